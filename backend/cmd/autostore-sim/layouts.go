@@ -0,0 +1,60 @@
+package main
+
+import (
+	"autostore-sim/backend/models"
+	"autostore-sim/backend/services"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// layoutsCommand places the catalog with each strategy and prints the estimated picking cost
+func layoutsCommand(args []string) error {
+	flags := flag.NewFlagSet("layouts", flag.ExitOnError)
+	width := flags.Int("width", 8, "warehouse width")
+	height := flags.Int("height", 8, "warehouse height")
+	levels := flags.Int("levels", 5, "warehouse levels")
+	products := flags.String("products", "data/products.json", "products JSON file")
+	layoutFile := flags.String("layout", "", "layout JSON file to compare as well")
+	seed := flags.Int64("seed", 1, "seed for the random strategy and quantities")
+	flags.Parse(args)
+
+	strategies := []string{services.PlacementRandom, services.PlacementVelocity, services.PlacementCategory}
+	if *layoutFile != "" {
+		strategies = append(strategies, services.PlacementLayout)
+	}
+
+	defer silenceStdout()()
+
+	var costs []services.LayoutCost
+	for _, name := range strategies {
+		strategy, err := services.ParsePlacementStrategy(name, *layoutFile)
+		if err != nil {
+			return err
+		}
+
+		productService := services.NewProductService()
+		if err := productService.LoadProductsFromPath(*products); err != nil {
+			return err
+		}
+		productService.SetSeed(*seed)
+
+		warehouse := models.NewSafeWarehouse(*width, *height, *levels)
+		cost, err := productService.PlaceProducts(warehouse, strategy)
+		if err != nil {
+			return err
+		}
+		costs = append(costs, cost)
+	}
+
+	fmt.Fprintf(os.Stderr, "%-10s %6s %8s %10s %8s %12s\n",
+		"strategy", "bins", "digs", "port dist", "levels", "s per pick")
+	fmt.Fprintln(os.Stderr, strings.Repeat("-", 59))
+	for _, cost := range costs {
+		fmt.Fprintf(os.Stderr, "%-10s %6d %8.2f %10.2f %8.2f %12.2f\n",
+			cost.Strategy, cost.Bins, cost.ExpectedDigs, cost.ExpectedPortDistance,
+			cost.ExpectedLevels, cost.SecondsPerPick)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"autostore-sim/backend/simulation"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const usage = `Usage: autostore-sim <command> [flags]
+
+Commands:
+  run       Run a headless simulation on the accelerated clock and write a KPI report
+  scenario  Run scripted scenario files and check their expected outcomes
+  layouts   Compare the estimated picking cost of the placement strategies
+  token     Issue an API token for a role, signed with AUTOSTORE_JWT_SECRET
+
+Run "autostore-sim <command> -h" for command flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = runCommand(os.Args[2:])
+	case "scenario":
+		err = scenarioCommand(os.Args[2:])
+	case "layouts":
+		err = layoutsCommand(os.Args[2:])
+	case "token":
+		err = tokenCommand(os.Args[2:])
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runOptions are the parsed flags of the run command
+type runOptions struct {
+	configs  []simulation.Config // One per sweep value, or the single run
+	duration time.Duration
+	outDir   string
+	format   string
+	sweep    bool
+	verbose  bool
+}
+
+// runCommand runs one simulation, or one per sweep value, and writes the reports
+func runCommand(args []string) error {
+	opts, err := parseRunFlags(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil // The flags were printed
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(opts.outDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	if !opts.verbose {
+		defer silenceStdout()()
+	}
+
+	// Ctrl-C ends the current run early, its report is still written
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return runSimulations(ctx, opts)
+}
+
+// parseRunFlags reads the run command's flags into validated configs
+func parseRunFlags(args []string) (runOptions, error) {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	configPath := flags.String("config", "", "JSON config file (defaults to the 8x8x5 warehouse)")
+	hours := flags.Float64("hours", 1, "simulated hours to run")
+	robots := flags.Int("robots", 0, "number of robots (overrides config)")
+	ordersPerHour := flags.Float64("orders-per-hour", -1, "random order demand (overrides config)")
+	speed := flags.Float64("speed", 1000, "simulated seconds per wall-clock second")
+	products := flags.String("products", "", "products JSON file (overrides config)")
+	placement := flags.String("placement", "", "placement strategy: random, velocity, category or layout (overrides config)")
+	layoutFile := flags.String("layout", "", "layout JSON file for -placement layout")
+	seed := flags.Int64("seed", 0, "seed for reproducible placement (overrides config)")
+	returnPolicy := flags.String("return-policy", "", "where returned bins go: same_stack, nearest_top or popularity (overrides config)")
+	compartments := flags.Int("compartments", 0, "compartments per bin: 1, 2, 4 or 8 (overrides config)")
+	autoReorder := flags.Bool("auto-reorder", false, "raise receipts when products reach their reorder point (overrides config)")
+	housekeeping := flags.Bool("housekeeping", false, "let idle robots move hot bins up (overrides config)")
+	fleetSpec := flags.String("fleet", "", "robots per type, e.g. r5=4,cantilever=2 (overrides config)")
+	outDir := flags.String("out", "results", "directory for report files")
+	format := flags.String("format", "both", "report format: json, csv or both")
+	sweepSpec := flags.String("sweep", "", "parameter sweep, e.g. robots=3..20 or orders_per_hour=60..240:60")
+	verbose := flags.Bool("verbose", false, "keep simulation logging on stdout")
+	if err := flags.Parse(args); err != nil {
+		return runOptions{}, err
+	}
+
+	if *format != "json" && *format != "csv" && *format != "both" {
+		return runOptions{}, fmt.Errorf("invalid format %q: expected json, csv or both", *format)
+	}
+	if *hours <= 0 {
+		return runOptions{}, fmt.Errorf("hours must be positive, got %v", *hours)
+	}
+
+	cfg := simulation.DefaultConfig()
+	cfg.OrdersPerHour = 120 // Headless runs need demand to be useful
+	if *configPath != "" {
+		loaded, err := simulation.LoadConfig(*configPath)
+		if err != nil {
+			return runOptions{}, err
+		}
+		cfg = loaded
+	}
+	if *robots > 0 {
+		cfg.Robots = *robots
+	}
+	if *ordersPerHour >= 0 {
+		cfg.OrdersPerHour = *ordersPerHour
+	}
+	if *products != "" {
+		cfg.ProductsFile = *products
+	}
+	if *placement != "" {
+		cfg.Placement = *placement
+	}
+	if *layoutFile != "" {
+		cfg.LayoutFile = *layoutFile
+	}
+	if *seed != 0 {
+		cfg.Seed = *seed
+	}
+	if *returnPolicy != "" {
+		cfg.ReturnPolicy = *returnPolicy
+	}
+	if *compartments > 0 {
+		cfg.Compartments = *compartments
+	}
+	if *autoReorder {
+		cfg.AutoReorder = true
+	}
+	if *housekeeping {
+		cfg.Housekeeping = true
+	}
+	if *fleetSpec != "" {
+		fleet, err := simulation.ParseFleet(*fleetSpec)
+		if err != nil {
+			return runOptions{}, err
+		}
+		cfg.Fleet = fleet
+		cfg.Robots = 0
+		for _, entry := range fleet {
+			cfg.Robots += entry.Count
+		}
+	}
+	cfg.ClockSpeed = *speed
+
+	configs := []simulation.Config{cfg}
+	if *sweepSpec != "" {
+		sweep, err := simulation.ParseSweep(*sweepSpec)
+		if err != nil {
+			return runOptions{}, err
+		}
+		configs = sweep.Configs(cfg)
+	}
+	for _, c := range configs {
+		if err := c.Validate(); err != nil {
+			return runOptions{}, err
+		}
+	}
+
+	return runOptions{
+		configs:  configs,
+		duration: time.Duration(*hours * float64(time.Hour)),
+		outDir:   *outDir,
+		format:   *format,
+		sweep:    *sweepSpec != "",
+		verbose:  *verbose,
+	}, nil
+}
+
+// runSimulations runs the configs until ctx ends and writes the reports of the runs that
+// finished, the one cut short included. It fails when ctx ends before any run started.
+func runSimulations(ctx context.Context, opts runOptions) error {
+	var reports []*simulation.RunReport
+	for i, c := range opts.configs {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] robots=%d orders/h=%v: simulating %v at %vx...\n",
+			i+1, len(opts.configs), c.Robots, c.OrdersPerHour, opts.duration, c.ClockSpeed)
+
+		report, err := simulation.Run(ctx, c, opts.duration)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+
+		fmt.Fprintf(os.Stderr, "      completed %d/%d orders, %.1f orders/h, p95 lead time %.1fs, utilisation %.0f%%\n",
+			report.OrdersCompleted, report.OrdersCreated, report.KPIs.OrdersPerHour,
+			report.KPIs.P95LeadTimeSeconds, report.KPIs.RobotUtilisation*100)
+	}
+	if len(reports) == 0 {
+		return fmt.Errorf("stopped before the first run: %w", ctx.Err())
+	}
+
+	if opts.sweep {
+		return writeSweep(opts.outDir, opts.format, reports)
+	}
+	return writeRun(opts.outDir, opts.format, reports[0])
+}
+
+// writeRun writes summary.json and/or summary.csv plus orders.csv for a single run
+func writeRun(outDir, format string, report *simulation.RunReport) error {
+	if format == "json" || format == "both" {
+		if err := writeFile(filepath.Join(outDir, "summary.json"), report.WriteJSON); err != nil {
+			return err
+		}
+	}
+	if format == "csv" || format == "both" {
+		reports := []*simulation.RunReport{report}
+		if err := writeFile(filepath.Join(outDir, "summary.csv"), func(w io.Writer) error {
+			return simulation.WriteSummaryCSV(w, reports)
+		}); err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(outDir, "orders.csv"), report.WriteOrdersCSV); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSweep writes one summary row per sweep value to sweep.json and/or sweep.csv
+func writeSweep(outDir, format string, reports []*simulation.RunReport) error {
+	if format == "json" || format == "both" {
+		// Per-order records would dwarf the comparison, keep only the summaries
+		summaries := make([]simulation.RunReport, len(reports))
+		for i, report := range reports {
+			summaries[i] = *report
+			summaries[i].Orders = nil
+			summaries[i].LayoutHistory = nil
+		}
+		if err := writeFile(filepath.Join(outDir, "sweep.json"), func(w io.Writer) error {
+			return writeIndentedJSON(w, summaries)
+		}); err != nil {
+			return err
+		}
+	}
+	if format == "csv" || format == "both" {
+		if err := writeFile(filepath.Join(outDir, "sweep.csv"), func(w io.Writer) error {
+			return simulation.WriteSummaryCSV(w, reports)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
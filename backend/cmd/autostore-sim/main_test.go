@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRunFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		check func(t *testing.T, opts runOptions)
+		err   string
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, opts runOptions) {
+				c := opts.configs[0]
+				if len(opts.configs) != 1 || opts.duration != time.Hour || opts.format != "both" || opts.outDir != "results" || opts.sweep {
+					t.Errorf("unexpected options %+v", opts)
+				}
+				if c.OrdersPerHour != 120 || c.ClockSpeed != 1000 {
+					t.Errorf("orders/h %v, speed %v", c.OrdersPerHour, c.ClockSpeed)
+				}
+			},
+		},
+		{
+			name: "overrides",
+			args: []string{"-hours", "0.5", "-robots", "3", "-orders-per-hour", "0", "-speed", "50", "-return-policy", "popularity", "-compartments", "4", "-format", "csv", "-out", "runs"},
+			check: func(t *testing.T, opts runOptions) {
+				c := opts.configs[0]
+				if c.Robots != 3 || c.OrdersPerHour != 0 || c.ClockSpeed != 50 || c.ReturnPolicy != "popularity" || c.Compartments != 4 {
+					t.Errorf("unexpected config %+v", c)
+				}
+				if opts.duration != 30*time.Minute || opts.format != "csv" || opts.outDir != "runs" {
+					t.Errorf("unexpected options %+v", opts)
+				}
+			},
+		},
+		{
+			name: "fleet sets the robot count",
+			args: []string{"-robots", "9", "-fleet", "r5=2,cantilever=1"},
+			check: func(t *testing.T, opts runOptions) {
+				if c := opts.configs[0]; c.Robots != 3 || len(c.Fleet) != 2 {
+					t.Errorf("robots %d, fleet %+v", c.Robots, c.Fleet)
+				}
+			},
+		},
+		{
+			name: "sweep",
+			args: []string{"-sweep", "robots=2..4"},
+			check: func(t *testing.T, opts runOptions) {
+				if !opts.sweep || len(opts.configs) != 3 || opts.configs[0].Robots != 2 || opts.configs[2].Robots != 4 {
+					t.Errorf("unexpected sweep %+v", opts.configs)
+				}
+			},
+		},
+		{name: "bad format", args: []string{"-format", "xml"}, err: `invalid format "xml"`},
+		{name: "no hours", args: []string{"-hours", "0"}, err: "hours must be positive"},
+		{name: "bad compartments", args: []string{"-compartments", "3"}, err: "compartments"},
+		{name: "bad sweep", args: []string{"-sweep", "robots"}, err: "sweep"},
+		{name: "unknown flag", args: []string{"-robot", "3"}, err: "flag provided but not defined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseRunFlags(tt.args)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, opts)
+		})
+	}
+}
+
+// TestRunSimulationsCancelledBeforeFirstRun is Ctrl-C during startup: no run, no report, an error
+func TestRunSimulationsCancelledBeforeFirstRun(t *testing.T) {
+	for _, args := range [][]string{nil, {"-sweep", "robots=2..3"}} {
+		opts, err := parseRunFlags(append(args, "-out", t.TempDir()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err = runSimulations(ctx, opts)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%v: error %v, want context.Canceled", args, err)
+		}
+		if files, _ := os.ReadDir(opts.outDir); len(files) != 0 {
+			t.Errorf("%v: reports written without a run: %v", args, files)
+		}
+	}
+}
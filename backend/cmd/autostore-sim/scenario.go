@@ -0,0 +1,114 @@
+package main
+
+import (
+	"autostore-sim/backend/handlers"
+	"autostore-sim/backend/simulation"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scenarioAPI is the REST router scenario API actions call, without WebSocket clients
+func scenarioAPI(sim *simulation.Simulation) http.Handler {
+	return handlers.NewServer(handlers.SimulationDependencies(sim, nil))
+}
+
+// scenarioCommand runs each scenario file and fails if any expectation does not hold
+func scenarioCommand(args []string) error {
+	flags := flag.NewFlagSet("scenario", flag.ExitOnError)
+	speed := flags.Float64("speed", 100, "simulated seconds per wall-clock second (0 keeps the file's clock_speed)")
+	outDir := flags.String("out", "", "directory for per-scenario JSON results")
+	verbose := flags.Bool("verbose", false, "keep simulation logging on stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: autostore-sim scenario [flags] <scenario.json>...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no scenario files given")
+	}
+
+	// Load everything first so a typo doesn't surface halfway through a suite
+	var scenarios []*simulation.Scenario
+	var names []string
+	for _, path := range flags.Args() {
+		scenario, err := simulation.LoadScenario(path)
+		if err != nil {
+			return err
+		}
+		if *speed > 0 {
+			scenario.Config.ClockSpeed = *speed
+		}
+		scenarios = append(scenarios, scenario)
+		names = append(names, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	}
+
+	if *outDir != "" {
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	if !*verbose {
+		gin.SetMode(gin.ReleaseMode)
+		gin.DefaultWriter = io.Discard
+		defer silenceStdout()()
+	}
+
+	failed := 0
+	for i, scenario := range scenarios {
+		fmt.Fprintf(os.Stderr, "=== RUN   %s\n", scenario.Name)
+		if scenario.Description != "" {
+			fmt.Fprintf(os.Stderr, "          %s\n", scenario.Description)
+		}
+
+		wallStart := time.Now()
+		result, err := simulation.RunScenario(scenario, scenarioAPI)
+		if err != nil {
+			return fmt.Errorf("scenario %s: %w", scenario.Name, err)
+		}
+
+		for _, actionErr := range result.ActionErrors {
+			fmt.Fprintf(os.Stderr, "    ERROR %s\n", actionErr)
+		}
+		for _, check := range result.Checks {
+			status := "PASS"
+			if !check.Passed {
+				status = "FAIL"
+			}
+			fmt.Fprintf(os.Stderr, "    %s  %s (%s)\n", status, check.Description, check.Detail)
+		}
+
+		verdict := "PASS"
+		if !result.Passed {
+			verdict = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(os.Stderr, "--- %s: %s (%.1fs)\n", verdict, scenario.Name, time.Since(wallStart).Seconds())
+
+		if *outDir != "" {
+			path := filepath.Join(*outDir, names[i]+".result.json")
+			if err := writeFile(path, func(w io.Writer) error {
+				return writeIndentedJSON(w, result)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scenarios failed", failed, len(scenarios))
+	}
+	fmt.Fprintf(os.Stderr, "ok  %d scenarios passed\n", len(scenarios))
+	return nil
+}
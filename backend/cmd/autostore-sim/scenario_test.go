@@ -0,0 +1,39 @@
+package main
+
+import (
+	"autostore-sim/backend/simulation"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestExpressWhileBusyScenario plays the scenario file through the REST API, as the scenario command does
+func TestExpressWhileBusyScenario(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs five simulated minutes")
+	}
+	gin.SetMode(gin.TestMode)
+	t.Chdir("../..") // Scenario and product paths are relative to the backend directory
+
+	scenario, err := simulation.LoadScenario("scenarios/express_while_busy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenario.Config.ClockSpeed = 100
+
+	result, err := simulation.RunScenario(scenario, scenarioAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, actionErr := range result.ActionErrors {
+		t.Errorf("action failed: %s", actionErr)
+	}
+	for _, check := range result.Checks {
+		if !check.Passed {
+			t.Errorf("%s: %s", check.Description, check.Detail)
+		}
+	}
+	if !result.Passed || len(result.Checks) != len(scenario.Expect) {
+		t.Errorf("scenario did not pass, %d of %d checks run", len(result.Checks), len(scenario.Expect))
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"autostore-sim/backend/handlers"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// tokenCommand issues an API token signed with AUTOSTORE_JWT_SECRET, the secret the server verifies tokens with
+func tokenCommand(args []string) error {
+	flags := flag.NewFlagSet("token", flag.ExitOnError)
+	role := flags.String("role", "viewer", "role the token grants: viewer, operator or admin")
+	subject := flags.String("subject", "", "who the token is for, e.g. a user or CI job")
+	ttl := flags.Duration("ttl", 24*time.Hour, "how long the token is valid, 0 for no expiry")
+	flags.Parse(args)
+
+	secret := os.Getenv("AUTOSTORE_JWT_SECRET")
+	if secret == "" {
+		return fmt.Errorf("AUTOSTORE_JWT_SECRET is not set")
+	}
+	if *ttl < 0 {
+		return fmt.Errorf("ttl must not be negative, got %v", *ttl)
+	}
+
+	now := time.Now()
+	claims := handlers.TokenClaims{Subject: *subject, Role: *role, IssuedAt: now.Unix()}
+	if *ttl > 0 {
+		claims.ExpiresAt = now.Add(*ttl).Unix()
+	}
+	token, err := handlers.SignToken([]byte(secret), claims)
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
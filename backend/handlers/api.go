@@ -4,62 +4,181 @@ import (
 	"autostore-sim/backend/models"
 	"autostore-sim/backend/services"
 	ws "autostore-sim/backend/websocket"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-type Server struct {
+// Fleet adds and removes robots while the warehouse runs, e.g. simulation.Simulation
+type Fleet interface {
+	ActiveRobots() []*models.Robot
+	AddRobot(typeName string, pos models.Position) (*models.Robot, error)
+	RemoveRobot(id int) (*models.Robot, error)
+}
+
+// Dependencies are the services of one warehouse a Server works on
+type Dependencies struct {
 	OrderService   *services.OrderService
 	ProductService *services.ProductService
+	Analytics      *services.AnalyticsService
+	Slotting       *services.SlottingService
+	Receiving      *services.ReceivingService
+	Events         *services.EventLog
+	Inventory      *services.InventoryMonitor
+	Traffic        *services.TrafficController
 	Warehouse      *models.SafeWarehouse
-	Robots         []*models.Robot
+	Fleet          Fleet
 	Workstations   []models.Workstation
-	WebSocketHub   *ws.Hub
+	Clock          models.Clock // Simulation time, reported in the status
+	WebSocketHub   *ws.Hub      // Optional, WebSocket and event stream routes are unavailable without it
+	Auth           AuthConfig   // Who may call the API, anyone as admin when empty
+	Frontend       http.Handler // Optional, serves the web app on every path outside /api and /ws
 }
 
-var server Server
+// Server serves the REST, WebSocket and event stream API of one warehouse. Servers share no
+// state, so one process can run several warehouses side by side.
+type Server struct {
+	Dependencies
+	router  *gin.Engine
+	auth    *authenticator
+	started time.Time
+}
 
-// InitializeServer sets up all services for API handlers
-func InitializeServer(os *services.OrderService, ps *services.ProductService,
-	wh *models.SafeWarehouse, rbs []*models.Robot, wss []models.Workstation, hub *ws.Hub) {
-	server = Server{
-		OrderService:   os,
-		ProductService: ps,
-		Warehouse:      wh,
-		Robots:         rbs,
-		Workstations:   wss,
-		WebSocketHub:   hub,
+// NewServer creates a server for the given warehouse and builds its routes. The WebSocket hub,
+// if any, gets its snapshots and origin check from the server, so create it before the hub runs.
+func NewServer(deps Dependencies) *Server {
+	s := &Server{Dependencies: deps, auth: newAuthenticator(deps.Auth), started: time.Now()}
+	if s.WebSocketHub != nil {
+		s.WebSocketHub.Snapshot = s.WarehouseSnapshot
+		s.WebSocketHub.CheckOrigin = s.auth.checkOrigin
 	}
+	s.router = s.setupRouter()
+	return s
 }
 
-// GetRobots returns all robots
-func GetRobots(c *gin.Context) {
-	c.JSON(http.StatusOK, server.Robots)
+// ServeHTTP serves a request through the server's routes, so a Server can be handed to
+// http.Server or httptest.NewServer as is
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
 }
 
-// GetOrders returns all orders
-func GetOrders(c *gin.Context) {
-	c.JSON(http.StatusOK, server.OrderService.GetActiveOrders())
+// GetRobots returns the active robots (?status=idle)
+func (s *Server) GetRobots(c *gin.Context) {
+	robots := s.Fleet.ActiveRobots()
+	if status := c.Query("status"); status != "" {
+		matching := []*models.Robot{}
+		for _, robot := range robots {
+			if robot.Snapshot().Status == status {
+				matching = append(matching, robot)
+			}
+		}
+		robots = matching
+	}
+	c.JSON(http.StatusOK, robots)
+}
+
+// GetOrders returns the active orders, or every order with the given status (?status=completed&priority=express)
+func (s *Server) GetOrders(c *gin.Context) {
+	status := models.OrderStatus(c.Query("status"))
+	priority := models.Priority(c.Query("priority"))
+	if status == "" && priority == "" {
+		c.JSON(http.StatusOK, s.OrderService.GetActiveOrders())
+		return
+	}
+
+	orders := s.OrderService.GetActiveOrders()
+	if status != "" {
+		orders = s.OrderService.GetAllOrders()
+	}
+	matching := []models.Order{}
+	for _, order := range orders {
+		if (status == "" || order.Status == status) && (priority == "" || order.Priority == priority) {
+			matching = append(matching, order)
+		}
+	}
+	c.JSON(http.StatusOK, matching)
 }
 
 // GetWorkstations returns all workstations
-func GetWorkstations(c *gin.Context) {
-	c.JSON(http.StatusOK, server.Workstations)
+func (s *Server) GetWorkstations(c *gin.Context) {
+	c.JSON(http.StatusOK, s.Workstations)
+}
+
+// GetAnalytics returns KPIs over a rolling window (?window=1h)
+func (s *Server) GetAnalytics(c *gin.Context) {
+	window, err := parseDurationQuery(c, "window", time.Hour)
+	if err != nil {
+		problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, s.Analytics.Report(window))
+}
+
+// GetAnalyticsTimeSeries returns KPIs bucketed per interval (?window=1h&interval=5m)
+func (s *Server) GetAnalyticsTimeSeries(c *gin.Context) {
+	window, err := parseDurationQuery(c, "window", time.Hour)
+	if err != nil {
+		problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	interval, err := parseDurationQuery(c, "interval", 5*time.Minute)
+	if err != nil {
+		problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Keep responses bounded
+	if window/interval > maxTimeSeriesBuckets {
+		problem(c, http.StatusBadRequest, fmt.Sprintf("window/interval must not exceed %d buckets", maxTimeSeriesBuckets))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"window":   window.String(),
+		"interval": interval.String(),
+		"series":   s.Analytics.TimeSeries(window, interval),
+	})
 }
 
-// GetWarehouseStatus returns complete warehouse state
-func GetWarehouseStatus(c *gin.Context) {
-	c.JSON(http.StatusOK, server)
+// maxTimeSeriesBuckets limits the number of points in a time-series response
+const maxTimeSeriesBuckets = 1000
+
+// parseDurationQuery reads a positive duration query parameter like "15m"
+func parseDurationQuery(c *gin.Context, name string, fallback time.Duration) (time.Duration, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a positive duration like 15m or 1h", name, raw)
+	}
+	return d, nil
 }
 
-// GetWarehouseData returns current warehouse state for processing
-func GetWarehouseData() ([]*models.Robot, []models.Order, []models.Workstation) {
-	return server.Robots, server.OrderService.GetActiveOrders(), server.Workstations
+// GetLayoutCost returns the estimated picking cost of the current inventory layout
+func (s *Server) GetLayoutCost(c *gin.Context) {
+	c.JSON(http.StatusOK, s.ProductService.EvaluateLayout(s.Warehouse))
 }
 
-func ProcessWarehouseOrders() {
-	server.OrderService.ProcessPendingOrders(server.Robots)
+// GetBins returns the bins in the grid with their compartments, fill and weight
+func (s *Server) GetBins(c *gin.Context) {
+	c.JSON(http.StatusOK, s.ProductService.DescribeBins(s.Warehouse))
+}
+
+// GetLayoutHistory returns layout cost samples over time, showing how returned bins reshape the grid
+func (s *Server) GetLayoutHistory(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"return_policy": s.Slotting.ReturnPolicy(),
+		"samples":       s.Slotting.GetLayoutHistory(),
+	})
 }
 
 // CreateOrderRequest represents the JSON structure for creating orders
@@ -67,17 +186,31 @@ type CreateOrderRequest struct {
 	CustomerName string `json:"customer_name" binding:"required"`
 	ProductID    int    `json:"product_id" binding:"required"`
 	RequestedQty int    `json:"requested_qty" binding:"required,min=1"`
-	Priority     string `json:"priority"`
+	Priority     string `json:"priority" binding:"omitempty,oneof=normal urgent express"`
 }
 
 // CreateOrder creates a new order
-func CreateOrder(c *gin.Context) {
+func (s *Server) CreateOrder(c *gin.Context) {
 	var req CreateOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	order, err := s.createOrder(req)
+	if err != nil {
+		problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Order created successfully",
+		"order":   order,
+	})
+}
+
+// createOrder queues an order from a validated request, shared by the REST and WebSocket APIs
+func (s *Server) createOrder(req CreateOrderRequest) (*models.Order, error) {
 	// Default to normal priority if not specified
 	priority := models.PriorityNormal
 	if req.Priority != "" {
@@ -85,26 +218,202 @@ func CreateOrder(c *gin.Context) {
 	}
 
 	// Create order via OrderService
-	order := server.OrderService.CreateOrder(req.CustomerName, req.ProductID, req.RequestedQty, priority)
+	order := s.OrderService.CreateOrder(req.CustomerName, req.ProductID, req.RequestedQty, priority)
 	if order == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to create order"})
+		return nil, fmt.Errorf("create order: unknown product %d", req.ProductID)
+	}
+	return order, nil
+}
+
+// AddRobotRequest represents the JSON structure for adding a robot, X and Y are a preferred cell
+type AddRobotRequest struct {
+	Type string `json:"type"`
+	X    int    `json:"x" binding:"min=0"`
+	Y    int    `json:"y" binding:"min=0"`
+}
+
+// AddRobot starts a new robot on a free cell
+func (s *Server) AddRobot(c *gin.Context) {
+	// Every field is optional, an empty body adds a standard robot
+	var req AddRobotRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	robot, err := s.Fleet.AddRobot(req.Type, models.Position{X: req.X, Y: req.Y})
+	if errors.Is(err, models.ErrNoFreeCell) {
+		problem(c, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		problem(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Order created successfully",
-		"order":   order,
+		"message": "Robot added successfully",
+		"robot":   robot,
 	})
 }
 
+// RemoveRobot drains a robot: it finishes its current order and then leaves the fleet
+func (s *Server) RemoveRobot(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem(c, http.StatusBadRequest, "robot id must be a number")
+		return
+	}
+
+	robot, err := s.Fleet.RemoveRobot(id)
+	if errors.Is(err, models.ErrUnknownRobot) {
+		problem(c, http.StatusNotFound, fmt.Sprintf("robot %d not found", id))
+		return
+	}
+	if err != nil {
+		problem(c, http.StatusConflict, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Robot is draining and will leave the fleet",
+		"robot":   robot,
+	})
+}
+
+// PauseRobot makes a robot hold after its current command until it is resumed
+func (s *Server) PauseRobot(c *gin.Context) {
+	s.setRobotPaused(c, true)
+}
+
+// ResumeRobot lets a paused robot carry on with its queued commands
+func (s *Server) ResumeRobot(c *gin.Context) {
+	s.setRobotPaused(c, false)
+}
+
+// setRobotPaused pauses or resumes the robot named in the path
+func (s *Server) setRobotPaused(c *gin.Context, paused bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem(c, http.StatusBadRequest, "robot id must be a number")
+		return
+	}
+
+	robot, err := s.pauseRobot(id, paused)
+	if err != nil {
+		problem(c, http.StatusNotFound, fmt.Sprintf("robot %d not found", id))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"robot": robot})
+}
+
+// pauseRobot pauses or resumes an active robot, shared by the REST and WebSocket APIs
+func (s *Server) pauseRobot(id int, paused bool) (*models.Robot, error) {
+	for _, robot := range s.Fleet.ActiveRobots() {
+		if robot.ID != id {
+			continue
+		}
+		if paused {
+			robot.Pause()
+		} else {
+			robot.Resume()
+		}
+		return robot, nil
+	}
+	return nil, models.ErrUnknownRobot
+}
+
+// GetReceipts returns all inbound receipts
+func (s *Server) GetReceipts(c *gin.Context) {
+	c.JSON(http.StatusOK, s.Receiving.GetAllReceipts())
+}
+
+// CreateReceiptRequest represents the JSON structure for creating receipts
+type CreateReceiptRequest struct {
+	ProductID int `json:"product_id" binding:"required"`
+	Quantity  int `json:"quantity" binding:"required,min=1"`
+}
+
+// CreateReceipt records inbound stock to be put away through goods-in
+func (s *Server) CreateReceipt(c *gin.Context) {
+	var req CreateReceiptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	receipt, err := s.createReceipt(req)
+	if err != nil {
+		problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Receipt created successfully",
+		"receipt": receipt,
+	})
+}
+
+// createReceipt records a receipt from a validated request, shared by the REST and WebSocket APIs
+func (s *Server) createReceipt(req CreateReceiptRequest) (*models.Receipt, error) {
+	receipt, err := s.Receiving.CreateReceipt(req.ProductID, req.Quantity, models.ReceiptSourceAPI)
+	if err != nil {
+		return nil, fmt.Errorf("create receipt: %w", err)
+	}
+	return receipt, nil
+}
+
+// GetTraffic returns congestion metrics: delays, detours, blocked requests and deadlocks
+func (s *Server) GetTraffic(c *gin.Context) {
+	c.JSON(http.StatusOK, s.Traffic.GetMetrics())
+}
+
+// GetEvents returns the newest events (?limit=100&type=low_stock), or the live stream of
+// diffs when the client accepts text/event-stream
+func (s *Server) GetEvents(c *gin.Context) {
+	if wantsEventStream(c) {
+		s.StreamEvents(c)
+		return
+	}
+
+	limit := 100
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			problem(c, http.StatusBadRequest, fmt.Sprintf("invalid limit %q: expected a positive number", raw))
+			return
+		}
+		limit = n
+	}
+
+	c.JSON(http.StatusOK, s.Events.Recent(limit, models.EventType(c.Query("type"))))
+}
+
+// GetWebSocketStats returns per-client message counters: sent, coalesced for slow clients and dropped
+func (s *Server) GetWebSocketStats(c *gin.Context) {
+	if s.WebSocketHub == nil {
+		c.JSON(http.StatusOK, ws.HubStats{PerClient: []ws.ClientStats{}})
+		return
+	}
+	c.JSON(http.StatusOK, s.WebSocketHub.Stats())
+}
+
 // HandleWebSocket upgrades HTTP connection to WebSocket
-func HandleWebSocket(c *gin.Context) {
-	ws.ServeWs(server.WebSocketHub, c.Writer, c.Request)
+func (s *Server) HandleWebSocket(c *gin.Context) {
+	if s.WebSocketHub == nil {
+		problem(c, http.StatusServiceUnavailable, "live updates not available")
+		return
+	}
+	ws.ServeWs(s.WebSocketHub, c.Writer, c.Request, s.commandsFor(callerRole(c)))
 }
 
-// BroadcastRobotUpdate sends robot state updates to all connected WebSocket clients
-func BroadcastRobotUpdate(update models.RobotUpdate) {
-	if server.WebSocketHub != nil {
-		server.WebSocketHub.BroadcastRobotUpdate(update)
+// WarehouseSnapshot returns the state WebSocket clients start from: robots, active orders,
+// workstations and the bins in the grid
+func (s *Server) WarehouseSnapshot() ws.WarehouseState {
+	return ws.WarehouseState{
+		Robots:       s.Fleet.ActiveRobots(),
+		Orders:       s.OrderService.GetActiveOrders(),
+		Workstations: s.Workstations,
+		Grid:         s.Warehouse.Occupancy(),
 	}
 }
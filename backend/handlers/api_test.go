@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"autostore-sim/backend/models"
+	"autostore-sim/backend/services"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubFleet is a fleet of robots that never move
+type stubFleet struct {
+	robots []*models.Robot
+}
+
+func (f *stubFleet) ActiveRobots() []*models.Robot { return f.robots }
+
+func (f *stubFleet) AddRobot(typeName string, pos models.Position) (*models.Robot, error) {
+	robot := &models.Robot{RobotState: models.RobotState{ID: len(f.robots) + 1, X: pos.X, Y: pos.Y, Status: "idle"}}
+	f.robots = append(f.robots, robot)
+	return robot, nil
+}
+
+func (f *stubFleet) RemoveRobot(id int) (*models.Robot, error) {
+	return nil, models.ErrUnknownRobot
+}
+
+// testServer builds a server on a small warehouse with one product, id 1, and two robots
+func testServer(t *testing.T) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	catalog := filepath.Join(t.TempDir(), "products.json")
+	data := `{"products": [{"id": 1, "name": "Brake Pad", "sku": "BP-1", "category": "brakes", "weight_kg": 0.5}]}`
+	if err := os.WriteFile(catalog, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	products := services.NewProductService()
+	if err := products.LoadProductsFromPath(catalog); err != nil {
+		t.Fatal(err)
+	}
+
+	warehouse := models.NewSafeWarehouse(4, 3, 2)
+	return NewServer(Dependencies{
+		OrderService:   services.NewOrderService(products, warehouse, nil, models.RealClock()),
+		ProductService: products,
+		Receiving:      services.NewReceivingService(products, nil, nil, models.RealClock()),
+		Warehouse:      warehouse,
+		Fleet: &stubFleet{robots: []*models.Robot{
+			{RobotState: models.RobotState{ID: 1, Status: "idle"}},
+			{RobotState: models.RobotState{ID: 2, Status: "moving", Paused: true}},
+		}},
+	})
+}
+
+// serve sends a request through the server's routes
+func serve(s *Server, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateOrder(t *testing.T) {
+	s := testServer(t)
+
+	w := serve(s, http.MethodPost, "/api/orders", `{"customer_name": "Downtown Garage", "product_id": 1, "requested_qty": 2, "priority": "urgent"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		Order models.Order `json:"order"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	order := created.Order
+	if order.ID != 1 || order.ProductID != 1 || order.RequestedQty != 2 || order.Priority != models.PriorityUrgent || order.Status != models.OrderPending {
+		t.Errorf("unexpected order %+v", order)
+	}
+	if orders := s.OrderService.GetActiveOrders(); len(orders) != 1 {
+		t.Errorf("%d orders queued, want 1", len(orders))
+	}
+}
+
+func TestCreateOrderUnknownProduct(t *testing.T) {
+	s := testServer(t)
+
+	w := serve(s, http.MethodPost, "/api/orders", `{"customer_name": "Downtown Garage", "product_id": 99, "requested_qty": 1}`)
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != problemContentType {
+		t.Fatalf("status %d with %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != http.StatusBadRequest || p.Detail == "" || p.Instance != "/api/orders" {
+		t.Errorf("unexpected problem %+v", p)
+	}
+	if orders := s.OrderService.GetActiveOrders(); len(orders) != 0 {
+		t.Errorf("%d orders queued for an unknown product", len(orders))
+	}
+}
+
+func TestCreateReceiptProblem(t *testing.T) {
+	s := testServer(t)
+
+	w := serve(s, http.MethodPost, "/api/receipts", `{"product_id": 99, "quantity": 5}`)
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || p.Detail != "create receipt: unknown product 99" {
+		t.Errorf("status %d with %+v", w.Code, p)
+	}
+
+	if w := serve(s, http.MethodPost, "/api/receipts", `{"product_id": 1, "quantity": 5}`); w.Code != http.StatusCreated {
+		t.Errorf("status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestWebSocketWithoutHub(t *testing.T) {
+	s := testServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Content-Type") != problemContentType {
+		t.Errorf("status %d with %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+}
+
+func TestGetWarehouseStatus(t *testing.T) {
+	s := testServer(t)
+	serve(s, http.MethodPost, "/api/orders", `{"customer_name": "Downtown Garage", "product_id": 1, "requested_qty": 1}`)
+
+	w := serve(s, http.MethodGet, "/api/status", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var status WarehouseStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if grid := status.Grid; grid.Width != 4 || grid.Height != 3 || grid.Levels != 2 || grid.Cells != 24 {
+		t.Errorf("unexpected grid %+v", grid)
+	}
+	if fleet := status.Fleet; fleet.Robots != 2 || fleet.Paused != 1 || fleet.ByStatus["idle"] != 1 || fleet.ByStatus["moving"] != 1 {
+		t.Errorf("unexpected fleet %+v", fleet)
+	}
+	if orders := status.Orders; orders.Total != 1 || orders.Active != 1 || orders.PendingByPriority[models.PriorityNormal] != 1 {
+		t.Errorf("unexpected orders %+v", orders)
+	}
+	if status.WebSocketClients != 0 {
+		t.Errorf("%d WebSocket clients without a hub", status.WebSocketClients)
+	}
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Role is what a caller may do, each role may do everything the roles below it may
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"   // Reads, event streams and WebSocket updates
+	RoleOperator Role = "operator" // Orders and goods-in receipts
+	RoleAdmin    Role = "admin"    // Robots joining, leaving, pausing and resuming
+)
+
+// roleRank orders the roles, unknown roles rank below viewer
+var roleRank = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// allows reports whether the role may do what the required role may
+func (r Role) allows(required Role) bool {
+	return roleRank[r] > 0 && roleRank[r] >= roleRank[required]
+}
+
+// ParseRole checks a role name
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if roleRank[role] == 0 {
+		return "", fmt.Errorf("unknown role %q: expected viewer, operator or admin", name)
+	}
+	return role, nil
+}
+
+// minJWTSecret is the shortest HS256 key accepted, as long as the hash
+const minJWTSecret = 32
+
+// AuthConfig says who may call the API. Without API keys and a JWT secret every caller is
+// an admin, which suits a simulator on a developer's machine and nothing else.
+type AuthConfig struct {
+	APIKeys        map[string]Role // Key to the role it grants
+	JWTSecret      []byte          // HS256 key tokens are verified against, tokens are refused without it
+	AllowedOrigins []string        // Browser origins besides the server's own, "*" for any
+}
+
+// enabled reports whether callers have to authenticate
+func (a AuthConfig) enabled() bool {
+	return len(a.APIKeys) > 0 || len(a.JWTSecret) > 0
+}
+
+// AuthConfigFromEnv reads the API keys (AUTOSTORE_API_KEYS=key:role,...), the JWT secret
+// (AUTOSTORE_JWT_SECRET) and the allowed origins (AUTOSTORE_ALLOWED_ORIGINS=https://a,https://b)
+func AuthConfigFromEnv() (AuthConfig, error) {
+	var cfg AuthConfig
+	if spec := os.Getenv("AUTOSTORE_API_KEYS"); spec != "" {
+		cfg.APIKeys = make(map[string]Role)
+		for _, entry := range strings.Split(spec, ",") {
+			key, name, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok || key == "" {
+				return cfg, fmt.Errorf("invalid API key entry %q: expected key:role", entry)
+			}
+			role, err := ParseRole(name)
+			if err != nil {
+				return cfg, fmt.Errorf("API key %s...: %w", key[:min(len(key), 4)], err)
+			}
+			cfg.APIKeys[key] = role
+		}
+	}
+	if secret := os.Getenv("AUTOSTORE_JWT_SECRET"); secret != "" {
+		if len(secret) < minJWTSecret {
+			return cfg, fmt.Errorf("AUTOSTORE_JWT_SECRET must be at least %d bytes", minJWTSecret)
+		}
+		cfg.JWTSecret = []byte(secret)
+	}
+	if origins := os.Getenv("AUTOSTORE_ALLOWED_ORIGINS"); origins != "" {
+		for _, origin := range strings.Split(origins, ",") {
+			cfg.AllowedOrigins = append(cfg.AllowedOrigins, strings.TrimRight(strings.TrimSpace(origin), "/"))
+		}
+	}
+	return cfg, nil
+}
+
+// authenticator checks credentials against an AuthConfig. API keys are kept as hashes, so
+// lookups never compare the keys themselves.
+type authenticator struct {
+	enabled   bool
+	apiKeys   map[[sha256.Size]byte]Role
+	jwtSecret []byte
+	origins   []string
+}
+
+// newAuthenticator prepares the checks for an AuthConfig
+func newAuthenticator(cfg AuthConfig) *authenticator {
+	a := &authenticator{
+		enabled:   cfg.enabled(),
+		apiKeys:   make(map[[sha256.Size]byte]Role, len(cfg.APIKeys)),
+		jwtSecret: cfg.JWTSecret,
+		origins:   cfg.AllowedOrigins,
+	}
+	for key, role := range cfg.APIKeys {
+		a.apiKeys[sha256.Sum256([]byte(key))] = role
+	}
+	return a
+}
+
+// errNoCredentials is returned for a request without a token or key
+var errNoCredentials = errors.New("authentication required: send Authorization: Bearer <token> or X-API-Key")
+
+// queryTokenRoutes are the only routes that take ?access_token=, browsers cannot set headers on
+// WebSocket and EventSource connections. Tokens in URLs end up in logs and histories.
+var queryTokenRoutes = map[string]bool{"GET /ws": true, "GET /api/events": true}
+
+// authenticate returns the role of the request's caller. The token comes from the
+// Authorization or X-API-Key header, or from ?access_token= when the route allows it.
+func (a *authenticator) authenticate(r *http.Request, route string) (Role, error) {
+	if !a.enabled {
+		return RoleAdmin, nil
+	}
+
+	token := r.Header.Get("X-API-Key")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = strings.TrimSpace(bearer)
+	}
+	if token == "" && queryTokenRoutes[r.Method+" "+route] {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token == "" {
+		return "", errNoCredentials
+	}
+
+	if strings.Count(token, ".") == 2 {
+		claims, err := verifyToken(a.jwtSecret, token, time.Now())
+		if err != nil {
+			return "", err
+		}
+		return ParseRole(claims.Role)
+	}
+	if role, ok := a.apiKeys[sha256.Sum256([]byte(token))]; ok {
+		return role, nil
+	}
+	return "", errors.New("invalid API key")
+}
+
+// allowsOrigin reports whether a browser on the given origin may call the API. The server's
+// own origin always may, others only when allowed.
+func (a *authenticator) allowsOrigin(origin, host string) bool {
+	if u, err := url.Parse(origin); err == nil && u.Host == host {
+		return true
+	}
+	for _, allowed := range a.origins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOrigin is the WebSocket upgrader's origin check, requests without an Origin are not from a browser
+func (a *authenticator) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || a.allowsOrigin(origin, r.Host)
+}
+
+// roleKey is where authorize leaves the caller's role in the Gin context
+const roleKey = "role"
+
+// authorize authenticates the caller and checks their role against the x-role the contract
+// gives the route's operation
+func (s *Server) authorize(c *gin.Context) {
+	op := openAPI.operation(c.Request.Method, c.FullPath())
+	if op == nil {
+		c.Next()
+		return
+	}
+
+	role, err := s.auth.authenticate(c.Request, c.FullPath())
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer realm="autostore"`)
+		problem(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if !role.allows(op.Role) {
+		problem(c, http.StatusForbidden, fmt.Sprintf("%s needs the %s role, the caller is %s", op.OperationID, op.Role, role))
+		return
+	}
+	c.Set(roleKey, role)
+	c.Next()
+}
+
+// callerRole returns the role authorize found for the request
+func callerRole(c *gin.Context) Role {
+	role, _ := c.Get(roleKey)
+	r, _ := role.(Role)
+	return r
+}
+
+// cors refuses browsers on origins that are not allowed and answers preflight requests, so a
+// frontend served from elsewhere can call the API with its token
+func (s *Server) cors(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		c.Next()
+		return
+	}
+	if !s.auth.allowsOrigin(origin, c.Request.Host) {
+		problem(c, http.StatusForbidden, "origin not allowed: "+origin)
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Set("Access-Control-Allow-Origin", origin)
+	header.Add("Vary", "Origin")
+	if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+		header.Set("Access-Control-Allow-Methods", "GET, POST, DELETE")
+		header.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-API-Key, Last-Event-ID")
+		header.Set("Access-Control-Max-Age", "600")
+		c.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+	header.Set("Access-Control-Expose-Headers", "WWW-Authenticate")
+	c.Next()
+}
+
+// TokenClaims are the claims of an API token, a JWT signed with HS256
+type TokenClaims struct {
+	Subject   string `json:"sub,omitempty"`
+	Role      string `json:"role"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"` // Unix seconds, the token never expires when zero
+}
+
+// jwtHeader is the only JWT header tokens are issued and accepted with
+const jwtHeader = `{"alg":"HS256","typ":"JWT"}`
+
+// SignToken issues an API token for the claims, signed with the JWT secret
+func SignToken(secret []byte, claims TokenClaims) (string, error) {
+	if len(secret) < minJWTSecret {
+		return "", fmt.Errorf("JWT secret must be at least %d bytes", minJWTSecret)
+	}
+	if _, err := ParseRole(claims.Role); err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(jwtHeader)) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(tokenSignature(secret, unsigned)), nil
+}
+
+// verifyToken checks a token's algorithm, signature and validity period and returns its claims
+func verifyToken(secret []byte, token string, now time.Time) (TokenClaims, error) {
+	var claims TokenClaims
+	if len(secret) == 0 {
+		return claims, errors.New("tokens are not accepted, use an API key")
+	}
+
+	parts := strings.Split(token, ".")
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return claims, errors.New("invalid token: malformed header")
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &h); err != nil || h.Alg != "HS256" {
+		return claims, errors.New("invalid token: only HS256 is accepted")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, tokenSignature(secret, parts[0]+"."+parts[1])) {
+		return claims, errors.New("invalid token: bad signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, errors.New("invalid token: malformed claims")
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, errors.New("invalid token: malformed claims")
+	}
+	if claims.ExpiresAt != 0 && now.Unix() >= claims.ExpiresAt {
+		return claims, errors.New("token expired")
+	}
+	if claims.NotBefore != 0 && now.Unix() < claims.NotBefore {
+		return claims, errors.New("token not valid yet")
+	}
+	return claims, nil
+}
+
+// tokenSignature is the HS256 signature of a token's header and claims
+func tokenSignature(secret []byte, unsigned string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestVerifyToken(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	valid, err := SignToken(testSecret, TokenClaims{Subject: "ops", Role: "operator", ExpiresAt: now.Unix() + 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{"valid", valid, ""},
+		{"bad signature", parts[0] + "." + parts[1] + "." + encode(tokenSignature([]byte("another secret, also 32 bytes..."), parts[0]+"."+parts[1])), "bad signature"},
+		{"tampered claims", parts[0] + "." + encode([]byte(`{"role":"admin"}`)) + "." + parts[2], "bad signature"},
+		{"alg none", encode([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + ".", "only HS256"},
+		{"alg HS512", signed(`{"alg":"HS512","typ":"JWT"}`, `{"role":"admin"}`), "only HS256"},
+		{"expired", signed(jwtHeader, `{"role":"viewer","exp":1700000000}`), "expired"},
+		{"not valid yet", signed(jwtHeader, `{"role":"viewer","nbf":1700000001}`), "not valid yet"},
+		{"valid from now", signed(jwtHeader, `{"role":"viewer","nbf":1700000000,"exp":1700000001}`), ""},
+		{"malformed header", "!." + parts[1] + "." + parts[2], "malformed header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifyToken(testSecret, tt.token, now)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := verifyToken(nil, valid, now); err == nil {
+		t.Error("token accepted without a JWT secret")
+	}
+	claims, _ := verifyToken(testSecret, valid, now)
+	if claims.Subject != "ops" || claims.Role != "operator" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role, required Role
+		want           bool
+	}{
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleOperator, false},
+		{RoleViewer, RoleAdmin, false},
+		{RoleOperator, RoleViewer, true},
+		{RoleOperator, RoleOperator, true},
+		{RoleOperator, RoleAdmin, false},
+		{RoleAdmin, RoleViewer, true},
+		{RoleAdmin, RoleAdmin, true},
+		{"", RoleViewer, false},
+		{"root", RoleViewer, false},
+		{"root", "root", false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.allows(tt.required); got != tt.want {
+			t.Errorf("%q.allows(%q) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := NewServer(Dependencies{Auth: AuthConfig{
+		APIKeys:   map[string]Role{"viewer-key": RoleViewer, "admin-key": RoleAdmin},
+		JWTSecret: testSecret,
+	}})
+	expired := signed(jwtHeader, `{"role":"admin","exp":1}`)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		status  int
+	}{
+		{"no credentials", "GET", "/api/openapi.json", nil, http.StatusUnauthorized},
+		{"unknown key", "GET", "/api/openapi.json", map[string]string{"X-API-Key": "guess"}, http.StatusUnauthorized},
+		{"expired token", "GET", "/api/openapi.json", map[string]string{"Authorization": "Bearer " + expired}, http.StatusUnauthorized},
+		{"viewer reads", "GET", "/api/openapi.json", map[string]string{"X-API-Key": "viewer-key"}, http.StatusOK},
+		{"viewer adds a robot", "POST", "/api/robots", map[string]string{"X-API-Key": "viewer-key"}, http.StatusForbidden},
+		{"viewer removes a robot", "DELETE", "/api/robots/1", map[string]string{"Authorization": "Bearer viewer-key"}, http.StatusForbidden},
+		{"no credentials to add a robot", "POST", "/api/robots", nil, http.StatusUnauthorized},
+		{"query token outside streams", "GET", "/api/openapi.json?access_token=admin-key", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+			if w.Code >= 400 && w.Header().Get("Content-Type") != "application/problem+json" {
+				t.Errorf("error with Content-Type %q", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestQueryTokenOnlyOnStreams(t *testing.T) {
+	a := newAuthenticator(AuthConfig{APIKeys: map[string]Role{"key": RoleViewer}})
+	for route, allowed := range map[string]bool{"/ws": true, "/api/events": true, "/api/robots": false, "/api/status": false} {
+		req := httptest.NewRequest(http.MethodGet, route+"?access_token=key", nil)
+		if _, err := a.authenticate(req, route); (err == nil) != allowed {
+			t.Errorf("GET %s with ?access_token=: err %v", route, err)
+		}
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/events?access_token=key", nil)
+	if _, err := a.authenticate(req, "/api/events"); err == nil {
+		t.Error("query token accepted on a POST")
+	}
+}
+
+func TestRedactToken(t *testing.T) {
+	tests := map[string]string{
+		"/api/robots":                             "/api/robots",
+		"/ws?access_token=secret":                 "/ws?access_token=REDACTED",
+		"/api/events?topic=robots&access_token=s": "/api/events?access_token=REDACTED&topic=robots",
+		"/api/events?topic=robots":                "/api/events?topic=robots",
+	}
+	for path, want := range tests {
+		if got := redactToken(path); got != want {
+			t.Errorf("redactToken(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+// encode is the base64url encoding of JWT segments
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// signed signs a token with any header and claims, unlike SignToken
+func signed(header, claims string) string {
+	unsigned := encode([]byte(header)) + "." + encode([]byte(claims))
+	return unsigned + "." + encode(tokenSignature(testSecret, unsigned))
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"autostore-sim/backend/models"
+	ws "autostore-sim/backend/websocket"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// robotCommandParams names the robot a WebSocket command acts on
+type robotCommandParams struct {
+	RobotID int `json:"robot_id" binding:"required"`
+}
+
+// commandRoles are the least roles allowed to run commands that change the warehouse, like
+// the matching REST routes. Any other command is open to viewers.
+var commandRoles = map[string]Role{
+	"create_order":   RoleOperator,
+	"create_receipt": RoleOperator,
+	"add_robot":      RoleAdmin,
+	"remove_robot":   RoleAdmin,
+	"pause_robot":    RoleAdmin,
+	"resume_robot":   RoleAdmin,
+}
+
+// commandsFor runs the commands of a WebSocket client whose caller has the given role
+func (s *Server) commandsFor(role Role) ws.CommandFunc {
+	return func(command string, params json.RawMessage) (interface{}, error) {
+		required, ok := commandRoles[command]
+		if !ok {
+			required = RoleViewer
+		}
+		if !role.allows(required) {
+			return nil, fmt.Errorf("%s needs the %s role, the caller is %s", command, required, role)
+		}
+		return s.RunCommand(command, params)
+	}
+}
+
+// RunCommand runs a command sent over WebSocket, so clients can act without a REST call.
+// Parameters are validated like the matching REST request.
+func (s *Server) RunCommand(command string, params json.RawMessage) (interface{}, error) {
+	switch command {
+	case "create_order":
+		var req CreateOrderRequest
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+		order, err := s.createOrder(req)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"order": order}, nil
+
+	case "add_robot":
+		var req AddRobotRequest
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+		robot, err := s.Fleet.AddRobot(req.Type, models.Position{X: req.X, Y: req.Y})
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"robot": robot}, nil
+
+	case "remove_robot", "pause_robot", "resume_robot":
+		var req robotCommandParams
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+		var robot *models.Robot
+		var err error
+		switch command {
+		case "remove_robot":
+			robot, err = s.Fleet.RemoveRobot(req.RobotID)
+		default:
+			robot, err = s.pauseRobot(req.RobotID, command == "pause_robot")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("robot %d: %w", req.RobotID, err)
+		}
+		return gin.H{"robot": robot}, nil
+
+	case "create_receipt":
+		var req CreateReceiptRequest
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+		receipt, err := s.createReceipt(req)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"receipt": receipt}, nil
+
+	case "get_analytics":
+		var req struct {
+			Window string `json:"window"` // Rolling window like 15m, an hour when empty
+		}
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+		window := time.Hour
+		if req.Window != "" {
+			d, err := time.ParseDuration(req.Window)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid window %q: expected a positive duration like 15m or 1h", req.Window)
+			}
+			window = d
+		}
+		return s.Analytics.Report(window), nil
+	}
+
+	return nil, fmt.Errorf("unknown command %q", command)
+}
+
+// decodeParams unmarshals command parameters and checks their binding rules
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, v); err != nil {
+			return fmt.Errorf("invalid params: %w", err)
+		}
+	}
+	return binding.Validator.ValidateStruct(v)
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPIDocument is the API contract, served at /api/openapi.json and enforced on requests
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// openAPI is the parsed contract, read once and never changed
+var openAPI = mustParseSpec(openAPIDocument)
+
+// spec is the part of an OpenAPI 3 document requests are validated against
+type spec struct {
+	Paths      map[string]map[string]*operation `json:"paths"` // Path, then lower-case method
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// operation is one method on one path
+type operation struct {
+	OperationID string       `json:"operationId"`
+	Role        Role         `json:"x-role"` // Least role allowed to call it
+	Parameters  []*parameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+// parameter is a path, query or header parameter
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+// schema is the subset of JSON Schema the contract uses
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"` // false forbids unknown properties
+	Items                *schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+}
+
+// mustParseSpec parses the embedded contract, a broken contract is a build mistake
+func mustParseSpec(document []byte) *spec {
+	var s spec
+	if err := json.Unmarshal(document, &s); err != nil {
+		panic(fmt.Sprintf("openapi.json: %v", err))
+	}
+	return &s
+}
+
+// operation finds the operation for a method and a Gin route path like /api/robots/:id
+func (s *spec) operation(method, route string) *operation {
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return s.Paths[strings.Join(segments, "/")][strings.ToLower(method)]
+}
+
+// resolve follows a $ref to the component schema
+func (s *spec) resolve(sc *schema) *schema {
+	for sc != nil && sc.Ref != "" {
+		sc = s.Components.Schemas[strings.TrimPrefix(sc.Ref, "#/components/schemas/")]
+	}
+	return sc
+}
+
+// checkRoutes panics unless every API route is in the contract, every operation has a route
+// and names the role it needs
+func (s *spec) checkRoutes(routes gin.RoutesInfo) {
+	served := make(map[string]bool)
+	var missing []string
+	for _, route := range routes {
+		if route.Path != "/ws" && !strings.HasPrefix(route.Path, "/api/") {
+			continue
+		}
+		op := s.operation(route.Method, route.Path)
+		if op == nil {
+			missing = append(missing, route.Method+" "+route.Path)
+			continue
+		}
+		served[op.OperationID] = true
+		if _, err := ParseRole(string(op.Role)); err != nil {
+			missing = append(missing, route.Method+" "+route.Path+" has no x-role")
+		}
+	}
+	for path, methods := range s.Paths {
+		for method, op := range methods {
+			if !served[op.OperationID] {
+				missing = append(missing, strings.ToUpper(method)+" "+path+" has no route")
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		panic("openapi.json does not match the routes: " + strings.Join(missing, ", "))
+	}
+}
+
+// GetOpenAPI serves the API contract
+func (s *Server) GetOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", openAPIDocument)
+}
+
+// validateRequest checks path, query and body against the route's operation in the contract,
+// answering a mismatch with a problem listing every field at fault
+func (s *Server) validateRequest(c *gin.Context) {
+	op := openAPI.operation(c.Request.Method, c.FullPath())
+	if op == nil {
+		c.Next()
+		return
+	}
+
+	var errs []FieldError
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			openAPI.validateParam(p, []string{c.Param(p.Name)}, &errs)
+		case "query":
+			openAPI.validateParam(p, c.QueryArray(p.Name), &errs)
+		}
+	}
+
+	if op.RequestBody != nil {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			problem(c, http.StatusBadRequest, "reading request body: "+err.Error())
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		openAPI.validateBody(op, body, &errs)
+	}
+
+	if len(errs) > 0 {
+		writeProblem(c, Problem{
+			Status: http.StatusBadRequest,
+			Detail: "the request does not match the API specification",
+			Errors: errs,
+		})
+		return
+	}
+	c.Next()
+}
+
+// validateParam checks the values of a path or query parameter, given as strings
+func (s *spec) validateParam(p *parameter, values []string, errs *[]FieldError) {
+	field := p.In + "." + p.Name
+	if len(values) == 0 || len(values) == 1 && values[0] == "" {
+		if p.Required {
+			*errs = append(*errs, FieldError{Field: field, Message: "is required"})
+		}
+		return
+	}
+
+	sc := s.resolve(p.Schema)
+	if sc == nil {
+		return
+	}
+	if sc.Type == "array" {
+		sc = s.resolve(sc.Items)
+	} else if len(values) > 1 {
+		*errs = append(*errs, FieldError{Field: field, Message: "must be given once"})
+		return
+	}
+	for _, raw := range values {
+		value, ok := parseParam(sc.Type, raw)
+		if !ok {
+			*errs = append(*errs, FieldError{Field: field, Message: "must be " + article(sc.Type)})
+			continue
+		}
+		s.validateValue(sc, value, field, errs)
+	}
+}
+
+// parseParam converts a parameter to the JSON value its schema type describes
+func parseParam(typ, raw string) (interface{}, bool) {
+	switch typ {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, false
+		}
+		return json.Number(raw), true
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		return b, err == nil
+	}
+	return raw, true
+}
+
+// validateBody checks a JSON request body against the operation's schema
+func (s *spec) validateBody(op *operation, body []byte, errs *[]FieldError) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			*errs = append(*errs, FieldError{Field: "body", Message: "is required"})
+		}
+		return
+	}
+
+	content, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		*errs = append(*errs, FieldError{Field: "body", Message: "must be valid JSON: " + err.Error()})
+		return
+	}
+	s.validateValue(content.Schema, value, "body", errs)
+}
+
+// validateValue checks a decoded JSON value against a schema, numbers as json.Number
+func (s *spec) validateValue(sc *schema, value interface{}, field string, errs *[]FieldError) {
+	sc = s.resolve(sc)
+	if sc == nil {
+		return
+	}
+	fail := func(message string) {
+		*errs = append(*errs, FieldError{Field: field, Message: message})
+	}
+
+	switch sc.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range sc.Required {
+			if _, ok := object[name]; !ok {
+				*errs = append(*errs, FieldError{Field: field + "." + name, Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, known := sc.Properties[name]
+			switch {
+			case known:
+				s.validateValue(prop, object[name], field+"."+name, errs)
+			case string(sc.AdditionalProperties) == "false":
+				*errs = append(*errs, FieldError{Field: field + "." + name, Message: "is not a known field"})
+			}
+		}
+		return
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range items {
+			s.validateValue(sc.Items, item, fmt.Sprintf("%s[%d]", field, i), errs)
+		}
+		return
+
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			fail("must be " + article(sc.Type))
+			return
+		}
+		n, err := number.Float64()
+		if err != nil || sc.Type == "integer" && n != float64(int64(n)) {
+			fail("must be " + article(sc.Type))
+			return
+		}
+		if sc.Minimum != nil && n < *sc.Minimum {
+			fail(fmt.Sprintf("must be at least %v", *sc.Minimum))
+		}
+		if sc.Maximum != nil && n > *sc.Maximum {
+			fail(fmt.Sprintf("must be at most %v", *sc.Maximum))
+		}
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if sc.MinLength != nil && len(str) < *sc.MinLength {
+			fail(fmt.Sprintf("must be at least %d characters", *sc.MinLength))
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+			return
+		}
+	}
+
+	if len(sc.Enum) > 0 && !inEnum(sc.Enum, value) {
+		options := make([]string, len(sc.Enum))
+		for i, option := range sc.Enum {
+			options[i] = fmt.Sprint(option)
+		}
+		fail("must be one of " + strings.Join(options, ", "))
+	}
+}
+
+// inEnum reports whether a value is one of the allowed ones
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, option := range enum {
+		if fmt.Sprint(option) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// article names a schema type for messages, e.g. "an integer"
+func article(typ string) string {
+	if typ == "integer" || typ == "object" || typ == "array" {
+		return "an " + typ
+	}
+	return "a " + typ
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testSpec is a contract with an array of referenced objects, which the API itself has no body for yet
+var testSpec = mustParseSpec([]byte(`{
+  "paths": {
+    "/api/shipments": {
+      "post": {
+        "operationId": "createShipment",
+        "x-role": "operator",
+        "parameters": [
+          {"name": "dry_run", "in": "query", "schema": {"type": "boolean"}},
+          {"name": "tag", "in": "query", "schema": {"type": "array", "items": {"$ref": "#/components/schemas/Tag"}}}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Shipment"}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "schemas": {
+      "Tag": {"type": "string", "enum": ["fragile", "cold"]},
+      "Shipment": {
+        "type": "object",
+        "properties": {
+          "carrier": {"type": "string", "minLength": 1},
+          "items": {"type": "array", "items": {"$ref": "#/components/schemas/Line"}}
+        },
+        "required": ["carrier", "items"],
+        "additionalProperties": false
+      },
+      "Line": {
+        "type": "object",
+        "properties": {
+          "product_id": {"type": "integer", "minimum": 1},
+          "quantity": {"type": "integer", "minimum": 1, "maximum": 100}
+        },
+        "required": ["product_id", "quantity"]
+      }
+    }
+  }
+}`))
+
+func TestValidateParam(t *testing.T) {
+	events := openAPI.operation(http.MethodGet, "/api/events")
+	robots := openAPI.operation(http.MethodGet, "/api/robots")
+	removeRobot := openAPI.operation(http.MethodDelete, "/api/robots/:id")
+	tags := testSpec.operation(http.MethodPost, "/api/shipments").Parameters[1]
+
+	tests := []struct {
+		name   string
+		spec   *spec
+		param  *parameter
+		values []string
+		want   []string // field: message
+	}{
+		{"integer", openAPI, param(events, "limit"), []string{"20"}, nil},
+		{"not an integer", openAPI, param(events, "limit"), []string{"ten"}, []string{"query.limit: must be an integer"}},
+		{"fraction", openAPI, param(events, "limit"), []string{"2.5"}, []string{"query.limit: must be an integer"}},
+		{"below minimum", openAPI, param(events, "limit"), []string{"0"}, []string{"query.limit: must be at least 1"}},
+		{"absent", openAPI, param(events, "limit"), nil, nil},
+		{"path integer", openAPI, param(removeRobot, "id"), []string{"x"}, []string{"path.id: must be an integer"}},
+		{"required path", openAPI, param(removeRobot, "id"), []string{""}, []string{"path.id: is required"}},
+		{"enum by $ref", openAPI, param(robots, "status"), []string{"idle"}, nil},
+		{"not in enum", openAPI, param(robots, "status"), []string{"asleep"}, []string{"query.status: must be one of idle, moving, picking, carrying, dropping, returning, storing, waiting, paused, error"}},
+		{"repeated", openAPI, param(robots, "status"), []string{"idle", "moving"}, []string{"query.status: must be given once"}},
+		{"repeated array", openAPI, param(events, "topic"), []string{"robots", "cells"}, nil},
+		{"repeated array of $ref enum", testSpec, tags, []string{"cold", "hot", "wet"}, []string{"query.tag: must be one of fragile, cold", "query.tag: must be one of fragile, cold"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []FieldError
+			tt.spec.validateParam(tt.param, tt.values, &errs)
+			checkErrors(t, errs, tt.want)
+		})
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	createOrder := openAPI.operation(http.MethodPost, "/api/orders")
+	createReceipt := openAPI.operation(http.MethodPost, "/api/receipts")
+	createShipment := testSpec.operation(http.MethodPost, "/api/shipments")
+
+	tests := []struct {
+		name string
+		spec *spec
+		op   *operation
+		body string
+		want []string
+	}{
+		{"valid order", openAPI, createOrder, `{"customer_name":"Ada","product_id":1,"requested_qty":2,"priority":"urgent"}`, nil},
+		{"priority by $ref enum", openAPI, createOrder, `{"customer_name":"Ada","product_id":1,"requested_qty":2,"priority":"asap"}`, []string{"body.priority: must be one of normal, urgent, express"}},
+		{"missing fields", openAPI, createOrder, `{"customer_name":""}`, []string{
+			"body.product_id: is required", "body.requested_qty: is required", "body.customer_name: must be at least 1 characters",
+		}},
+		{"string for integer", openAPI, createReceipt, `{"product_id":"1","quantity":1.5}`, []string{
+			"body.product_id: must be an integer", "body.quantity: must be an integer",
+		}},
+		{"unknown field", openAPI, createReceipt, `{"product_id":1,"quantity":1,"qty":1}`, []string{"body.qty: is not a known field"}},
+		{"not an object", openAPI, createReceipt, `[1]`, []string{"body: must be an object"}},
+		{"invalid JSON", openAPI, createReceipt, `{"product_id":`, []string{"body: must be valid JSON: unexpected EOF"}},
+		{"required body", openAPI, createReceipt, ` `, []string{"body: is required"}},
+		{"valid shipment", testSpec, createShipment, `{"carrier":"DHL","items":[{"product_id":1,"quantity":3}]}`, nil},
+		{"array items by $ref", testSpec, createShipment, `{"carrier":"DHL","items":[{"product_id":1,"quantity":3},{"product_id":0,"quantity":101},{"quantity":"2"}]}`, []string{
+			"body.items[1].product_id: must be at least 1",
+			"body.items[1].quantity: must be at most 100",
+			"body.items[2].product_id: is required",
+			"body.items[2].quantity: must be an integer",
+		}},
+		{"not an array", testSpec, createShipment, `{"carrier":"DHL","items":{"product_id":1}}`, []string{"body.items: must be an array"}},
+		// additionalProperties is not false on Line, unknown fields there are let through
+		{"open nested object", testSpec, createShipment, `{"carrier":"DHL","items":[{"product_id":1,"quantity":1,"note":"x"}],"note":"x"}`, []string{"body.note: is not a known field"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []FieldError
+			tt.spec.validateBody(tt.op, []byte(tt.body), &errs)
+			checkErrors(t, errs, tt.want)
+		})
+	}
+}
+
+func TestValidateRequestProblem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := NewServer(Dependencies{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/orders?unused=1", strings.NewReader(`{"customer_name":"Ada","product_id":0,"requested_qty":1,"rush":true}`))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != problemContentType {
+		t.Fatalf("status %d with %q, want a 400 problem", w.Code, w.Header().Get("Content-Type"))
+	}
+	var p Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Bad Request" || p.Instance != "/api/orders" {
+		t.Errorf("unexpected problem %+v", p)
+	}
+	checkErrors(t, p.Errors, []string{"body.product_id: must be at least 1", "body.rush: is not a known field"})
+
+	req = httptest.NewRequest(http.MethodGet, "/api/orders?status=pending&status=done&priority=low", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	p = Problem{}
+	json.Unmarshal(w.Body.Bytes(), &p)
+	checkErrors(t, p.Errors, []string{"query.status: must be given once", "query.priority: must be one of normal, urgent, express"})
+}
+
+func TestCheckRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	routes := NewServer(Dependencies{}).router.Routes()
+	openAPI.checkRoutes(routes) // Panics when the router and the contract disagree
+
+	undocumented := append(gin.RoutesInfo{{Method: http.MethodGet, Path: "/api/secret"}}, routes...)
+	if msg := recoverPanic(func() { openAPI.checkRoutes(undocumented) }); !strings.Contains(msg, "GET /api/secret") {
+		t.Errorf("undocumented route not reported: %q", msg)
+	}
+	var withoutStatus gin.RoutesInfo
+	for _, route := range routes {
+		if route.Path != "/api/status" {
+			withoutStatus = append(withoutStatus, route)
+		}
+	}
+	if msg := recoverPanic(func() { openAPI.checkRoutes(withoutStatus) }); !strings.Contains(msg, "GET /api/status has no route") {
+		t.Errorf("operation without a route not reported: %q", msg)
+	}
+	noRole := mustParseSpec([]byte(`{"paths": {"/api/status": {"get": {"operationId": "getStatus"}}}}`))
+	if msg := recoverPanic(func() { noRole.checkRoutes(gin.RoutesInfo{{Method: http.MethodGet, Path: "/api/status"}}) }); !strings.Contains(msg, "has no x-role") {
+		t.Errorf("operation without a role not reported: %q", msg)
+	}
+}
+
+// param finds an operation's parameter by name
+func param(op *operation, name string) *parameter {
+	for _, p := range op.Parameters {
+		if p.Name == name {
+			return p
+		}
+	}
+	panic("no parameter " + name)
+}
+
+// checkErrors compares field errors, in order, with "field: message" strings
+func checkErrors(t *testing.T, errs []FieldError, want []string) {
+	t.Helper()
+	got := make([]string, len(errs))
+	for i, e := range errs {
+		got[i] = e.Field + ": " + e.Message
+	}
+	if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", append([]string{}, want...)) {
+		t.Errorf("errors\n  %q\nwant\n  %q", got, want)
+	}
+}
+
+// recoverPanic runs f and returns what it panicked with, empty when it did not
+func recoverPanic(f func()) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprint(r)
+		}
+	}()
+	f()
+	return ""
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// problemContentType is the media type of error responses
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 9457 problem details response, the body of every API error
+type Problem struct {
+	Type     string       `json:"type"` // about:blank, the status says it all
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"` // Request path
+	Errors   []FieldError `json:"errors,omitempty"`   // What failed validation
+}
+
+// FieldError is one part of a request that does not match the API specification
+type FieldError struct {
+	Field   string `json:"field"` // e.g. body.priority, query.limit or path.id
+	Message string `json:"message"`
+}
+
+// problem ends the request with a problem response
+func problem(c *gin.Context, status int, detail string) {
+	writeProblem(c, Problem{Status: status, Detail: detail})
+}
+
+// writeProblem fills in the type, title and instance and ends the request with the problem
+func writeProblem(c *gin.Context, p Problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.Instance = c.Request.URL.Path
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupRouter registers the WebSocket and REST routes on a new Gin engine. It panics when a
+// route is missing from openapi.json or the contract names a route that does not exist.
+func (s *Server) setupRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(requestLogger(), gin.Recovery())
+
+	// Browsers on other origins only get in when allowed
+	r.Use(s.cors)
+
+	// WebSocket route, callers need the role the contract names like on every API route
+	r.GET("/ws", s.authorize, s.HandleWebSocket)
+
+	// Unknown API paths get a problem like every other error, any other path is the web app's
+	r.NoRoute(func(c *gin.Context) {
+		if s.Frontend != nil && !isAPIPath(c.Request.URL.Path) {
+			s.Frontend.ServeHTTP(c.Writer, c.Request)
+			return
+		}
+		problem(c, http.StatusNotFound, "no such endpoint: "+c.Request.Method+" "+c.Request.URL.Path)
+	})
+
+	// API routes, the caller and the request are checked against the OpenAPI contract first
+	api := r.Group("/api", s.authorize, s.validateRequest)
+	{
+		api.GET("/openapi.json", s.GetOpenAPI)
+
+		api.GET("/robots", s.GetRobots)
+		api.GET("/orders", s.GetOrders)
+		api.GET("/workstations", s.GetWorkstations)
+		api.GET("/status", s.GetWarehouseStatus)
+		api.GET("/analytics", s.GetAnalytics)
+		api.GET("/analytics/timeseries", s.GetAnalyticsTimeSeries)
+		api.GET("/layout/cost", s.GetLayoutCost)
+		api.GET("/layout/history", s.GetLayoutHistory)
+
+		api.GET("/bins", s.GetBins)
+		api.GET("/receipts", s.GetReceipts)
+		api.GET("/events", s.GetEvents)
+		api.GET("/traffic", s.GetTraffic)
+		api.GET("/websocket", s.GetWebSocketStats)
+
+		// POST endpoints to create orders and inbound receipts
+		api.POST("/orders", s.CreateOrder)
+		api.POST("/receipts", s.CreateReceipt)
+
+		// Scale the fleet while the warehouse runs
+		api.POST("/robots", s.AddRobot)
+		api.DELETE("/robots/:id", s.RemoveRobot)
+		api.POST("/robots/:id/pause", s.PauseRobot)
+		api.POST("/robots/:id/resume", s.ResumeRobot)
+	}
+
+	openAPI.checkRoutes(r.Routes())
+	return r
+}
+
+// isAPIPath reports whether a path belongs to the API rather than the web app
+func isAPIPath(path string) bool {
+	return path == "/api" || strings.HasPrefix(path, "/api/") || path == "/ws"
+}
+
+// requestLogger is Gin's request log with access tokens redacted from the query
+func requestLogger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(p gin.LogFormatterParams) string {
+		var statusColor, methodColor, resetColor string
+		if p.IsOutputColor() {
+			statusColor, methodColor, resetColor = p.StatusCodeColor(), p.MethodColor(), p.ResetColor()
+		}
+		if p.Latency > time.Minute {
+			p.Latency = p.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			p.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, p.StatusCode, resetColor,
+			p.Latency, p.ClientIP,
+			methodColor, p.Method, resetColor, redactToken(p.Path), p.ErrorMessage)
+	})
+}
+
+// redactToken hides the value of ?access_token= in a logged path
+func redactToken(path string) string {
+	base, rawQuery, ok := strings.Cut(path, "?")
+	if !ok || !strings.Contains(rawQuery, "access_token") {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return base + "?[unparsable query]"
+	}
+	if query.Has("access_token") {
+		query.Set("access_token", "REDACTED")
+	}
+	return base + "?" + query.Encode()
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"autostore-sim/backend/simulation"
+	ws "autostore-sim/backend/websocket"
+)
+
+// SimulationDependencies returns the services an API server for a simulation works on, hub may be nil
+func SimulationDependencies(sim *simulation.Simulation, hub *ws.Hub) Dependencies {
+	return Dependencies{
+		OrderService:   sim.Orders,
+		ProductService: sim.Products,
+		Analytics:      sim.Analytics,
+		Slotting:       sim.Slotting,
+		Receiving:      sim.Receiving,
+		Events:         sim.Events,
+		Inventory:      sim.Inventory,
+		Traffic:        sim.Traffic,
+		Warehouse:      sim.Warehouse,
+		Fleet:          sim,
+		Workstations:   sim.Workstations,
+		Clock:          sim.Clock,
+		WebSocketHub:   hub,
+	}
+}
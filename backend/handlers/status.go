@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"autostore-sim/backend/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WarehouseStatus is the summary GET /api/status returns
+type WarehouseStatus struct {
+	UptimeSeconds    float64               `json:"uptime_seconds"` // Wall-clock time since the server started
+	SimTime          time.Time             `json:"sim_time"`
+	Grid             GridStatus            `json:"grid"`
+	Fleet            FleetStatus           `json:"fleet"`
+	Orders           services.QueueSummary `json:"orders"`
+	Stock            services.StockSummary `json:"stock"`
+	WebSocketClients int                   `json:"websocket_clients"`
+}
+
+// GridStatus describes the grid and how full it is
+type GridStatus struct {
+	Width        int `json:"width"`
+	Height       int `json:"height"`
+	Levels       int `json:"levels"`
+	Compartments int `json:"compartments"` // Per bin
+	Cells        int `json:"cells"`
+	Bins         int `json:"bins"` // Cells holding a bin
+}
+
+// FleetStatus counts robots by status
+type FleetStatus struct {
+	Robots   int            `json:"robots"`
+	ByStatus map[string]int `json:"by_status"`
+	Paused   int            `json:"paused"`
+	Draining int            `json:"draining"` // Leaving the fleet once their queued work is done
+}
+
+// GetWarehouseStatus returns a summary of the warehouse. Each part is read under the lock of
+// the service owning it, so every count is consistent within itself.
+func (s *Server) GetWarehouseStatus(c *gin.Context) {
+	status := WarehouseStatus{
+		UptimeSeconds: time.Since(s.started).Seconds(),
+		Grid:          s.gridStatus(),
+		Fleet:         s.fleetStatus(),
+		Orders:        s.OrderService.Summary(),
+	}
+	if s.Clock != nil {
+		status.SimTime = s.Clock.Now()
+	}
+	if s.Inventory != nil {
+		status.Stock = s.Inventory.Summary()
+	}
+	if s.WebSocketHub != nil {
+		status.WebSocketClients = s.WebSocketHub.ClientCount()
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// gridStatus reads the grid dimensions, fixed once built, and counts bins under the grid lock
+func (s *Server) gridStatus() GridStatus {
+	wh := s.Warehouse
+	return GridStatus{
+		Width:        wh.Width,
+		Height:       wh.Height,
+		Levels:       wh.Levels,
+		Compartments: wh.Compartments,
+		Cells:        wh.Width * wh.Height * wh.Levels,
+		Bins:         wh.BinCount(),
+	}
+}
+
+// fleetStatus counts the active robots from a snapshot of each
+func (s *Server) fleetStatus() FleetStatus {
+	fleet := FleetStatus{ByStatus: make(map[string]int)}
+	for _, robot := range s.Fleet.ActiveRobots() {
+		state := robot.Snapshot()
+		fleet.Robots++
+		fleet.ByStatus[state.Status]++
+		if state.Paused {
+			fleet.Paused++
+		}
+		if state.Draining {
+			fleet.Draining++
+		}
+	}
+	return fleet
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	ws "autostore-sim/backend/websocket"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// streamRetry is the reconnect delay in milliseconds suggested to EventSource clients
+	streamRetry = 2000
+
+	// streamHeartbeat is how often an idle stream gets a comment, so proxies keep it open
+	streamHeartbeat = 15 * time.Second
+)
+
+// wantsEventStream reports whether the client asked for Server-Sent Events
+func wantsEventStream(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), sse.ContentType)
+}
+
+// StreamEvents serves the WebSocket diffs as Server-Sent Events (?topic=robots&topic=robot:3).
+// A reconnecting client sends Last-Event-ID, or ?last_event_id=, and gets the diffs it missed
+// while they are still buffered, otherwise it starts over with a snapshot.
+func (s *Server) StreamEvents(c *gin.Context) {
+	if s.WebSocketHub == nil {
+		problem(c, http.StatusServiceUnavailable, "event stream not available")
+		return
+	}
+	hub := s.WebSocketHub
+
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+	stream, err := hub.OpenStream(lastID, c.QueryArray("topic"))
+	if errors.Is(err, ws.ErrStreamsEnded) {
+		problem(c, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer stream.Close()
+
+	// A retry field without data sets the reconnect delay without dispatching an event
+	sse.Event{}.WriteContentType(c.Writer)
+	c.Header("X-Accel-Buffering", "no") // Keep nginx from buffering the stream
+	c.Status(http.StatusOK)
+	c.Writer.WriteString("retry: " + strconv.Itoa(streamRetry) + "\n\n")
+	for _, event := range stream.Initial {
+		c.Render(-1, sse.Event{Id: event.ID, Data: string(event.Data)})
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, open := <-stream.Events:
+			if !open {
+				return // Fell behind or the hub stopped, the client reconnects and resumes
+			}
+			c.Render(-1, sse.Event{Id: event.ID, Data: string(event.Data)})
+			c.Writer.Flush()
+
+		case <-heartbeat.C:
+			c.Writer.WriteString(": heartbeat\n\n")
+			c.Writer.Flush()
+
+		case <-hub.Ended():
+			return
+
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"autostore-sim/backend/handlers"
+	"autostore-sim/backend/simulation"
+	ws "autostore-sim/backend/websocket"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// TestConcurrentLoad serves a running simulation over HTTP and lets REST clients read state,
+// place orders and scale the fleet while WebSocket and event stream clients read the diffs.
+// Run it with -race: the point is concurrent access to robots, orders and the hub.
+func TestConcurrentLoad(t *testing.T) {
+	if testing.Short() {
+		t.Skip("load test runs for seconds")
+	}
+	gin.SetMode(gin.TestMode)
+
+	cfg := simulation.DefaultConfig()
+	cfg.Robots = 6
+	cfg.OrdersPerHour = 600
+	cfg.ClockSpeed = 200
+	sim, err := simulation.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hub := ws.NewHub()
+	sim.BroadcastTo(hub)
+	server := httptest.NewServer(handlers.NewServer(handlers.SimulationDependencies(sim, hub)))
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	hubDone := make(chan struct{})
+	go func() {
+		hub.Run(hubCtx)
+		close(hubDone)
+	}()
+
+	// The simulation runs until shutdown, so clients at the deadline still find it running
+	sim.Start(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	run := func(name string, f func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f(); err != nil && !endOfRun(err) {
+				t.Errorf("%s: %v", name, err)
+			}
+		}()
+	}
+
+	products := len(sim.Products.GetAllProducts())
+	for i := 0; i < 8; i++ {
+		rng := rand.New(rand.NewSource(int64(i + 1)))
+		run(fmt.Sprintf("REST client %d", i), func() error {
+			for ctx.Err() == nil {
+				if err := randomCall(server.URL, rng, products); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	run("WebSocket reader", func() error { return readDiffs(ctx, server.URL, 0) })
+	run("slow WebSocket reader", func() error { return readDiffs(ctx, server.URL, 50*time.Millisecond) })
+	run("WebSocket churn", func() error {
+		for ctx.Err() == nil {
+			conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL(server.URL), nil)
+			if err != nil {
+				return err
+			}
+			conn.ReadMessage()
+			conn.Close()
+		}
+		return nil
+	})
+	run("event stream", func() error { return readEventStream(ctx, server.URL) })
+
+	<-ctx.Done()
+	wg.Wait()
+	server.Close()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelShutdown()
+	if err := sim.Shutdown(shutdownCtx); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	stopHub()
+	<-hubDone
+
+	if stats := hub.Stats(); stats.Sent == 0 {
+		t.Errorf("hub sent no messages: %+v", stats)
+	}
+}
+
+// endOfRun reports whether err is a client stopping because the run is over: a dial, read or
+// stream timing out at the deadline, or the server closing the WebSocket
+func endOfRun(err error) bool {
+	var netErr net.Error
+	var closeErr *websocket.CloseError
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr) && netErr.Timeout() ||
+		errors.As(err, &closeErr)
+}
+
+// randomCall makes one request a client picks at random and checks its status and JSON body
+func randomCall(base string, rng *rand.Rand, products int) error {
+	method, path, body := http.MethodGet, "", ""
+	ok := []int{http.StatusOK}
+	switch roll := rng.Intn(100); {
+	case roll < 60:
+		path = []string{"/api/robots", "/api/orders", "/api/status", "/api/analytics", "/api/bins", "/api/events", "/api/traffic"}[rng.Intn(7)]
+	case roll < 85:
+		method, path, ok = http.MethodPost, "/api/orders", []int{http.StatusCreated}
+		body = fmt.Sprintf(`{"customer_name": "Load Test", "product_id": %d, "requested_qty": 1}`, rng.Intn(products)+1)
+	case roll < 90:
+		// The grid may be full
+		method, path, ok = http.MethodPost, "/api/robots", []int{http.StatusCreated, http.StatusConflict}
+	case roll < 95:
+		method, path, ok = http.MethodPost, fmt.Sprintf("/api/robots/%d/pause", rng.Intn(6)+1), []int{http.StatusOK, http.StatusNotFound}
+		if rng.Intn(2) == 0 {
+			path = strings.Replace(path, "pause", "resume", 1)
+		}
+	default:
+		// Another client may have removed the robot already, leave the first two to do the work
+		method, path, ok = http.MethodDelete, fmt.Sprintf("/api/robots/%d", rng.Intn(8)+3), []int{http.StatusAccepted, http.StatusNotFound, http.StatusConflict}
+	}
+
+	req, err := http.NewRequest(method, base+path, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			if !json.Valid(data) {
+				return fmt.Errorf("%s %s: invalid JSON: %.200s", method, path, data)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %s returned %d: %.200s", method, path, resp.StatusCode, data)
+}
+
+// wsURL is the WebSocket address of a test server
+func wsURL(base string) string {
+	return "ws" + strings.TrimPrefix(base, "http") + "/ws"
+}
+
+// readDiffs reads a WebSocket until ctx ends, pausing after every frame when delay is set. The
+// first message must be a snapshot and diffs must be numbered on from it; a slow client may get
+// a fresh snapshot instead, which restarts the count.
+func readDiffs(ctx context.Context, base string, delay time.Duration) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL(base), nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.SetReadDeadline(time.Now())
+	}()
+
+	var last uint64
+	synced := false
+	for {
+		_, frame, err := conn.ReadMessage()
+		if websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+			return nil // Fell behind while catching up, the hub gave up on it
+		}
+		if err != nil {
+			return err
+		}
+		time.Sleep(delay)
+
+		// JSON clients get everything queued as one frame, a message per line
+		for _, line := range bytes.Split(frame, []byte{'\n'}) {
+			var message struct {
+				Type string `json:"type"`
+				Seq  uint64 `json:"seq"`
+			}
+			if err := json.Unmarshal(line, &message); err != nil {
+				return fmt.Errorf("invalid JSON: %.200s", line)
+			}
+			switch {
+			case message.Type == "snapshot":
+				last, synced = message.Seq, true
+			case message.Seq == 0:
+				// Responses are not numbered
+			case !synced:
+				return fmt.Errorf("%s before the snapshot", message.Type)
+			case message.Seq != last+1:
+				return fmt.Errorf("%s has seq %d after %d", message.Type, message.Seq, last)
+			default:
+				last = message.Seq
+			}
+		}
+	}
+}
+
+// readEventStream reads Server-Sent Events until ctx ends, every data line must be JSON
+func readEventStream(ctx context.Context, base string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/api/events", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	// Only whole lines are checked, the one cut off at the deadline ends the read with its error
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if data, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), "data:"); ok && !json.Valid([]byte(data)) {
+			return fmt.Errorf("invalid JSON: %.200s", data)
+		}
+	}
+}
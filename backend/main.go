@@ -3,51 +3,77 @@ package main
 import (
 	"autostore-sim/backend/handlers"
 	"autostore-sim/backend/models"
-	"autostore-sim/backend/services"
+	"autostore-sim/backend/simulation"
 	ws "autostore-sim/backend/websocket"
+	"autostore-sim/frontend"
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
-
-	"github.com/gin-gonic/gin"
 )
 
+// shutdownTimeout bounds how long robots and open requests get to finish after a signal
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	fmt.Println("Starting AutoStore Warehouse Simulation")
 
-	// Create thread-safe warehouse
-	safeWarehouse := models.GetDefaultSafeWarehouse()
+	// SIGINT or SIGTERM ends ctx, which stops order intake and the robots
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Create and load products
-	productService := services.NewProductService()
-	if err := productService.LoadProductsFromFile("products.json"); err != nil {
-		fmt.Printf("Error loading products: %v\n", err)
+	// API keys, token secret and allowed origins come from the environment
+	auth, err := handlers.AuthConfigFromEnv()
+	if err != nil {
+		fmt.Printf("Error reading API access settings: %v\n", err)
 		return
 	}
+	if len(auth.APIKeys) == 0 && len(auth.JWTSecret) == 0 {
+		fmt.Println("WARNING: no AUTOSTORE_API_KEYS or AUTOSTORE_JWT_SECRET set, every caller is an admin")
+	}
 
-	// Place products randomly in warehouse
-	if err := productService.PlaceProductsInWarehouse(safeWarehouse); err != nil {
-		fmt.Printf("Error placing products: %v\n", err)
+	// Build warehouse, products, services and robots
+	sim, err := simulation.New(simulation.DefaultConfig())
+	if err != nil {
+		fmt.Printf("Error creating simulation: %v\n", err)
 		return
 	}
+	robots := sim.Robots
 
 	fmt.Printf("Successfully loaded %d products into warehouse\n",
-		productService.GetProductCount())
+		sim.Products.GetProductCount())
 
-	// Create robots using pointers for goroutines
-	robots := []*models.Robot{
-		&models.Robot{ID: 1, X: 0, Y: 0, Z: 0, Status: "idle", BroadcastUpdate: nil}, // Will be set after hub creation
-		&models.Robot{ID: 2, X: 1, Y: 1, Z: 0, Status: "idle", BroadcastUpdate: nil},
-		&models.Robot{ID: 3, X: 2, Y: 2, Z: 0, Status: "idle", BroadcastUpdate: nil},
+	// Broadcast robot updates to WebSocket clients, the hub outlives the simulation so clients see the robots stop
+	hub := ws.NewHub()
+	sim.BroadcastTo(hub)
+
+	// The API server owns the services it serves and answers the hub's snapshots and commands
+	deps := handlers.SimulationDependencies(sim, hub)
+	deps.Auth = auth
+	deps.Frontend = frontend.Handler()
+	if vite := os.Getenv("AUTOSTORE_FRONTEND_DEV"); vite != "" {
+		// Development: the Vite dev server serves the app with hot reload
+		if deps.Frontend, err = frontend.DevProxy(vite); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Proxying the web app to %s\n", vite)
 	}
-
-	// Create done channel for graceful shutdown
-	done := make(chan bool)
-
-	// Start robot goroutines
+	api := handlers.NewServer(deps)
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	hubDone := make(chan struct{})
+	go func() {
+		hub.Run(hubCtx)
+		close(hubDone)
+	}()
+
+	// Start robot goroutines and the order processor
 	fmt.Println("Starting robot goroutines:")
-	for _, robot := range robots {
-		robot.StartRobot(safeWarehouse, done)
-	}
+	sim.Start(ctx)
 
 	// Display initial state
 	fmt.Println("Initial robot positions:")
@@ -69,74 +95,47 @@ func main() {
 
 	fmt.Println("\nRobot positions after movement:")
 	for _, robot := range robots {
+		state := robot.Snapshot()
 		fmt.Printf("Robot %d at (%d, %d, %d) - Status: %s\n",
-			robot.ID, robot.X, robot.Y, robot.Z, robot.Status)
-	}
-
-	// Create OrderService
-	orderService := services.NewOrderService(productService, safeWarehouse)
-
-	// Create workstations (example positions at delivery ports)
-	workstations := []models.Workstation{
-		{ID: 1, X: 0, Y: 0, Status: "idle"},
-		{ID: 2, X: 7, Y: 0, Status: "idle"},
-	}
-
-	// Initialize WebSocket hub
-	hub := ws.NewHub()
-	go hub.Run()
-
-	// Set up broadcast callback for all robots
-	for _, robot := range robots {
-		robot.BroadcastUpdate = func(update models.RobotUpdate) {
-			handlers.BroadcastRobotUpdate(update)
-		}
+			state.ID, state.X, state.Y, state.Z, state.Status)
 	}
 
-	// Initialize API handlers with all dependencies
-	handlers.InitializeServer(orderService, productService, safeWarehouse, robots, workstations, hub)
-
 	fmt.Println("Warehouse is running!")
-	fmt.Println("API available at http://localhost:8080")
-
-	// Start order processor in background
-	go startOrderProcessor()
+	fmt.Println("Web app and API available at http://localhost:8080")
 
 	// Start web server in a separate goroutine
-	go startWebServer()
+	srv := &http.Server{Addr: ":8080", Handler: api}
+	srv.RegisterOnShutdown(hub.EndStreams) // Event streams never finish on their own
+	go startWebServer(srv, stop)
 
-	// Keep main running
-	select {}
-}
+	<-ctx.Done()
+	stop()
+	fmt.Println("\nShutting down, press Ctrl+C again to force")
 
-// startOrderProcessor runs in a goroutine and processes pending orders periodically
-func startOrderProcessor() {
-	ticker := time.NewTicker(3 * time.Second)
-	defer ticker.Stop()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	for range ticker.C {
-		// Process orders directly on warehouse data
-		handlers.ProcessWarehouseOrders()
+	// No new requests, in-flight ones finish
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		fmt.Printf("Web server shutdown: %v\n", err)
 	}
-}
-
-func startWebServer() {
-	r := gin.Default()
-
-	// WebSocket route
-	r.GET("/ws", handlers.HandleWebSocket)
+	// Robots finish or abandon the command they are on
+	if err := sim.Shutdown(shutdownCtx); err != nil {
+		fmt.Printf("Simulation shutdown: %v\n", err)
+	}
+	// Robot updates and events are forwarded as they happen, this is the last one clients get
+	sim.Events.Record(models.Event{Type: models.EventShutdown, Message: "Warehouse stopped"})
+	stopHub()
+	<-hubDone
 
-	// API routes
-	api := r.Group("/api")
-	{
-		api.GET("/robots", handlers.GetRobots)
-		api.GET("/orders", handlers.GetOrders)
-		api.GET("/workstations", handlers.GetWorkstations)
-		api.GET("/status", handlers.GetWarehouseStatus)
+	fmt.Println("Warehouse stopped")
+}
 
-		// POST endpoint to create orders
-		api.POST("/orders", handlers.CreateOrder)
-	}
+// startWebServer serves the API until Shutdown, a failure to listen stops the warehouse
+func startWebServer(srv *http.Server, stop context.CancelFunc) {
 	fmt.Println("Web server starting on :8080")
-	r.Run(":8080")
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Web server error: %v\n", err)
+		stop()
+	}
 }
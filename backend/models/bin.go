@@ -0,0 +1,226 @@
+package models
+
+import (
+	"fmt"
+	"math"
+)
+
+// Standard bin limits
+const (
+	BinVolumeLitres = 65.0 // Usable volume of a 330mm bin
+	MaxBinWeightKg  = 30.0 // Payload a robot can lift
+)
+
+// capacityEpsilon keeps float rounding from costing a unit of capacity, e.g. 30kg / 0.3kg
+const capacityEpsilon = 1e-9
+
+// ValidCompartmentCounts lists the compartment layouts bins can be divided into
+var ValidCompartmentCounts = []int{1, 2, 4, 8}
+
+// Compartment is one division of a bin holding a single SKU
+type Compartment struct {
+	ProductID int `json:"product_id"` // 0 when the compartment was never filled
+	Quantity  int `json:"quantity"`
+}
+
+// StorageCell reprensents a bin in the warehouse capable of carrying product inventory in it
+type StorageCell struct {
+	BinID        string        `json:"bin_id"`       // Unique bin identifier
+	Compartments []Compartment `json:"compartments"` // Equal divisions, each holding one product
+}
+
+// ProductLookup resolves product IDs to products, e.g. ProductService.GetProductByID
+type ProductLookup func(id int) *Product
+
+// NewBin creates an empty bin divided into the given number of compartments
+func NewBin(binID string, compartments int) StorageCell {
+	if compartments < 1 {
+		compartments = 1
+	}
+	return StorageCell{
+		BinID:        binID,
+		Compartments: make([]Compartment, compartments),
+	}
+}
+
+// IsValidCompartmentCount reports whether bins can be divided into n compartments
+func IsValidCompartmentCount(n int) bool {
+	for _, valid := range ValidCompartmentCounts {
+		if n == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEmpty checks if the the storage cell has no inventory
+func (sc StorageCell) IsEmpty() bool {
+	for _, c := range sc.Compartments {
+		if c.Quantity > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// HasProduct checks if cell contains a specific product
+func (sc StorageCell) HasProduct(productID int) bool {
+	return sc.QuantityOf(productID) > 0
+}
+
+// QuantityOf totals a product's units over all compartments
+func (sc StorageCell) QuantityOf(productID int) int {
+	total := 0
+	for _, c := range sc.Compartments {
+		if c.ProductID == productID {
+			total += c.Quantity
+		}
+	}
+	return total
+}
+
+// CanFulfill checks if cell has enough quantity for an order
+func (sc StorageCell) CanFulfill(productID int, requestedQty int) bool {
+	return sc.QuantityOf(productID) >= requestedQty
+}
+
+// ProductIDs returns the products with stock in the bin
+func (sc StorageCell) ProductIDs() []int {
+	var ids []int
+	for _, c := range sc.Compartments {
+		if c.Quantity > 0 {
+			ids = append(ids, c.ProductID)
+		}
+	}
+	return ids
+}
+
+// CompartmentVolume returns the volume of one compartment in litres
+func (sc StorageCell) CompartmentVolume() float64 {
+	if len(sc.Compartments) == 0 {
+		return 0
+	}
+	return BinVolumeLitres / float64(len(sc.Compartments))
+}
+
+// Weight returns the total weight of the bin contents in kg
+func (sc StorageCell) Weight(lookup ProductLookup) float64 {
+	weight := 0.0
+	for _, c := range sc.Compartments {
+		if c.Quantity == 0 {
+			continue
+		}
+		if product := lookup(c.ProductID); product != nil {
+			weight += float64(c.Quantity) * product.Weight
+		}
+	}
+	return weight
+}
+
+// Room returns how many more units of product fit, by compartment volume and bin weight.
+// Products go into the compartment already holding them, otherwise an empty one.
+func (sc StorageCell) Room(product *Product, lookup ProductLookup) int {
+	index := sc.compartmentFor(product.ID)
+	if index < 0 {
+		return 0
+	}
+	return sc.roomIn(index, product, lookup)
+}
+
+// Add puts up to quantity units of product into the bin and returns how many fit
+func (sc *StorageCell) Add(product *Product, quantity int, lookup ProductLookup) int {
+	index := sc.compartmentFor(product.ID)
+	if index < 0 || quantity <= 0 {
+		return 0
+	}
+
+	added := quantity
+	if room := sc.roomIn(index, product, lookup); added > room {
+		added = room
+	}
+	if added <= 0 {
+		return 0
+	}
+	sc.Compartments[index].ProductID = product.ID
+	sc.Compartments[index].Quantity += added
+	return added
+}
+
+// AddToCompartment fills a specific compartment, e.g. from a layout file
+func (sc *StorageCell) AddToCompartment(index int, product *Product, quantity int, lookup ProductLookup) (int, error) {
+	if index < 0 || index >= len(sc.Compartments) {
+		return 0, fmt.Errorf("compartment %d does not exist in a %d-compartment bin", index, len(sc.Compartments))
+	}
+	c := sc.Compartments[index]
+	if c.Quantity > 0 && c.ProductID != product.ID {
+		return 0, fmt.Errorf("compartment %d already holds product %d", index, c.ProductID)
+	}
+
+	added := quantity
+	if room := sc.roomIn(index, product, lookup); added > room {
+		added = room
+	}
+	if added < 0 {
+		added = 0
+	}
+	sc.Compartments[index].ProductID = product.ID
+	sc.Compartments[index].Quantity += added
+	return added, nil
+}
+
+// Take removes up to quantity units of a product and returns how many were taken
+func (sc *StorageCell) Take(productID, quantity int) int {
+	taken := 0
+	for i := range sc.Compartments {
+		c := &sc.Compartments[i]
+		if c.ProductID != productID || c.Quantity == 0 {
+			continue
+		}
+		n := quantity - taken
+		if n > c.Quantity {
+			n = c.Quantity
+		}
+		c.Quantity -= n
+		taken += n
+		if taken == quantity {
+			break
+		}
+	}
+	return taken
+}
+
+// compartmentFor returns the compartment holding product, else the first empty one, else -1
+func (sc StorageCell) compartmentFor(productID int) int {
+	empty := -1
+	for i, c := range sc.Compartments {
+		if c.ProductID == productID && c.Quantity > 0 {
+			return i
+		}
+		if c.Quantity == 0 && empty < 0 {
+			empty = i
+		}
+	}
+	return empty
+}
+
+// roomIn returns how many more units of product fit into compartment index
+func (sc StorageCell) roomIn(index int, product *Product, lookup ProductLookup) int {
+	room := math.MaxInt32
+	if product.Volume > 0 {
+		used := 0.0
+		if c := sc.Compartments[index]; c.ProductID == product.ID {
+			used = float64(c.Quantity) * product.Volume
+		}
+		room = int((sc.CompartmentVolume()-used)/product.Volume + capacityEpsilon)
+	}
+	if product.Weight > 0 {
+		byWeight := int((MaxBinWeightKg-sc.Weight(lookup))/product.Weight + capacityEpsilon)
+		if byWeight < room {
+			room = byWeight
+		}
+	}
+	if room < 0 {
+		return 0
+	}
+	return room
+}
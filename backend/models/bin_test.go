@@ -0,0 +1,125 @@
+package models
+
+import "testing"
+
+// testProducts are the products bin tests fill compartments with
+var testProducts = map[int]*Product{
+	1: {ID: 1, Volume: 10, Weight: 1}, // Six fill a bin by volume, 30 by weight
+	2: {ID: 2, Volume: 0.5, Weight: 5},
+	3: {ID: 3, Volume: 0.1, Weight: 0.1},
+}
+
+func lookupTestProduct(id int) *Product { return testProducts[id] }
+
+// filledBin returns a bin whose compartments hold the given product and quantity pairs, in order
+func filledBin(compartments int, contents ...Compartment) StorageCell {
+	bin := NewBin("BIN", compartments)
+	copy(bin.Compartments, contents)
+	return bin
+}
+
+func TestBinRoom(t *testing.T) {
+	tests := []struct {
+		name    string
+		bin     StorageCell
+		product Product
+		want    int
+	}{
+		// Compartment volume, 65 litres split into 1 or 4
+		{"volume, exactly full", filledBin(1), Product{ID: 9, Volume: 13}, 5},
+		{"volume, just over", filledBin(1), Product{ID: 9, Volume: 13.01}, 4},
+		{"quarter compartment, exactly full", filledBin(4), Product{ID: 9, Volume: 16.25}, 1},
+		{"quarter compartment, just over", filledBin(4), Product{ID: 9, Volume: 16.26}, 0},
+		{"own compartment, one unit left", filledBin(1, Compartment{ProductID: 1, Quantity: 5}), *testProducts[1], 1},
+		{"own compartment, full", filledBin(1, Compartment{ProductID: 1, Quantity: 6}), *testProducts[1], 0},
+		// Another product's compartment takes no volume, but counts for weight
+		{"other compartment", filledBin(2, Compartment{ProductID: 2, Quantity: 1}), *testProducts[1], 3},
+		{"no free compartment", filledBin(2, Compartment{ProductID: 2, Quantity: 1}, Compartment{ProductID: 3, Quantity: 1}), *testProducts[1], 0},
+
+		// MaxBinWeightKg, 30 kg over all compartments
+		{"weight, exactly full", filledBin(1), Product{ID: 9, Weight: 3}, 10},
+		{"weight, just over", filledBin(1), Product{ID: 9, Weight: 3.01}, 9},
+		{"weight of other compartments, exactly full", filledBin(2, Compartment{ProductID: 2, Quantity: 5}), Product{ID: 9, Weight: 5}, 1},
+		{"weight of other compartments, just over", filledBin(2, Compartment{ProductID: 2, Quantity: 5}), Product{ID: 9, Weight: 5.01}, 0},
+		{"overweight", filledBin(2, Compartment{ProductID: 2, Quantity: 7}), Product{ID: 9, Weight: 1}, 0},
+
+		// capacityEpsilon: (30 - 0.1) / 0.1 is 298.99999999999994 in floating point
+		{"rounding, exactly full", filledBin(1, Compartment{ProductID: 3, Quantity: 1}), *testProducts[3], 299},
+		{"rounding, just over", filledBin(1), Product{ID: 9, Weight: 0.1000001}, 299},
+		{"unlimited by volume", filledBin(1), Product{ID: 9, Weight: 30}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.bin.Room(&tt.product, lookupTestProduct); got != tt.want {
+				t.Errorf("room %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBinAdd(t *testing.T) {
+	tests := []struct {
+		name     string
+		bin      StorageCell
+		product  *Product
+		quantity int
+		want     int
+		holds    int // Units of the product in the bin afterwards
+	}{
+		{"up to the volume limit", filledBin(1), testProducts[1], 6, 6, 6},
+		{"one over the volume limit", filledBin(1), testProducts[1], 7, 6, 6},
+		{"up to the weight limit", filledBin(1), testProducts[2], 6, 6, 6},
+		{"one over the weight limit", filledBin(1), testProducts[2], 7, 6, 6},
+		{"into a full compartment", filledBin(1, Compartment{ProductID: 1, Quantity: 6}), testProducts[1], 1, 0, 6},
+		{"rounding at the limit", filledBin(1, Compartment{ProductID: 3, Quantity: 1}), testProducts[3], 300, 299, 300},
+		{"nothing", filledBin(1), testProducts[1], 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin := tt.bin
+			if got := bin.Add(tt.product, tt.quantity, lookupTestProduct); got != tt.want {
+				t.Errorf("added %d, want %d", got, tt.want)
+			}
+			if got := bin.QuantityOf(tt.product.ID); got != tt.holds {
+				t.Errorf("bin holds %d, want %d", got, tt.holds)
+			}
+			if weight := bin.Weight(lookupTestProduct); weight > MaxBinWeightKg+capacityEpsilon {
+				t.Errorf("bin weighs %v kg", weight)
+			}
+		})
+	}
+}
+
+func TestBinTake(t *testing.T) {
+	tests := []struct {
+		name     string
+		bin      StorageCell
+		quantity int
+		want     int
+		left     int
+	}{
+		{"some", filledBin(1, Compartment{ProductID: 1, Quantity: 5}), 3, 3, 2},
+		{"all", filledBin(1, Compartment{ProductID: 1, Quantity: 5}), 5, 5, 0},
+		{"one more than held", filledBin(1, Compartment{ProductID: 1, Quantity: 5}), 6, 5, 0},
+		{"across compartments", filledBin(2, Compartment{ProductID: 1, Quantity: 2}, Compartment{ProductID: 1, Quantity: 3}), 4, 4, 1},
+		{"none held", filledBin(1, Compartment{ProductID: 2, Quantity: 5}), 1, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin := tt.bin
+			if got := bin.Take(1, tt.quantity); got != tt.want {
+				t.Errorf("took %d, want %d", got, tt.want)
+			}
+			if got := bin.QuantityOf(1); got != tt.left {
+				t.Errorf("%d left, want %d", got, tt.left)
+			}
+		})
+	}
+
+	// An emptied compartment takes another product
+	bin := filledBin(1, Compartment{ProductID: 1, Quantity: 1})
+	bin.Take(1, 1)
+	if added := bin.Add(testProducts[2], 1, lookupTestProduct); added != 1 {
+		t.Errorf("emptied compartment took %d units of another product", added)
+	}
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Clock provides simulation time so runs can be accelerated
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) *time.Ticker
+}
+
+// minWallInterval keeps scaled tickers valid at very high clock speeds
+const minWallInterval = 100 * time.Microsecond
+
+// ScaledClock runs simulation time a fixed factor faster than wall time
+type ScaledClock struct {
+	speed     float64
+	wallStart time.Time
+	simStart  time.Time
+}
+
+// NewScaledClock creates a clock running speed times faster than wall time
+func NewScaledClock(speed float64) *ScaledClock {
+	if speed <= 0 {
+		speed = 1
+	}
+	now := time.Now()
+	return &ScaledClock{
+		speed:     speed,
+		wallStart: now,
+		simStart:  now,
+	}
+}
+
+// RealClock returns a clock running at wall-clock speed
+func RealClock() Clock {
+	return NewScaledClock(1)
+}
+
+// Speed returns how many simulated seconds pass per wall-clock second
+func (c *ScaledClock) Speed() float64 {
+	return c.speed
+}
+
+// Now returns the current simulation time
+func (c *ScaledClock) Now() time.Time {
+	elapsed := time.Since(c.wallStart)
+	return c.simStart.Add(time.Duration(float64(elapsed) * c.speed))
+}
+
+// Sleep blocks for d of simulation time
+func (c *ScaledClock) Sleep(d time.Duration) {
+	time.Sleep(c.toWall(d))
+}
+
+// After returns a channel that fires after d of simulation time
+func (c *ScaledClock) After(d time.Duration) <-chan time.Time {
+	return time.After(c.toWall(d))
+}
+
+// NewTicker returns a ticker firing every d of simulation time
+func (c *ScaledClock) NewTicker(d time.Duration) *time.Ticker {
+	wall := c.toWall(d)
+	if wall < minWallInterval {
+		wall = minWallInterval
+	}
+	return time.NewTicker(wall)
+}
+
+// toWall converts a simulation duration to wall-clock time
+func (c *ScaledClock) toWall(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / c.speed)
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Event is a notable occurrence in the warehouse, kept in the event log and pushed to clients
+type Event struct {
+	ID        int       `json:"id"`
+	Type      EventType `json:"type"`
+	Time      time.Time `json:"time"`
+	Message   string    `json:"message"`
+	ProductID int       `json:"product_id,omitempty"`
+	Stock     int       `json:"stock"`                // Units on hand when the event was raised
+	Inbound   int       `json:"inbound"`              // Units on open receipts
+	ReceiptID int       `json:"receipt_id,omitempty"` // Receipt raised in response, if any
+}
+
+// EventType classifies events
+type EventType string
+
+const (
+	EventLowStock      EventType = "low_stock"      // Stock at or below the reorder point
+	EventOutOfStock    EventType = "out_of_stock"   // No units left anywhere in the grid
+	EventStockRestored EventType = "stock_restored" // Back above the reorder point
+	EventReorder       EventType = "reorder"        // Replenishment receipt raised automatically
+	EventShutdown      EventType = "shutdown"       // Warehouse stopped, the last event clients receive
+)
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Grid geometry of a standard AutoStore grid
+const (
+	CellWidthMeters = 0.705 // 705mm wide direction (X)
+	CellDepthMeters = 0.480 // 480mm narrow direction (Y)
+	BinHeightMeters = 0.330 // 330mm bins
+)
+
+// Stops between straight runs
+const (
+	DirectionChangeTime = 300 * time.Millisecond // Settle after braking before moving off again
+	WheelSwitchTime     = 1 * time.Second        // Lower one wheel set and raise the other to change track
+)
+
+// Axis is the track direction a robot drives along, each has its own set of wheels
+type Axis int
+
+const (
+	AxisNone Axis = iota // Robot has not moved yet, either wheel set can be down
+	AxisX
+	AxisY
+)
+
+// MotionProfile describes a trapezoidal velocity profile: accelerate, cruise, decelerate
+type MotionProfile struct {
+	MaxSpeed     float64 // m/s
+	Acceleration float64 // m/s²
+	Deceleration float64 // m/s²
+}
+
+// LiftAcceleration is how fast the gripper winch gets up to speed, m/s²
+const LiftAcceleration = 2.0
+
+// Energy model
+const (
+	RollingResistance = 0.02 // Wheels on aluminium rails
+	MotorEfficiency   = 0.7  // Battery to motion, no regenerative braking
+	gravity           = 9.81 // m/s²
+)
+
+// DriveEnergyWh estimates the battery energy for one leg: rolling losses plus getting the mass up to speed
+func DriveEnergyWh(massKg, distance, speed float64) float64 {
+	joules := massKg*gravity*RollingResistance*distance + 0.5*massKg*speed*speed
+	return joules / MotorEfficiency / 3600
+}
+
+// LiftEnergyWh estimates the battery energy to raise a payload through height metres
+func LiftEnergyWh(massKg, height float64) float64 {
+	return massKg * gravity * height / MotorEfficiency / 3600
+}
+
+// Time returns how long a stop-to-stop move over distance metres takes.
+// Short moves never reach MaxSpeed and follow a triangular profile instead.
+func (p MotionProfile) Time(distance float64) time.Duration {
+	if distance <= 0 || p.MaxSpeed <= 0 || p.Acceleration <= 0 || p.Deceleration <= 0 {
+		return 0
+	}
+
+	accelDistance := p.MaxSpeed * p.MaxSpeed / (2 * p.Acceleration)
+	decelDistance := p.MaxSpeed * p.MaxSpeed / (2 * p.Deceleration)
+
+	var seconds float64
+	if distance >= accelDistance+decelDistance {
+		cruise := distance - accelDistance - decelDistance
+		seconds = p.MaxSpeed/p.Acceleration + cruise/p.MaxSpeed + p.MaxSpeed/p.Deceleration
+	} else {
+		peak := math.Sqrt(2 * distance * p.Acceleration * p.Deceleration / (p.Acceleration + p.Deceleration))
+		seconds = peak/p.Acceleration + peak/p.Deceleration
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Leg is a straight run along one axis, the robot stops at both ends
+type Leg struct {
+	Axis Axis
+	From Position
+	To   Position
+}
+
+// Distance returns the length of the leg in metres
+func (l Leg) Distance() float64 {
+	if l.Axis == AxisX {
+		return float64(abs(l.To.X-l.From.X)) * CellWidthMeters
+	}
+	return float64(abs(l.To.Y-l.From.Y)) * CellDepthMeters
+}
+
+// Cells returns every cell the leg passes over, both ends included
+func (l Leg) Cells() []Position {
+	cells := []Position{{X: l.From.X, Y: l.From.Y}}
+	x, y := l.From.X, l.From.Y
+	for x != l.To.X || y != l.To.Y {
+		switch {
+		case x < l.To.X:
+			x++
+		case x > l.To.X:
+			x--
+		case y < l.To.Y:
+			y++
+		default:
+			y--
+		}
+		cells = append(cells, Position{X: x, Y: y})
+	}
+	return cells
+}
+
+// PlanRoute returns the horizontal legs from one cell to another with the fewest turns.
+// When a turn is needed the route starts on the current axis, saving a wheel switch.
+func PlanRoute(from, to Position, current Axis) []Leg {
+	dx := from.X != to.X
+	dy := from.Y != to.Y
+
+	switch {
+	case dx && !dy:
+		return []Leg{{Axis: AxisX, From: from, To: to}}
+	case dy && !dx:
+		return []Leg{{Axis: AxisY, From: from, To: to}}
+	case !dx && !dy:
+		return nil
+	}
+
+	if current == AxisY {
+		corner := Position{X: from.X, Y: to.Y, Z: from.Z}
+		return []Leg{{Axis: AxisY, From: from, To: corner}, {Axis: AxisX, From: corner, To: to}}
+	}
+	corner := Position{X: to.X, Y: from.Y, Z: from.Z}
+	return []Leg{{Axis: AxisX, From: from, To: corner}, {Axis: AxisY, From: corner, To: to}}
+}
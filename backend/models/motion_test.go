@@ -0,0 +1,102 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMotionProfileTime(t *testing.T) {
+	// Reaches 2 m/s after 2 m and stops from it in 1 m, so moves under 3 m never cruise
+	profile := MotionProfile{MaxSpeed: 2, Acceleration: 1, Deceleration: 2}
+
+	tests := []struct {
+		name     string
+		profile  MotionProfile
+		distance float64
+		want     time.Duration
+	}{
+		// Peaks at √2 m/s: √2 s accelerating, √2/2 s braking
+		{"short, triangle", profile, 1.5, 2121320 * time.Microsecond},
+		{"just reaching top speed", profile, 3, 3 * time.Second},
+		// 2 s accelerating, 10 m at 2 m/s, 1 s braking
+		{"long, trapezoid", profile, 13, 8 * time.Second},
+		{"standing still", profile, 0, 0},
+		{"cannot move", MotionProfile{MaxSpeed: 2}, 13, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.profile.Time(tt.distance)
+			if diff := got - tt.want; diff < -time.Microsecond || diff > time.Microsecond {
+				t.Errorf("time %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// routeTime is how long a robot on axis current drives legs, stopping and switching wheels at turns
+func routeTime(legs []Leg, current Axis) time.Duration {
+	profile := StandardRobot.DriveProfile()
+	var d time.Duration
+	for _, leg := range legs {
+		d += profile.Time(leg.Distance())
+		if current != AxisNone && current != leg.Axis {
+			d += DirectionChangeTime + WheelSwitchTime
+		}
+		current = leg.Axis
+	}
+	return d
+}
+
+func TestPlanRoute(t *testing.T) {
+	origin := Position{X: 1, Y: 1}
+	tests := []struct {
+		name    string
+		to      Position
+		current Axis
+		want    string
+	}{
+		{"along X", Position{X: 4, Y: 1}, AxisY, "[{1 {1 1 0} {4 1 0}}]"},
+		{"along Y", Position{X: 1, Y: 0}, AxisX, "[{2 {1 1 0} {1 0 0}}]"},
+		{"same cell", origin, AxisX, "[]"},
+		{"L, X first", Position{X: 4, Y: 3}, AxisNone, "[{1 {1 1 0} {4 1 0}} {2 {4 1 0} {4 3 0}}]"},
+		{"L, on the current axis", Position{X: 4, Y: 3}, AxisY, "[{2 {1 1 0} {1 3 0}} {1 {1 3 0} {4 3 0}}]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fmt.Sprint(PlanRoute(origin, tt.to, tt.current)); got != tt.want {
+				t.Errorf("legs %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPlanRouteFewestTurns compares the planned L with a staircase over the same cells and
+// with the L that starts on the other axis
+func TestPlanRouteFewestTurns(t *testing.T) {
+	from, to := Position{X: 0, Y: 0}, Position{X: 3, Y: 3}
+
+	l := PlanRoute(from, to, AxisY)
+	var staircase []Leg
+	for pos := from; pos != to; {
+		next := Position{X: pos.X + 1, Y: pos.Y}
+		staircase = append(staircase, Leg{Axis: AxisX, From: pos, To: next})
+		pos = Position{X: next.X, Y: next.Y + 1}
+		staircase = append(staircase, Leg{Axis: AxisY, From: next, To: pos})
+	}
+
+	// Both cover 3 cells each way, the staircase stops and turns at every cell
+	lTime, staircaseTime := routeTime(l, AxisY), routeTime(staircase, AxisY)
+	if len(l) != 2 || lTime >= staircaseTime {
+		t.Errorf("L of %d legs takes %v, staircase of %d legs %v", len(l), lTime, len(staircase), staircaseTime)
+	}
+	if minTurns := 5 * (DirectionChangeTime + WheelSwitchTime); staircaseTime-lTime < minTurns {
+		t.Errorf("staircase is %v slower, at least five more turns take %v", staircaseTime-lTime, minTurns)
+	}
+
+	// Starting on the other axis costs a wheel switch before moving off
+	other := PlanRoute(from, to, AxisX)
+	if got := routeTime(other, AxisY) - lTime; got != DirectionChangeTime+WheelSwitchTime {
+		t.Errorf("starting on the other axis costs %v", got)
+	}
+}
@@ -37,17 +37,31 @@ const (
 	PriorityExpress Priority = "express" // Emergency - highest priority
 )
 
+// Rank orders priorities, higher ranks go first
+func (p Priority) Rank() int {
+	switch p {
+	case PriorityExpress:
+		return 2
+	case PriorityUrgent:
+		return 1
+	default:
+		return 0
+	}
+}
+
 // OrderQueue manages pending orders
 type OrderQueue struct {
 	Orders []Order `json:"orders"`
 	NextID int     `json:"next_id"`
+	Clock  Clock   `json:"-"` // Timestamps orders in simulation time
 }
 
 // NewOrderQueue creates a new order queue
-func NewOrderQueue() *OrderQueue {
+func NewOrderQueue(clock Clock) *OrderQueue {
 	return &OrderQueue{
 		Orders: make([]Order, 0),
 		NextID: 1,
+		Clock:  clock,
 	}
 }
 
@@ -61,7 +75,7 @@ func (oq *OrderQueue) AddOrder(customerName string, productID int, requestedQty
 		Status:        OrderPending,
 		Priority:      priority,
 		AssignedRobot: 0,
-		CreatedAt:     time.Now(),
+		CreatedAt:     oq.Clock.Now(),
 		DeliveryPort:  Position{X: -1, Y: -1, Z: -1}, // Will be assigned later
 	}
 
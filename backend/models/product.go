@@ -2,16 +2,20 @@ package models
 
 // Product represents an auto part stored in the warehouse
 type Product struct {
-	ID          int      `json:"id"`
-	Name        string   `json:"name"`         // "Air Filter Honda Civic"
-	SKU         string   `json:"sku"`          // "AF-HC-2023"
-	Category    Category `json:"category"`     // Engine, Brakes, etc.
-	Brand       string   `json:"brand"`        // "Bosch", "ACDelco", etc.
-	VehicleYear int      `json:"vehicle_year"` // 2020, 2019, etc.
-	VehicleMake string   `json:"vehicle_make"` // "Honda", "Toyota", etc.
-	Price       float64  `json:"price"`        // 29.99
-	Weight      float64  `json:"weight_kg"`    // 0.5 kg
-	Position    Position `json:"position"`     // Where it's stored in warehouse
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`          // "Air Filter Honda Civic"
+	SKU          string   `json:"sku"`           // "AF-HC-2023"
+	Category     Category `json:"category"`      // Engine, Brakes, etc.
+	Brand        string   `json:"brand"`         // "Bosch", "ACDelco", etc.
+	VehicleYear  int      `json:"vehicle_year"`  // 2020, 2019, etc.
+	VehicleMake  string   `json:"vehicle_make"`  // "Honda", "Toyota", etc.
+	Price        float64  `json:"price"`         // 29.99
+	Weight       float64  `json:"weight_kg"`     // 0.5 kg
+	Volume       float64  `json:"volume_l"`      // Space one unit takes in a bin, 1.2 litres
+	Velocity     float64  `json:"velocity"`      // Relative demand, fast movers pick more often
+	ReorderPoint int      `json:"reorder_point"` // Replenish when total stock drops to this level, 0 disables
+	TargetLevel  int      `json:"target_level"`  // Stock level a replenishment receipt tops up to
+	Position     Position `json:"position"`      // Where it's stored in warehouse, guarded by the warehouse lock
 }
 
 // Category represents product categories for auto parts
@@ -86,24 +90,14 @@ func (pc *ProductCatalog) GetProductsByCategory(category Category) []*Product {
 	return products
 }
 
-// StorageCell reprensents a bin in the warehouse capable of carrying product inventory in it
-type StorageCell struct {
-	ProductID int    `json:"product_id"` // ID of product stored in this bin
-	Quantity  int    `json:"quantity"`   // Number of items in this bin
-	BinID     string `json:"bin_id"`     // Unique bin identifier
-}
-
-// IsEmpty checks if the the storage cell has no inventory
-func (sc StorageCell) IsEmpty() bool {
-	return sc.Quantity == 0
-}
-
-// HasProduct checks if cell contains a specific product
-func (sc StorageCell) HasProduct(productID int) bool {
-	return sc.ProductID == productID && sc.Quantity > 0
-}
-
-// CanFulfill checks if cell has enough quantity for an order
-func (sc StorageCell) CanFulfill(productID int, requestedQty int) bool {
-	return sc.ProductID == productID && sc.Quantity >= requestedQty
+// BinPlacement puts a quantity of a product into a bin at a fixed grid position
+type BinPlacement struct {
+	ProductID int    `json:"product_id"`
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	Z         int    `json:"z"`
+	Quantity  int    `json:"quantity"`
+	BinID     string `json:"bin_id,omitempty"` // Generated when empty
+	// Compartment within the bin, several placements may share a bin in different compartments
+	Compartment int `json:"compartment,omitempty"`
 }
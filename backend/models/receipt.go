@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Receipt represents inbound stock to be put away through a goods-in workstation
+type Receipt struct {
+	ID            int           `json:"id"`
+	ProductID     int           `json:"product_id"`            // ID of product arriving
+	Quantity      int           `json:"quantity"`              // Units to add to a bin
+	ReceivedQty   int           `json:"received_qty"`          // Units put away so far, the receipt stays open until all are
+	Source        ReceiptSource `json:"source"`                // api or reorder
+	Status        ReceiptStatus `json:"status"`                // pending, assigned, receiving, received
+	AssignedRobot int           `json:"assigned_robot"`        // Which robot fetches the bin
+	Bin           Position      `json:"bin"`                   // Where the bin to fill was taken from
+	CreatedAt     time.Time     `json:"created_at"`            // When the receipt was raised
+	ReceivedAt    *time.Time    `json:"received_at,omitempty"` // When the stock was added to the bin
+}
+
+// Remaining returns the units still to be put away
+func (r Receipt) Remaining() int {
+	return r.Quantity - r.ReceivedQty
+}
+
+// ReceiptStatus represents the current state of a receipt
+type ReceiptStatus string
+
+const (
+	ReceiptPending   ReceiptStatus = "pending"   // Waiting for a bin and robot, again when the last bin was too full
+	ReceiptAssigned  ReceiptStatus = "assigned"  // Robot fetching a bin
+	ReceiptReceiving ReceiptStatus = "receiving" // Bin at goods-in, operator filling it
+	ReceiptReceived  ReceiptStatus = "received"  // Every unit added, bin on its way back
+)
+
+// ReceiptSource tells where a receipt came from
+type ReceiptSource string
+
+const (
+	ReceiptSourceAPI     ReceiptSource = "api"     // Created through POST /api/receipts
+	ReceiptSourceReorder ReceiptSource = "reorder" // Raised when stock fell below the reorder point
+)
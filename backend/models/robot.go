@@ -1,51 +1,108 @@
 package models
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 )
 
-// Robot represents an AutoStore robot
+// RobotState is where a robot is and what it is doing
+type RobotState struct {
+	ID        int        `json:"id"`
+	X         int        `json:"x"`
+	Y         int        `json:"y"`
+	Z         int        `json:"z"`
+	Status    string     `json:"status"`
+	Type      *RobotType `json:"type"`               // Robot model, StandardRobot when nil
+	PayloadKg float64    `json:"payload_kg"`         // Weight of the bin being carried, 0 when empty
+	EnergyWh  float64    `json:"energy_wh"`          // Battery energy used so far
+	Draining  bool       `json:"draining,omitempty"` // Finishing queued work before leaving the fleet
+	Paused    bool       `json:"paused,omitempty"`   // Holding queued commands until resumed
+	WheelAxis Axis       `json:"-"`                  // Track the lowered wheel set drives along
+}
+
+// Robot represents an AutoStore robot. Its state is written by the robot goroutine under mu,
+// other goroutines read it through Snapshot.
 type Robot struct {
-	ID              int               `json:"id"`
-	X               int               `json:"x"`
-	Y               int               `json:"y"`
-	Z               int               `json:"z"`
-	Status          string            `json:"status"`
+	RobotState
 	Commands        chan RobotCommand `json:"-"`
 	Updates         chan RobotUpdate  `json:"-"`
 	BroadcastUpdate func(RobotUpdate) `json:"-"` // Callback for broadcasting updates
+	Clock           Clock             `json:"-"` // Simulation clock, wall time when nil
+	Traffic         TrafficControl    `json:"-"` // Reserves routes between robots, free driving when nil
+
+	mu      sync.RWMutex    // Guards RobotState
+	ctx     context.Context // Lifetime of the goroutine, travel is abandoned once it ends
+	retire  chan struct{}   // Closed by Retire
+	wake    chan struct{}   // Signalled by Pause and Resume
+	stopped chan struct{}   // Closed when the goroutine has exited
 }
 
 // RobotCommand represents a command sent to robot
 type RobotCommand struct {
-	Type    string `json:"type"` // "move", "pick", "drop"
-	X       int    `json:"x"`
-	Y       int    `json:"y"`
-	Z       int    `json:"z"`
-	OrderID int    `json:"order_id"`
+	Type      string        `json:"type"` // "move", "pick", "drop", "store", "fault"
+	X         int           `json:"x"`
+	Y         int           `json:"y"`
+	Z         int           `json:"z"`
+	OrderID   int           `json:"order_id"`
+	ReceiptID int           `json:"receipt_id,omitempty"`
+	Digs      int           `json:"digs,omitempty"`       // Bins stacked above a pick that must be dug out
+	Duration  time.Duration `json:"duration,omitempty"`   // How long a fault lasts, or extra operator time at a port
+	PayloadKg float64       `json:"payload_kg,omitempty"` // Weight of the bin handled by this command
+	Priority  int           `json:"priority,omitempty"`   // Right of way in traffic, e.g. Priority.Rank of the order
 }
 
+// DigTimePerBin is the time to lift a blocking bin off a stack and set it aside
+const DigTimePerBin = 4 * time.Second
+
+// LiftSlowdownAtMax is the share of lift speed lost with a bin at the robot's full payload
+const LiftSlowdownAtMax = 0.5
+
 // RobotUpdate represents status updates from robots
 type RobotUpdate struct {
-	RobotID int    `json:"robot_id"`
-	X       int    `json:"x"`
-	Y       int    `json:"y"`
-	Z       int    `json:"z"`
-	Status  string `json:"status"`
-	OrderID int    `json:"order_id,omitempty"`
+	RobotID   int    `json:"robot_id"`
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	Z         int    `json:"z"`
+	Status    string `json:"status"`
+	OrderID   int    `json:"order_id,omitempty"`
+	ReceiptID int    `json:"receipt_id,omitempty"`
+	Command   string `json:"command,omitempty"` // Command type the update belongs to
+}
+
+// Snapshot returns a consistent copy of the robot's state
+func (r *Robot) Snapshot() RobotState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.RobotState
+}
+
+// MarshalJSON encodes a snapshot, so the API never sees a robot halfway through an update
+func (r *Robot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Snapshot())
+}
+
+// IsIdle reports whether the robot is idle with no commands queued and not paused
+func (r *Robot) IsIdle() bool {
+	state := r.Snapshot()
+	return state.Status == "idle" && !state.Paused && len(r.Commands) == 0
 }
 
 // DisplayInfo prints robot information to console
-func (r Robot) DisplayInfo() {
-	fmt.Printf("Robot %d at position (%d, %d) - Status: %s\n", r.ID, r.X, r.Y, r.Status)
+func (r *Robot) DisplayInfo() {
+	state := r.Snapshot()
+	fmt.Printf("Robot %d at position (%d, %d) - Status: %s\n", state.ID, state.X, state.Y, state.Status)
 }
 
 // Old MoveTo method for compatibility temporarily
 func (r *Robot) MoveTo(newX, newY int) {
+	r.mu.Lock()
 	r.X = newX
 	r.Y = newY
 	r.Status = "moving"
+	r.mu.Unlock()
 	fmt.Printf("Robot %d moved to (%d, %d)\n", r.ID, r.X, r.Y)
 }
 
@@ -56,34 +113,63 @@ func (r *Robot) MoveToWithBounds(newX, newY, newZ int, warehouse Warehouse) bool
 		return false
 	}
 
+	r.mu.Lock()
 	r.X = newX
 	r.Y = newY
 	r.Z = newZ
 	r.Status = "moving"
+	r.mu.Unlock()
 	fmt.Printf("Robot %d moved to (%d, %d, %d)\n", r.ID, r.X, r.Y, r.Z)
 	return true
 }
 
-// StartRobot to launch the robot as gouroutine with channels for communication
-func (r *Robot) StartRobot(sw *SafeWarehouse, done chan bool) {
+// StartRobot to launch the robot as gouroutine with channels for communication.
+// Once ctx ends the robot finishes its current command and stops, queued commands are dropped.
+func (r *Robot) StartRobot(ctx context.Context, sw *SafeWarehouse) {
 	// Initialize channels
+	r.ctx = ctx
 	r.Commands = make(chan RobotCommand, 10)
 	r.Updates = make(chan RobotUpdate, 10)
+	r.retire = make(chan struct{})
+	r.wake = make(chan struct{}, 1)
+	r.stopped = make(chan struct{})
 
 	fmt.Printf("Robot %d started as goroutine at position (%d, %d, %d)\n", r.ID, r.X, r.Y, r.Z)
 
 	// Launch the worker goroutine
 	go func() {
+		defer close(r.stopped)
 		for {
+			if r.Snapshot().Paused && !r.holdWhilePaused(ctx) {
+				fmt.Printf("Robot %d shutting down\n", r.ID)
+				return
+			}
+
 			select {
 			// Listen for commands
 			case cmd := <-r.Commands:
+				// select picks at random when ctx has ended too, a queued command must not start then
+				if ctx.Err() != nil {
+					fmt.Printf("Robot %d shutting down\n", r.ID)
+					return
+				}
 				fmt.Printf("Robot %d received command: %s to (%d, %d, %d)\n",
 					r.ID, cmd.Type, cmd.X, cmd.Y, cmd.Z)
 
 				r.processCommand(cmd, sw)
 
-			case <-done:
+			case <-r.wake:
+				// Paused while idle, hold from the next pass
+
+			case <-r.retire:
+				// Finish the job already queued, e.g. return the bin of a delivered order
+				for len(r.Commands) > 0 && ctx.Err() == nil {
+					r.processCommand(<-r.Commands, sw)
+				}
+				fmt.Printf("Robot %d retired\n", r.ID)
+				return
+
+			case <-ctx.Done():
 				fmt.Printf("Robot %d shutting down\n", r.ID)
 				return // Exiting the goroutine
 			}
@@ -91,6 +177,58 @@ func (r *Robot) StartRobot(sw *SafeWarehouse, done chan bool) {
 	}()
 }
 
+// holdWhilePaused reports the robot paused and waits until it is resumed or retired.
+// It returns false when ctx ends first.
+func (r *Robot) holdWhilePaused(ctx context.Context) bool {
+	r.setStatus("paused", RobotCommand{Type: "pause"})
+	for r.Snapshot().Paused {
+		select {
+		case <-r.wake:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	r.setStatus("idle", RobotCommand{Type: "resume"})
+	return true
+}
+
+// Pause makes the robot hold after its current command, queued commands wait until Resume
+func (r *Robot) Pause() {
+	r.setPaused(true)
+}
+
+// Resume lets a paused robot carry on with its queued commands
+func (r *Robot) Resume() {
+	r.setPaused(false)
+}
+
+// setPaused records the pause flag and wakes the goroutine to act on it
+func (r *Robot) setPaused(paused bool) {
+	r.mu.Lock()
+	r.Paused = paused
+	r.mu.Unlock()
+	select {
+	case r.wake <- struct{}{}:
+	default: // A wake-up is already pending
+	}
+}
+
+// Retire lets the robot finish its queued commands and then stops its goroutine.
+// A paused robot is resumed first. The returned channel is closed once the robot has stopped.
+func (r *Robot) Retire() <-chan struct{} {
+	r.mu.Lock()
+	r.Draining = true
+	r.mu.Unlock()
+	close(r.retire)
+	r.setPaused(false)
+	return r.stopped
+}
+
+// Stopped returns a channel that is closed once the robot goroutine has exited
+func (r *Robot) Stopped() <-chan struct{} {
+	return r.stopped
+}
+
 // processCommand handles actual command execution for robots
 func (r *Robot) processCommand(cmd RobotCommand, sw *SafeWarehouse) {
 	switch cmd.Type {
@@ -100,125 +238,318 @@ func (r *Robot) processCommand(cmd RobotCommand, sw *SafeWarehouse) {
 
 			travelTime := r.calculateTravelTime(cmd.X, cmd.Y, cmd.Z)
 
-			r.Status = "moving"
+			r.setStatus("moving", cmd)
 			fmt.Printf("Robot %d moving to (%d, %d, %d), estimated time: %v\n",
 				r.ID, cmd.X, cmd.Y, cmd.Z, travelTime)
 
-			// Simulate travel time
-			time.Sleep(travelTime)
-
-			// Update position after travel
-			r.X = cmd.X
-			r.Y = cmd.Y
-			r.Z = cmd.Z
-			r.Status = "idle"
-
+			// Simulate travel leg by leg, position updates at each corner
+			if !r.travelTo(cmd) {
+				return
+			}
 			fmt.Printf("Robot %d arrived at (%d, %d, %d)\n", r.ID, r.X, r.Y, r.Z)
 
-			// Broadcast update via WebSocket
-			if r.BroadcastUpdate != nil {
-				r.BroadcastUpdate(RobotUpdate{
-					RobotID: r.ID,
-					X:       r.X,
-					Y:       r.Y,
-					Z:       r.Z,
-					Status:  r.Status,
-				})
-			}
+			r.setStatus("idle", cmd)
 		} else {
-			r.Status = "error"
-			fmt.Printf("Robot %d: Move failed\n", r.ID)
 			fmt.Printf("Robot %d cannot move to (%d, %d, %d) - position occupied or out of bounds\n",
 				r.ID, cmd.X, cmd.Y, cmd.Z)
-			fmt.Printf("Robot %d: Move failed - invalid position (%d, %d, %d)\n", r.ID, cmd.X, cmd.Y, cmd.Z)
+			r.setStatus("error", cmd)
 		}
 	case "pick":
+		// Travel empty to the stack, the gripper takes the bin's weight once it lifts
+		r.setPayload(0)
+
 		// First move to pick location if not already there
 		if r.X != cmd.X || r.Y != cmd.Y || r.Z != cmd.Z {
 			travelTime := r.calculateTravelTime(cmd.X, cmd.Y, cmd.Z)
-			r.Status = "moving"
+			r.setStatus("moving", cmd)
 			fmt.Printf("Robot %d moving to pick location (%d, %d, %d) - ETA: %.1fs\n",
 				r.ID, cmd.X, cmd.Y, cmd.Z, travelTime.Seconds())
-			time.Sleep(travelTime)
-			r.X = cmd.X
-			r.Y = cmd.Y
-			r.Z = cmd.Z
+			if !r.travelTo(cmd) {
+				return
+			}
 		}
 
-		r.Status = "picking"
+		r.setStatus("picking", cmd)
 		fmt.Printf("Robot %d picking up item at (%d, %d, %d)\n", r.ID, cmd.X, cmd.Y, cmd.Z)
-		// Realistic pick time (lowering bin, grabbing, lifting)
-		time.Sleep(2 * time.Second)
-		r.Status = "carrying"
+		// Dig out bins stacked above the target first
+		if cmd.Digs > 0 {
+			fmt.Printf("Robot %d digging %d bins\n", r.ID, cmd.Digs)
+			r.sleep(time.Duration(cmd.Digs) * DigTimePerBin)
+		}
+		// Realistic pick time (lowering bin, grabbing, lifting), heavy bins lift slower
+		r.setPayload(cmd.PayloadKg)
+		r.sleep(2*time.Second + r.liftPenalty(cmd.Z+1))
+		r.addEnergy(LiftEnergyWh(r.PayloadKg, float64(cmd.Z+1)*BinHeightMeters))
 		fmt.Printf("Robot %d picked up item for order %d\n", r.ID, cmd.OrderID)
+		r.setStatus("carrying", cmd)
+	case "drop":
+		r.setPayload(cmd.PayloadKg)
 
-		// Broadcast update via WebSocket
-		if r.BroadcastUpdate != nil {
-			r.BroadcastUpdate(RobotUpdate{
-				RobotID: r.ID,
-				X:       r.X,
-				Y:       r.Y,
-				Z:       r.Z,
-				Status:  r.Status,
-				OrderID: cmd.OrderID,
-			})
+		// Carry the bin to the delivery port first
+		if r.X != cmd.X || r.Y != cmd.Y || r.Z != cmd.Z {
+			travelTime := r.calculateTravelTime(cmd.X, cmd.Y, cmd.Z)
+			fmt.Printf("Robot %d delivering to port (%d, %d, %d) - ETA: %.1fs\n",
+				r.ID, cmd.X, cmd.Y, cmd.Z, travelTime.Seconds())
+			if !r.travelTo(cmd) {
+				return
+			}
 		}
-	case "drop":
-		r.Status = "dropping"
+
+		r.setStatus("dropping", cmd)
 		fmt.Printf("Robot %d dropping item at (%d, %d, %d)\n", r.ID, cmd.X, cmd.Y, cmd.Z)
 		// Realistic drop time (lowering, placing, lifting)
-		time.Sleep(1500 * time.Millisecond)
-		r.Status = "idle"
+		r.sleep(1500*time.Millisecond + r.liftPenalty(1))
+		r.addEnergy(LiftEnergyWh(r.PayloadKg, BinHeightMeters))
+		// Wait while the operator works on the bin, e.g. filling it at goods-in
+		if cmd.Duration > 0 {
+			r.sleep(cmd.Duration)
+		}
 		fmt.Printf("Robot %d completed delivery for order %d\n", r.ID, cmd.OrderID)
+		r.setStatus("idle", cmd)
+	case "store":
+		r.setPayload(cmd.PayloadKg)
 
-		// Broadcast update via WebSocket
-		if r.BroadcastUpdate != nil {
-			r.BroadcastUpdate(RobotUpdate{
-				RobotID: r.ID,
-				X:       r.X,
-				Y:       r.Y,
-				Z:       r.Z,
-				Status:  r.Status,
-				OrderID: cmd.OrderID,
-			})
+		// Return the carried bin into the grid
+		if r.X != cmd.X || r.Y != cmd.Y || r.Z != cmd.Z {
+			travelTime := r.calculateTravelTime(cmd.X, cmd.Y, cmd.Z)
+			r.setStatus("returning", cmd)
+			fmt.Printf("Robot %d returning bin to (%d, %d, %d) - ETA: %.1fs\n",
+				r.ID, cmd.X, cmd.Y, cmd.Z, travelTime.Seconds())
+			if !r.travelTo(cmd) {
+				return
+			}
 		}
+
+		r.setStatus("storing", cmd)
+		// Lowering the bin into the stack
+		r.sleep(1500*time.Millisecond + r.liftPenalty(cmd.Z+1))
+		r.setPayload(0)
+		fmt.Printf("Robot %d stored bin at (%d, %d, %d)\n", r.ID, cmd.X, cmd.Y, cmd.Z)
+		r.setStatus("idle", cmd)
+	case "fault":
+		// Simulated breakdown, the robot is unavailable until it recovers
+		r.setStatus("error", cmd)
+		fmt.Printf("Robot %d fault, recovering in %v\n", r.ID, cmd.Duration)
+		r.sleep(cmd.Duration)
+		fmt.Printf("Robot %d recovered\n", r.ID)
+		r.setStatus("idle", cmd)
+	}
+}
+
+// sleep waits for d of simulation time
+func (r *Robot) sleep(d time.Duration) {
+	if r.Clock == nil {
+		time.Sleep(d)
+		return
 	}
+	r.Clock.Sleep(d)
 }
 
-// calculateTravelTime calculates realistic travel time based on distance
+// setStatus changes the robot status and broadcasts the new state via the callback
+func (r *Robot) setStatus(status string, cmd RobotCommand) {
+	r.mu.Lock()
+	r.Status = status
+	r.mu.Unlock()
+
+	if r.BroadcastUpdate != nil {
+		r.BroadcastUpdate(RobotUpdate{
+			RobotID:   r.ID,
+			X:         r.X,
+			Y:         r.Y,
+			Z:         r.Z,
+			Status:    r.Status,
+			OrderID:   cmd.OrderID,
+			ReceiptID: cmd.ReceiptID,
+			Command:   cmd.Type,
+		})
+	}
+}
+
+// setPayload records the weight of the bin in the gripper
+func (r *Robot) setPayload(kg float64) {
+	r.mu.Lock()
+	r.PayloadKg = kg
+	r.mu.Unlock()
+}
+
+// setLevel records the lift level
+func (r *Robot) setLevel(z int) {
+	r.mu.Lock()
+	r.Z = z
+	r.mu.Unlock()
+}
+
+// addEnergy adds battery energy used
+func (r *Robot) addEnergy(wh float64) {
+	r.mu.Lock()
+	r.EnergyWh += wh
+	r.mu.Unlock()
+}
+
+// calculateTravelTime estimates the trip to a target along the planned route, including
+// stops at corners, wheel switches and the lift, for the current payload
 func (r *Robot) calculateTravelTime(targetX, targetY, targetZ int) time.Duration {
+	from := Position{X: r.X, Y: r.Y, Z: r.Z}
+	to := Position{X: targetX, Y: targetY, Z: targetZ}
+
+	legs := PlanRoute(from, to, r.WheelAxis)
+	if len(legs) == 0 {
+		// Staying on the same stack, only the lift moves
+		return r.liftTime(abs(r.Z - targetZ))
+	}
+
+	total := r.liftTime(r.Z) + r.liftTime(targetZ)
+	axis := r.WheelAxis
+	for _, leg := range legs {
+		total += r.legTime(leg, axis)
+		axis = leg.Axis
+	}
+	return total
+}
+
+// travelTo raises the lift, drives to the command target and lowers the lift there.
+// With traffic control the robot drives reserved routes, waiting in place when blocked.
+// It returns false when the robot shuts down while waiting for traffic, short of the target.
+func (r *Robot) travelTo(cmd RobotCommand) bool {
+	if r.X == cmd.X && r.Y == cmd.Y {
+		r.lift(abs(r.Z - cmd.Z))
+		r.setLevel(cmd.Z)
+		return true
+	}
+
+	// The gripper must be clear of the grid before the robot drives off
+	r.lift(r.Z)
+	r.setLevel(0)
+
+	status := r.Status
+	target := Position{X: cmd.X, Y: cmd.Y, Z: cmd.Z}
+	for r.X != target.X || r.Y != target.Y {
+		from := Position{X: r.X, Y: r.Y}
+		if r.Traffic == nil {
+			r.drive(PlanRoute(from, target, r.WheelAxis), cmd)
+			continue
+		}
+
+		plan := r.Traffic.RequestRoute(RouteRequest{
+			RobotID:  r.ID,
+			From:     from,
+			To:       target,
+			Axis:     r.WheelAxis,
+			Priority: cmd.Priority,
+			LegTime:  r.legTime,
+		})
+		if !plan.OK {
+			if r.ctx != nil && r.ctx.Err() != nil {
+				// Shutting down, the way may never clear now that other robots have stopped
+				fmt.Printf("Robot %d abandons %s at (%d, %d), shutting down\n", r.ID, cmd.Type, r.X, r.Y)
+				return false
+			}
+			r.waitForTraffic(plan.RetryAfter, cmd)
+			continue
+		}
+		if plan.Wait > 0 {
+			r.waitForTraffic(plan.Wait, cmd)
+		}
+		if r.Status != status {
+			r.setStatus(status, cmd)
+		}
+		r.drive(plan.Legs, cmd)
+		if plan.Yield {
+			// Give the robots we stepped aside for a head start
+			r.sleep(plan.RetryAfter)
+		}
+	}
+
+	r.lift(cmd.Z)
+	r.setLevel(cmd.Z)
+	return true
+}
+
+// drive follows legs, reporting each corner so clients can follow the robot
+func (r *Robot) drive(legs []Leg, cmd RobotCommand) {
+	for _, leg := range legs {
+		r.sleep(r.legTime(leg, r.WheelAxis))
+		r.addEnergy(DriveEnergyWh(r.RobotType().MassKg+r.PayloadKg, leg.Distance(), r.RobotType().Speed))
+		r.mu.Lock()
+		r.X, r.Y = leg.To.X, leg.To.Y
+		r.WheelAxis = leg.Axis
+		r.mu.Unlock()
+		// The command reports arrival at its target itself
+		if r.X != cmd.X || r.Y != cmd.Y {
+			r.setStatus(r.Status, cmd)
+		}
+	}
+}
+
+// waitForTraffic holds the robot in its cell while other robots pass
+func (r *Robot) waitForTraffic(d time.Duration, cmd RobotCommand) {
+	if r.Status != "waiting" {
+		r.setStatus("waiting", cmd)
+	}
+	r.sleep(d)
+}
+
+// legTime is the time for one straight run, plus stopping to turn and switching wheels when the axis changes
+func (r *Robot) legTime(leg Leg, current Axis) time.Duration {
+	d := r.driveProfile().Time(leg.Distance())
+	if current != AxisNone && current != leg.Axis {
+		d += DirectionChangeTime + WheelSwitchTime
+	}
+	return d
+}
+
+// RobotType returns the robot's model, the standard robot when none is set
+func (r *Robot) RobotType() *RobotType {
+	if r.Type == nil {
+		return &StandardRobot
+	}
+	return r.Type
+}
+
+// driveProfile is the drive profile for the current payload, the same motor force moves more mass
+func (r *Robot) driveProfile() MotionProfile {
+	t := r.RobotType()
+	p := t.DriveProfile()
+	scale := t.MassKg / (t.MassKg + r.PayloadKg)
+	p.Acceleration *= scale
+	p.Deceleration *= scale
+	return p
+}
+
+// liftProfile is the lift profile for the current payload, heavy bins are raised slower
+func (r *Robot) liftProfile() MotionProfile {
+	t := r.RobotType()
+	load := r.PayloadKg / t.PayloadKg
+	if load > 1 {
+		load = 1
+	}
+	p := t.LiftProfile()
+	p.MaxSpeed *= 1 - LiftSlowdownAtMax*load
+	return p
+}
+
+// lift moves the lift through levels with the current payload
+func (r *Robot) lift(levels int) {
+	r.sleep(r.liftTime(levels))
+	r.addEnergy(LiftEnergyWh(r.PayloadKg, float64(levels)*BinHeightMeters))
+}
+
+// liftTime is the time to move the lift through levels with the current payload
+func (r *Robot) liftTime(levels int) time.Duration {
+	return r.liftProfile().Time(float64(levels) * BinHeightMeters)
+}
+
+// liftPenalty is the extra time to lift or lower the payload through levels compared to an empty gripper
+func (r *Robot) liftPenalty(levels int) time.Duration {
+	distance := float64(levels) * BinHeightMeters
+	return r.liftProfile().Time(distance) - r.RobotType().LiftProfile().Time(distance)
+}
 
-	// Real AutoStore physical constants
-	const (
-		GRID_WIDTH_METERS      = 0.705 // 705mm wide direction
-		GRID_DEPTH_METERS      = 0.480 // 480mm narrow direction
-		BIN_HEIGHT_METERS      = 0.330 // 330mm bins
-		ROBOT_HORIZONTAL_SPEED = 3.1   // m/s (real spec)
-		ROBOT_LIFT_SPEED       = 1.6   // m/s (real spec)
-		ROBOT_ACCELERATION     = 0.8   // m/s²
-	)
-
-	// Calculate Manhattan distance
-	deltaX := abs(r.X - targetX)
-	deltaY := abs(r.Y - targetY)
-	deltaZ := abs(r.Z - targetZ)
-
-	// Calculate actual distances in meters
-	horizontalDistance := float64(deltaX)*GRID_WIDTH_METERS + float64(deltaY)*GRID_DEPTH_METERS
-	verticalDistance := float64(deltaZ) * BIN_HEIGHT_METERS
-
-	// Calculate travel times based on real AutoStore speeds
-	horizontalTime := horizontalDistance / ROBOT_HORIZONTAL_SPEED
-	verticalTime := verticalDistance / ROBOT_LIFT_SPEED
-	totalTime := horizontalTime + verticalTime
-
-	// Add small base time for acceleration/deceleration
-	if totalTime > 0 {
-		accelTime := ROBOT_HORIZONTAL_SPEED / ROBOT_ACCELERATION // Time to reach max speed
-		totalTime += accelTime * 0.5                             // Account for accel/decel
-	}
-
-	return time.Duration(totalTime * float64(time.Second))
+// TravelTimeTo estimates how long the robot takes to reach pos from where it is now
+func (r *Robot) TravelTimeTo(pos Position) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.calculateTravelTime(pos.X, pos.Y, pos.Z)
 }
 
 // abs returns absolute value of integer
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestRobotDropsCommandsAfterShutdown queues a command once ctx has ended, so the robot's
+// select finds both ready. The command must never start.
+func TestRobotDropsCommandsAfterShutdown(t *testing.T) {
+	sw := NewSafeWarehouse(10, 10, 5)
+	for i := 0; i < 50; i++ {
+		robot := &Robot{RobotState: RobotState{ID: 1, Status: "idle"}}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// The goroutine reads a snapshot before its select, holding the lock parks it there
+		robot.mu.Lock()
+		robot.StartRobot(ctx, sw)
+		robot.Commands <- RobotCommand{Type: "move", X: 5, Y: 5}
+		robot.mu.Unlock()
+
+		select {
+		case <-robot.Stopped():
+		case <-time.After(5 * time.Second):
+			t.Fatal("robot did not stop")
+		}
+		if state := robot.Snapshot(); state.Status != "idle" || state.X != 0 || state.Y != 0 {
+			t.Fatalf("command ran after shutdown: %+v", state)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RobotType describes a robot model: how it drives, lifts and what it can carry
+type RobotType struct {
+	Name         string  `json:"name"`
+	Speed        float64 `json:"speed"`        // Top horizontal speed, m/s
+	Acceleration float64 `json:"acceleration"` // Horizontal acceleration and braking, m/s²
+	LiftSpeed    float64 `json:"lift_speed"`   // Top lift speed with an empty gripper, m/s
+	PayloadKg    float64 `json:"payload_kg"`   // Heaviest bin the robot can lift
+	MassKg       float64 `json:"mass_kg"`      // Empty robot, heavier payloads reduce acceleration proportionally
+	BatteryKWh   float64 `json:"battery_kwh"`  // Usable battery capacity
+	Footprint    int     `json:"footprint"`    // Cells covered: 1, or 2 for a cantilever robot whose body sits east of its gripper
+}
+
+// Fleet changes at runtime fail with these
+var (
+	ErrNoFreeCell   = errors.New("no free cell on the grid for the robot")
+	ErrUnknownRobot = errors.New("unknown robot")
+)
+
+// Built-in robot types, configs can add their own
+var (
+	// StandardRobot is a single-cell robot like the AutoStore R5
+	StandardRobot = RobotType{
+		Name: "r5", Speed: 3.1, Acceleration: 0.8, LiftSpeed: 1.6,
+		PayloadKg: MaxBinWeightKg, MassKg: 145, BatteryKWh: 1.4, Footprint: 1,
+	}
+	// CantileverRobot is an older two-cell robot, it cannot work the easternmost column
+	CantileverRobot = RobotType{
+		Name: "cantilever", Speed: 3.1, Acceleration: 0.6, LiftSpeed: 1.3,
+		PayloadKg: MaxBinWeightKg, MassKg: 210, BatteryKWh: 1.2, Footprint: 2,
+	}
+)
+
+// BuiltinRobotTypes lists the robot types available without config
+var BuiltinRobotTypes = []RobotType{StandardRobot, CantileverRobot}
+
+// Validate checks that a robot type can move and lift
+func (t RobotType) Validate() error {
+	switch {
+	case t.Name == "":
+		return fmt.Errorf("robot type needs a name")
+	case t.Speed <= 0 || t.Acceleration <= 0 || t.LiftSpeed <= 0:
+		return fmt.Errorf("robot type %q: speed, acceleration and lift speed must be positive", t.Name)
+	case t.PayloadKg <= 0 || t.MassKg <= 0:
+		return fmt.Errorf("robot type %q: payload and mass must be positive", t.Name)
+	case t.BatteryKWh < 0:
+		return fmt.Errorf("robot type %q: battery capacity must not be negative", t.Name)
+	case t.Footprint != 1 && t.Footprint != 2:
+		return fmt.Errorf("robot type %q: footprint must be 1 or 2 cells, got %d", t.Name, t.Footprint)
+	}
+	return nil
+}
+
+// DriveProfile returns the horizontal motion profile of an empty robot
+func (t *RobotType) DriveProfile() MotionProfile {
+	return MotionProfile{MaxSpeed: t.Speed, Acceleration: t.Acceleration, Deceleration: t.Acceleration}
+}
+
+// LiftProfile returns the lift motion profile with an empty gripper
+func (t *RobotType) LiftProfile() MotionProfile {
+	return MotionProfile{MaxSpeed: t.LiftSpeed, Acceleration: LiftAcceleration, Deceleration: LiftAcceleration}
+}
+
+// Fits reports whether the robot's body stays on a grid width cells wide with its gripper over column x
+func (t *RobotType) Fits(x, width int) bool {
+	return x >= 0 && x+t.Footprint-1 < width
+}
+
+// CanLift reports whether the robot can lift a bin of the given weight
+func (t *RobotType) CanLift(weightKg float64) bool {
+	return weightKg <= t.PayloadKg+capacityEpsilon
+}
+
+// Cells returns the cells the robot covers with its gripper over pos
+func (t *RobotType) Cells(pos Position) []Position {
+	cells := make([]Position, 0, t.Footprint)
+	for i := 0; i < t.Footprint; i++ {
+		cells = append(cells, Position{X: pos.X + i, Y: pos.Y})
+	}
+	return cells
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// TrafficControl hands out collision-free routes over the grid top, e.g. services.TrafficController
+type TrafficControl interface {
+	// Register parks a robot at its start cell before it first moves, footprint is the cells it covers
+	Register(robotID int, pos Position, footprint int)
+	// RequestRoute reserves a timed route for the robot. When no route is free the plan is
+	// not ok and the robot holds its cell and asks again after RetryAfter.
+	RequestRoute(req RouteRequest) RoutePlan
+	// Unregister drops every reservation a robot holds, e.g. when it leaves the fleet
+	Unregister(robotID int)
+}
+
+// RouteRequest asks for a route from the robot's cell to a target cell
+type RouteRequest struct {
+	RobotID  int
+	From     Position
+	To       Position
+	Axis     Axis // Current wheel set, turns onto the other axis cost a wheel switch
+	Priority int  // Higher priority robots win when robots block each other
+	// LegTime times one leg for this robot and payload, starting on the current axis
+	LegTime func(leg Leg, current Axis) time.Duration
+}
+
+// RoutePlan is a reserved route: wait in place, then drive the legs
+type RoutePlan struct {
+	OK         bool
+	Wait       time.Duration // Hold the start cell before the first leg
+	Legs       []Leg
+	Yield      bool          // Legs side-step out of another robot's way instead of reaching the target
+	RetryAfter time.Duration // When not OK, how long to wait before asking again
+}
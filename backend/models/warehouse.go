@@ -18,6 +18,8 @@ type SafeWarehouse struct {
 	Levels int               `json:"levels"`
 	Grid   [][][]StorageCell `json:"-"`
 	Mutex  sync.RWMutex      `json:"-"`
+
+	Compartments int `json:"compartments"` // Compartments per bin: 1, 2, 4 or 8
 }
 
 // NewSafeWarehouse creates new thread-safe with the initialized grid
@@ -36,6 +38,8 @@ func NewSafeWarehouse(width, height, levels int) *SafeWarehouse {
 		Height: height,
 		Levels: levels,
 		Grid:   grid,
+
+		Compartments: 1,
 	}
 }
 
@@ -90,3 +94,55 @@ func (sw *SafeWarehouse) IsValidPosition(x, y, z int) bool {
 		y >= 0 && y < sw.Height &&
 		z >= 0 && z < sw.Levels
 }
+
+// BinSlot is a grid cell and the bin stored in it, an empty BinID when the cell is free
+type BinSlot struct {
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	Z     int    `json:"z"`
+	BinID string `json:"bin_id"`
+}
+
+// GridOccupancy lists the grid dimensions and every cell holding a bin
+type GridOccupancy struct {
+	Width  int       `json:"width"`
+	Height int       `json:"height"`
+	Levels int       `json:"levels"`
+	Bins   []BinSlot `json:"bins"`
+}
+
+// Occupancy returns the cells currently holding a bin
+func (sw *SafeWarehouse) Occupancy() GridOccupancy {
+	sw.Mutex.RLock()
+	defer sw.Mutex.RUnlock()
+
+	occupancy := GridOccupancy{Width: sw.Width, Height: sw.Height, Levels: sw.Levels}
+	for x := range sw.Grid {
+		for y := range sw.Grid[x] {
+			for z, cell := range sw.Grid[x][y] {
+				if cell.BinID != "" {
+					occupancy.Bins = append(occupancy.Bins, BinSlot{X: x, Y: y, Z: z, BinID: cell.BinID})
+				}
+			}
+		}
+	}
+	return occupancy
+}
+
+// BinCount returns how many cells hold a bin
+func (sw *SafeWarehouse) BinCount() int {
+	sw.Mutex.RLock()
+	defer sw.Mutex.RUnlock()
+
+	count := 0
+	for x := range sw.Grid {
+		for y := range sw.Grid[x] {
+			for _, cell := range sw.Grid[x][y] {
+				if cell.BinID != "" {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
@@ -5,5 +5,12 @@ type Workstation struct {
 	ID     int    `json:"id"`
 	X      int    `json:"x"`
 	Y      int    `json:"y"`
+	Type   string `json:"type"` // picking or goods_in
 	Status string `json:"status"`
 }
+
+// Workstation types
+const (
+	WorkstationPicking = "picking"  // Operators pick order lines out of bins
+	WorkstationGoodsIn = "goods_in" // Operators fill bins with received stock
+)
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"autostore-sim/backend/models"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Robot activities used for time-share accounting
+const (
+	ActivityTravelling = "travelling" // Moving along the grid, with or without a bin
+	ActivityLifting    = "lifting"    // Lowering or lifting a bin (picking, dropping, storing)
+	ActivityWaiting    = "waiting"    // Holding a cell until traffic lets the robot through
+	ActivityIdle       = "idle"       // Waiting for work (also covers faults)
+)
+
+// DefaultAnalyticsRetention is how much history the analytics service keeps
+const DefaultAnalyticsRetention = 24 * time.Hour
+
+// KPIReport holds warehouse KPIs aggregated over a time window
+type KPIReport struct {
+	WindowStart        time.Time          `json:"window_start"`
+	WindowEnd          time.Time          `json:"window_end"`
+	OrdersCompleted    int                `json:"orders_completed"`
+	OrdersPerHour      float64            `json:"orders_per_hour"`
+	LinesPerHour       float64            `json:"lines_per_hour"`
+	UnitsPerHour       float64            `json:"units_per_hour"`
+	AvgLeadTimeSeconds float64            `json:"avg_lead_time_s"` // Order created -> delivered
+	P95LeadTimeSeconds float64            `json:"p95_lead_time_s"`
+	RobotUtilisation   float64            `json:"robot_utilisation"` // Share of robot time travelling or lifting
+	TimeShare          ActivityShare      `json:"time_share"`
+	PortUtilisation    map[string]float64 `json:"port_utilisation"` // "x,y" -> share of time busy
+}
+
+// ActivityShare splits robot time between travelling, lifting, waiting in traffic and idle
+type ActivityShare struct {
+	Travelling float64 `json:"travelling"`
+	Lifting    float64 `json:"lifting"`
+	Waiting    float64 `json:"waiting"`
+	Idle       float64 `json:"idle"`
+}
+
+// orderRecord is a completed order kept for throughput and lead time
+type orderRecord struct {
+	createdAt   time.Time
+	completedAt time.Time
+	lines       int
+	units       int
+}
+
+// activitySpan is a period a robot spent in one activity
+type activitySpan struct {
+	robotID  int
+	activity string
+	port     string // Set while dropping at a port
+	start    time.Time
+	end      time.Time
+}
+
+// AnalyticsService aggregates throughput, lead time and utilisation KPIs
+type AnalyticsService struct {
+	mu        sync.Mutex
+	clock     models.Clock
+	startedAt time.Time
+	retention time.Duration
+
+	orders []orderRecord         // Ordered by completion time
+	spans  []activitySpan        // Closed spans, ordered by end time
+	open   map[int]*activitySpan // Current span per robot
+}
+
+// NewAnalyticsService creates a new analytics service
+func NewAnalyticsService(clock models.Clock) *AnalyticsService {
+	return &AnalyticsService{
+		clock:     clock,
+		startedAt: clock.Now(),
+		retention: DefaultAnalyticsRetention,
+		open:      make(map[int]*activitySpan),
+	}
+}
+
+// RegisterRobot starts tracking a robot as idle so its waiting time counts
+func (as *AnalyticsService) RegisterRobot(robotID int) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	if _, ok := as.open[robotID]; ok {
+		return
+	}
+	as.open[robotID] = &activitySpan{robotID: robotID, activity: ActivityIdle, start: as.clock.Now()}
+}
+
+// UnregisterRobot stops tracking a robot that left the fleet, closing its current span
+func (as *AnalyticsService) UnregisterRobot(robotID int) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	if span, ok := as.open[robotID]; ok {
+		span.end = as.clock.Now()
+		as.spans = append(as.spans, *span)
+		delete(as.open, robotID)
+	}
+}
+
+// RecordRobotUpdate closes the robot's current activity span and opens a new one
+func (as *AnalyticsService) RecordRobotUpdate(update models.RobotUpdate) {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	now := as.clock.Now()
+	activity := activityForStatus(update.Status)
+	port := ""
+	if update.Status == "dropping" {
+		port = fmt.Sprintf("%d,%d", update.X, update.Y)
+	}
+
+	if span, ok := as.open[update.RobotID]; ok {
+		// Status updates that don't change the activity extend the current span
+		if span.activity == activity && span.port == port {
+			return
+		}
+		span.end = now
+		as.spans = append(as.spans, *span)
+	}
+
+	as.open[update.RobotID] = &activitySpan{
+		robotID:  update.RobotID,
+		activity: activity,
+		port:     port,
+		start:    now,
+	}
+	as.prune(now)
+}
+
+// RecordOrderCompleted stores a delivered order for throughput and lead time
+func (as *AnalyticsService) RecordOrderCompleted(order models.Order) {
+	if order.CompletedAt == nil {
+		return
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	as.orders = append(as.orders, orderRecord{
+		createdAt:   order.CreatedAt,
+		completedAt: *order.CompletedAt,
+		lines:       1, // Orders currently carry a single product line
+		units:       order.RequestedQty,
+	})
+	as.prune(as.clock.Now())
+}
+
+// Report returns KPIs over the rolling window ending now
+func (as *AnalyticsService) Report(window time.Duration) KPIReport {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	end := as.clock.Now()
+	start := end.Add(-window)
+	if start.Before(as.startedAt) {
+		start = as.startedAt
+	}
+	return as.reportBetween(start, end)
+}
+
+// TimeSeries returns one KPI report per interval bucket over the rolling window
+func (as *AnalyticsService) TimeSeries(window, interval time.Duration) []KPIReport {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	end := as.clock.Now()
+	start := end.Add(-window)
+	if start.Before(as.startedAt) {
+		start = as.startedAt
+	}
+
+	series := make([]KPIReport, 0, int(window/interval)+1)
+	for bucketStart := start; bucketStart.Before(end); bucketStart = bucketStart.Add(interval) {
+		bucketEnd := bucketStart.Add(interval)
+		if bucketEnd.After(end) {
+			bucketEnd = end
+		}
+		series = append(series, as.reportBetween(bucketStart, bucketEnd))
+	}
+	return series
+}
+
+// reportBetween aggregates KPIs for [start, end), caller must hold the lock
+func (as *AnalyticsService) reportBetween(start, end time.Time) KPIReport {
+	report := KPIReport{
+		WindowStart:     start,
+		WindowEnd:       end,
+		PortUtilisation: make(map[string]float64),
+	}
+
+	duration := end.Sub(start)
+	if duration <= 0 {
+		return report
+	}
+	hours := duration.Hours()
+
+	// Throughput and lead time from orders completed inside the window
+	var leadTimes []float64
+	lines, units := 0, 0
+	for _, record := range as.orders {
+		if record.completedAt.Before(start) || !record.completedAt.Before(end) {
+			continue
+		}
+		leadTimes = append(leadTimes, record.completedAt.Sub(record.createdAt).Seconds())
+		lines += record.lines
+		units += record.units
+	}
+
+	report.OrdersCompleted = len(leadTimes)
+	report.OrdersPerHour = float64(len(leadTimes)) / hours
+	report.LinesPerHour = float64(lines) / hours
+	report.UnitsPerHour = float64(units) / hours
+	report.AvgLeadTimeSeconds = mean(leadTimes)
+	report.P95LeadTimeSeconds = percentile(leadTimes, 0.95)
+
+	// Robot time per activity, clipped to the window
+	activityTime := make(map[string]time.Duration)
+	portTime := make(map[string]time.Duration)
+	addSpan := func(span activitySpan, spanEnd time.Time) {
+		overlap := overlapDuration(span.start, spanEnd, start, end)
+		if overlap <= 0 {
+			return
+		}
+		activityTime[span.activity] += overlap
+		if span.port != "" {
+			portTime[span.port] += overlap
+		}
+	}
+	for _, span := range as.spans {
+		addSpan(span, span.end)
+	}
+	for _, span := range as.open {
+		addSpan(*span, end)
+	}
+
+	var total time.Duration
+	for _, d := range activityTime {
+		total += d
+	}
+	if total > 0 {
+		report.TimeShare = ActivityShare{
+			Travelling: activityTime[ActivityTravelling].Seconds() / total.Seconds(),
+			Lifting:    activityTime[ActivityLifting].Seconds() / total.Seconds(),
+			Waiting:    activityTime[ActivityWaiting].Seconds() / total.Seconds(),
+			Idle:       activityTime[ActivityIdle].Seconds() / total.Seconds(),
+		}
+		report.RobotUtilisation = report.TimeShare.Travelling + report.TimeShare.Lifting
+	}
+
+	for port, busy := range portTime {
+		report.PortUtilisation[port] = busy.Seconds() / duration.Seconds()
+	}
+
+	return report
+}
+
+// prune drops history older than the retention period, caller must hold the lock
+func (as *AnalyticsService) prune(now time.Time) {
+	cutoff := now.Add(-as.retention)
+
+	i := 0
+	for i < len(as.orders) && as.orders[i].completedAt.Before(cutoff) {
+		i++
+	}
+	as.orders = as.orders[i:]
+
+	i = 0
+	for i < len(as.spans) && as.spans[i].end.Before(cutoff) {
+		i++
+	}
+	as.spans = as.spans[i:]
+}
+
+// activityForStatus maps a robot status to its time-share activity
+func activityForStatus(status string) string {
+	switch status {
+	case "moving", "carrying", "returning":
+		return ActivityTravelling
+	case "picking", "dropping", "storing":
+		return ActivityLifting
+	case "waiting":
+		return ActivityWaiting
+	default:
+		return ActivityIdle
+	}
+}
+
+// overlapDuration returns how much of [aStart, aEnd) lies inside [bStart, bEnd)
+func overlapDuration(aStart, aEnd, bStart, bEnd time.Time) time.Duration {
+	if aStart.Before(bStart) {
+		aStart = bStart
+	}
+	if aEnd.After(bEnd) {
+		aEnd = bEnd
+	}
+	if !aEnd.After(aStart) {
+		return 0
+	}
+	return aEnd.Sub(aStart)
+}
+
+// mean returns the average of values, or 0 when empty
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// percentile returns the p-th percentile (0-1) using nearest rank, or 0 when empty
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"autostore-sim/backend/models"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	twenty := make([]float64, 20)
+	for i := range twenty {
+		twenty[i] = float64(20 - i)
+	}
+
+	tests := []struct {
+		name   string
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{"empty", nil, 0.95, 0},
+		{"single sample", []float64{42}, 0.95, 42},
+		{"single sample, lowest", []float64{42}, 0, 42},
+		{"nearest rank", twenty, 0.95, 19},
+		{"highest", twenty, 1, 20},
+		{"lowest", twenty, 0, 1},
+		{"median of an even count", []float64{4, 1, 3, 2}, 0.5, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.values, tt.p); got != tt.want {
+				t.Errorf("percentile %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if twenty[0] != 20 {
+		t.Error("percentile sorted its input")
+	}
+}
+
+func TestReportBetween(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	start, end := t0.Add(time.Hour), t0.Add(2*time.Hour)
+
+	// order completes at offset minutes from t0 after taking lead minutes
+	order := func(offset, lead int) models.Order {
+		completed := t0.Add(time.Duration(offset) * time.Minute)
+		return models.Order{RequestedQty: 2, CreatedAt: completed.Add(-time.Duration(lead) * time.Minute), CompletedAt: &completed}
+	}
+
+	tests := []struct {
+		name      string
+		orders    []models.Order
+		completed int
+		avgLead   float64 // Seconds
+		p95Lead   float64
+	}{
+		{name: "empty window", orders: []models.Order{order(30, 5), order(150, 5)}},
+		{name: "single sample", orders: []models.Order{order(90, 2)}, completed: 1, avgLead: 120, p95Lead: 120},
+		{
+			// Completion decides, an order created before the window counts with its whole lead time
+			name: "straddling the edges",
+			orders: []models.Order{
+				order(59, 1),  // Completed just before the window
+				order(60, 1),  // At the start, inside
+				order(65, 20), // Created before the window
+				order(119, 3), // Just inside the end
+				order(120, 1), // At the end, outside
+			},
+			completed: 3, avgLead: 480, p95Lead: 1200,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fixedClock{now: t0}
+			as := NewAnalyticsService(clock)
+			clock.now = t0.Add(3 * time.Hour)
+			for _, o := range tt.orders {
+				as.RecordOrderCompleted(o)
+			}
+
+			as.mu.Lock()
+			report := as.reportBetween(start, end)
+			as.mu.Unlock()
+
+			if report.OrdersCompleted != tt.completed || report.OrdersPerHour != float64(tt.completed) ||
+				report.UnitsPerHour != float64(2*tt.completed) || report.LinesPerHour != float64(tt.completed) {
+				t.Errorf("throughput %+v, want %d orders", report, tt.completed)
+			}
+			if report.AvgLeadTimeSeconds != tt.avgLead || report.P95LeadTimeSeconds != tt.p95Lead {
+				t.Errorf("lead time avg %v p95 %v, want %v and %v", report.AvgLeadTimeSeconds, report.P95LeadTimeSeconds, tt.avgLead, tt.p95Lead)
+			}
+			if report.RobotUtilisation != 0 || len(report.PortUtilisation) != 0 {
+				t.Errorf("utilisation without robots: %+v", report)
+			}
+		})
+	}
+}
+
+// TestReportBetweenClipsSpans checks that activity spans are cut at the window edges
+func TestReportBetweenClipsSpans(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	clock := &fixedClock{now: t0}
+	as := NewAnalyticsService(clock)
+
+	as.RegisterRobot(1) // Idle from t0
+	clock.now = t0.Add(30 * time.Minute)
+	as.RecordRobotUpdate(models.RobotUpdate{RobotID: 1, Status: "moving"})
+	clock.now = t0.Add(60 * time.Minute)
+	as.RecordRobotUpdate(models.RobotUpdate{RobotID: 1, Status: "dropping", X: 1})
+	clock.now = t0.Add(90 * time.Minute)
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	// 15 minutes moving, then the open drop clipped to 15 minutes
+	report := as.reportBetween(t0.Add(45*time.Minute), t0.Add(75*time.Minute))
+	share := report.TimeShare
+	if share.Travelling != 0.5 || share.Lifting != 0.5 || share.Idle != 0 || report.RobotUtilisation != 1 {
+		t.Errorf("time share %+v, utilisation %v", share, report.RobotUtilisation)
+	}
+	if got := fmt.Sprint(report.PortUtilisation); got != "map[1,0:0.5]" {
+		t.Errorf("port utilisation %s", got)
+	}
+
+	// A window with no length reports nothing instead of dividing by zero
+	empty := as.reportBetween(t0, t0)
+	if empty.OrdersPerHour != 0 || math.IsNaN(empty.TimeShare.Idle) || empty.RobotUtilisation != 0 {
+		t.Errorf("empty window %+v", empty)
+	}
+}
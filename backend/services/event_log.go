@@ -0,0 +1,82 @@
+package services
+
+import (
+	"autostore-sim/backend/models"
+	"sync"
+)
+
+// DefaultEventLogSize is how many events the log keeps before dropping the oldest
+const DefaultEventLogSize = 1000
+
+// EventLog keeps the most recent warehouse events and forwards new ones to listeners
+type EventLog struct {
+	mu     sync.Mutex
+	events []models.Event
+	counts map[models.EventType]int // All events ever recorded, including dropped ones
+	nextID int
+	limit  int
+	clock  models.Clock
+
+	// OnEvent receives every recorded event, e.g. for WebSocket broadcast
+	OnEvent func(models.Event) `json:"-"`
+}
+
+// NewEventLog creates an event log keeping up to DefaultEventLogSize events
+func NewEventLog(clock models.Clock) *EventLog {
+	return &EventLog{
+		counts: make(map[models.EventType]int),
+		nextID: 1,
+		limit:  DefaultEventLogSize,
+		clock:  clock,
+	}
+}
+
+// Record timestamps and stores an event, then notifies OnEvent
+func (el *EventLog) Record(event models.Event) models.Event {
+	el.mu.Lock()
+	event.ID = el.nextID
+	event.Time = el.clock.Now()
+	el.nextID++
+	el.counts[event.Type]++
+	el.events = append(el.events, event)
+	if len(el.events) > el.limit {
+		el.events = el.events[len(el.events)-el.limit:]
+	}
+	el.mu.Unlock()
+
+	if el.OnEvent != nil {
+		el.OnEvent(event)
+	}
+	return event
+}
+
+// Recent returns up to limit of the newest events, oldest first, optionally of one type
+func (el *EventLog) Recent(limit int, eventType models.EventType) []models.Event {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+
+	events := []models.Event{}
+	for i := len(el.events) - 1; i >= 0 && (limit <= 0 || len(events) < limit); i-- {
+		if eventType == "" || el.events[i].Type == eventType {
+			events = append(events, el.events[i])
+		}
+	}
+
+	// Collected newest first
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events
+}
+
+// Counts returns how many events of each type were recorded since start
+func (el *EventLog) Counts() map[models.EventType]int {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+
+	counts := make(map[models.EventType]int, len(el.counts))
+	for eventType, n := range el.counts {
+		counts[eventType] = n
+	}
+	return counts
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"autostore-sim/backend/models"
+	"fmt"
+	"sync"
+)
+
+// Stock levels tracked per product so events fire on changes only
+const (
+	stockOK  = "ok"
+	stockLow = "low"
+	stockOut = "out"
+)
+
+// InventoryMonitor watches total stock per product against reorder points
+type InventoryMonitor struct {
+	mu             sync.Mutex
+	productService *ProductService
+	slotting       *SlottingService
+	receiving      *ReceivingService
+	events         *EventLog
+	levels         map[int]string // Last seen stock level per product
+
+	// AutoReorder raises receipts topping products up to their target level
+	AutoReorder bool
+}
+
+// NewInventoryMonitor creates a monitor reporting to the event log
+func NewInventoryMonitor(productService *ProductService, slotting *SlottingService,
+	receiving *ReceivingService, events *EventLog) *InventoryMonitor {
+	return &InventoryMonitor{
+		productService: productService,
+		slotting:       slotting,
+		receiving:      receiving,
+		events:         events,
+		levels:         make(map[int]string),
+	}
+}
+
+// Check compares stock with reorder points, raising events and receipts where needed
+func (im *InventoryMonitor) Check() {
+	stock := im.slotting.StockByProduct()
+	inbound := im.receiving.InboundByProduct()
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	for _, product := range sortedByID(im.productService.GetAllProducts()) {
+		onHand := stock[product.ID]
+		level := stockLevel(product, onHand)
+
+		previous, seen := im.levels[product.ID]
+		if !seen {
+			previous = stockOK
+		}
+		im.levels[product.ID] = level
+		if level != previous {
+			im.record(levelEvent(level), product, onHand, inbound[product.ID], 0)
+		}
+
+		if im.AutoReorder && product.ReorderPoint > 0 && onHand+inbound[product.ID] <= product.ReorderPoint {
+			im.reorder(product, onHand, inbound[product.ID])
+		}
+	}
+}
+
+// StockSummary totals stock across products and counts those at a low or out level
+type StockSummary struct {
+	Products     int `json:"products"`
+	UnitsOnHand  int `json:"units_on_hand"` // In the grid and on robots
+	UnitsInbound int `json:"units_inbound"` // On open receipts
+	LowStock     int `json:"low_stock"`
+	OutOfStock   int `json:"out_of_stock"`
+}
+
+// Summary computes stock levels now rather than at the last Check
+func (im *InventoryMonitor) Summary() StockSummary {
+	stock := im.slotting.StockByProduct()
+	inbound := im.receiving.InboundByProduct()
+
+	var summary StockSummary
+	for _, product := range im.productService.GetAllProducts() {
+		summary.Products++
+		summary.UnitsOnHand += stock[product.ID]
+		summary.UnitsInbound += inbound[product.ID]
+		switch stockLevel(product, stock[product.ID]) {
+		case stockLow:
+			summary.LowStock++
+		case stockOut:
+			summary.OutOfStock++
+		}
+	}
+	return summary
+}
+
+// stockLevel classifies a product's stock against its reorder point
+func stockLevel(product *models.Product, onHand int) string {
+	switch {
+	case onHand == 0:
+		return stockOut
+	case onHand <= product.ReorderPoint:
+		return stockLow
+	}
+	return stockOK
+}
+
+// reorder raises a receipt bringing a product up to its target level, caller must hold the lock
+func (im *InventoryMonitor) reorder(product *models.Product, onHand, inbound int) {
+	target := product.TargetLevel
+	if target <= product.ReorderPoint {
+		target = 2 * product.ReorderPoint // No sensible target configured
+	}
+
+	receipt, err := im.receiving.CreateReceipt(product.ID, target-onHand-inbound, models.ReceiptSourceReorder)
+	if err != nil {
+		return
+	}
+	im.record(models.EventReorder, product, onHand, inbound+receipt.Quantity, receipt.ID)
+}
+
+// record writes a stock event to the log
+func (im *InventoryMonitor) record(eventType models.EventType, product *models.Product, onHand, inbound, receiptID int) {
+	var message string
+	switch eventType {
+	case models.EventOutOfStock:
+		message = fmt.Sprintf("%s is out of stock", product.Name)
+	case models.EventLowStock:
+		message = fmt.Sprintf("%s is low on stock: %d left, reorder point %d", product.Name, onHand, product.ReorderPoint)
+	case models.EventStockRestored:
+		message = fmt.Sprintf("%s is back in stock: %d on hand", product.Name, onHand)
+	case models.EventReorder:
+		message = fmt.Sprintf("Reordered %s: receipt %d tops up to %d", product.Name, receiptID, onHand+inbound)
+	}
+
+	im.events.Record(models.Event{
+		Type:      eventType,
+		Message:   message,
+		ProductID: product.ID,
+		Stock:     onHand,
+		Inbound:   inbound,
+		ReceiptID: receiptID,
+	})
+}
+
+// levelEvent returns the event type announcing a new stock level
+func levelEvent(level string) models.EventType {
+	switch level {
+	case stockOut:
+		return models.EventOutOfStock
+	case stockLow:
+		return models.EventLowStock
+	default:
+		return models.EventStockRestored
+	}
+}
@@ -3,22 +3,38 @@ package services
 import (
 	"autostore-sim/backend/models"
 	"fmt"
+	"math"
 	"math/rand"
+	"sync"
+	"time"
 )
 
 // OrderService handles order processing and robot assignment
 type OrderService struct {
+	mu             sync.Mutex // Guards orderQueue, shared by API, processor and robots
 	orderQueue     *models.OrderQueue
 	productService *ProductService
 	warehouse      *models.SafeWarehouse
+	slotting       *SlottingService
+	clock          models.Clock
+
+	// OnOrderCompleted is called once an order has been delivered to its port
+	OnOrderCompleted func(models.Order) `json:"-"`
+	// OnOrderChanged is called with a copy of each order created or changing status, outside the lock
+	OnOrderChanged func(models.Order) `json:"-"`
+	// HasInboundStock reports whether stock for a product is being received, orders then wait instead of failing
+	HasInboundStock func(productID int) bool `json:"-"`
 }
 
 // NewOrderService creates a new order service
-func NewOrderService(productService *ProductService, warehouse *models.SafeWarehouse) *OrderService {
+func NewOrderService(productService *ProductService, warehouse *models.SafeWarehouse,
+	slotting *SlottingService, clock models.Clock) *OrderService {
 	return &OrderService{
-		orderQueue:     models.NewOrderQueue(),
+		orderQueue:     models.NewOrderQueue(clock),
 		productService: productService,
 		warehouse:      warehouse,
+		slotting:       slotting,
+		clock:          clock,
 	}
 }
 
@@ -31,13 +47,13 @@ func (os *OrderService) GenerateRandomOrder() *models.Order {
 		"Precision Automotive", "Express Auto Repair",
 	}
 
-	// Get random product from catalog
+	// Get random product from catalog, fast movers are ordered more often
 	products := os.productService.GetAllProducts()
 	if len(products) == 0 {
 		return nil
 	}
 
-	randomProduct := products[rand.Intn(len(products))]
+	randomProduct := pickByVelocity(products)
 	randomCustomer := customers[rand.Intn(len(customers))]
 
 	// Random quantity (1-5 items for realistic orders)
@@ -55,36 +71,73 @@ func (os *OrderService) GenerateRandomOrder() *models.Order {
 		priority = models.PriorityExpress
 	}
 
-	return os.orderQueue.AddOrder(randomCustomer, randomProduct.ID, requestedQty, priority)
+	return os.addOrder(randomCustomer, randomProduct.ID, requestedQty, priority)
 }
 
-// AssignAvailablePort assigns a delivery port for the order
-func (os *OrderService) AssignAvailablePort() models.Position {
-	// Use north edge ports (y=0) - randomly pick one
-	portX := rand.Intn(os.warehouse.Width) // 0-7 for 8x8 warehouse
+// pickByVelocity draws a product with probability proportional to its velocity
+func pickByVelocity(products []*models.Product) *models.Product {
+	total := 0.0
+	for _, product := range products {
+		total += productVelocity(product)
+	}
+
+	roll := rand.Float64() * total
+	for _, product := range products {
+		roll -= productVelocity(product)
+		if roll < 0 {
+			return product
+		}
+	}
+	return products[len(products)-1]
+}
+
+// AssignAvailablePort assigns a delivery port the robot can reach
+func (os *OrderService) AssignAvailablePort(robot *models.Robot) models.Position {
+	// Use north edge ports (y=0) - randomly pick one, wide robots cannot reach the last ones
+	reachable := os.warehouse.Width - robot.RobotType().Footprint + 1
+	portX := rand.Intn(reachable) // 0-7 for 8x8 warehouse
 	return models.Position{X: portX, Y: 0, Z: 0}
 }
 
 // ProcessPendingOrders assigns robots to pending orders
 func (os *OrderService) ProcessPendingOrders(robots []*models.Robot) {
+	os.mu.Lock()
 	pendingOrders := os.orderQueue.GetPendingOrders()
 
+	// Robots handed an order in this pass still report idle until they start
+	assigned := make(map[int]bool)
+	var dispatches []robotDispatch
+	var changed []models.Order
+
 	for _, order := range pendingOrders {
-		// Find available robot
-		availableRobot := os.findAvailableRobot(robots)
-		if availableRobot == nil {
+		if findIdleRobot(robots, assigned, models.Position{}, nil) == nil {
 			continue // No robots available
 		}
 
 		// Find product in warehouse
 		productLocation := os.findProductInWarehouse(order.ProductID, order.RequestedQty)
 		if productLocation == nil {
+			if os.HasInboundStock != nil && os.HasInboundStock(order.ProductID) {
+				continue // Wait for the receipt to be put away
+			}
 			// Mark order as failed - no stock
 			os.updateOrderStatus(order.ID, models.OrderFailed)
+			if failed := os.orderQueue.GetOrderByID(order.ID); failed != nil {
+				changed = append(changed, *failed)
+			}
 			fmt.Printf("Order %d failed - insufficient stock for product %d\n", order.ID, order.ProductID)
 			continue
 		}
 
+		// Fastest idle robot that can reach the stack and lift the bin
+		location := *productLocation
+		availableRobot := findIdleRobot(robots, assigned, location, func(robot *models.Robot) bool {
+			return os.slotting.CanHandle(robot, location, 0)
+		})
+		if availableRobot == nil {
+			continue // Only robots of the wrong type are free
+		}
+
 		// Get actual order pointer from queue (not the loop copy)
 		actualOrder := os.orderQueue.GetOrderByID(order.ID)
 		if actualOrder == nil {
@@ -92,21 +145,54 @@ func (os *OrderService) ProcessPendingOrders(robots []*models.Robot) {
 		}
 
 		// Assign robot and update order
-		os.assignRobotToOrder(availableRobot, actualOrder, *productLocation)
+		commands, err := os.assignRobotToOrder(availableRobot, actualOrder, *productLocation)
+		if err != nil {
+			fmt.Printf("Order %d not assigned: %v\n", order.ID, err)
+			continue
+		}
+		dispatches = append(dispatches, robotDispatch{robot: availableRobot, commands: commands})
+		assigned[availableRobot.ID] = true
+		changed = append(changed, *actualOrder)
 	}
+	os.mu.Unlock()
+	os.notifyChanged(changed...)
+
+	// Send commands without holding the lock, robots report back via HandleRobotUpdate
+	for _, dispatch := range dispatches {
+		for _, cmd := range dispatch.commands {
+			dispatch.robot.Commands <- cmd
+		}
+	}
+}
+
+// robotDispatch holds commands queued for a robot during a processing pass
+type robotDispatch struct {
+	robot    *models.Robot
+	commands []models.RobotCommand
 }
 
-// findAvailableRobot returns first idle robot
-func (os *OrderService) findAvailableRobot(robots []*models.Robot) *models.Robot {
+// findIdleRobot returns the idle robot with no queued work, not already assigned and capable of
+// the job, that reaches target soonest. A nil capable accepts every robot.
+func findIdleRobot(robots []*models.Robot, assigned map[int]bool, target models.Position,
+	capable func(*models.Robot) bool) *models.Robot {
+	var best *models.Robot
+	var bestTime time.Duration
 	for _, robot := range robots {
-		if robot.Status == "idle" {
-			return robot
+		// A robot is briefly idle between dropping a bin and returning it
+		if !robot.IsIdle() || assigned[robot.ID] {
+			continue
+		}
+		if capable != nil && !capable(robot) {
+			continue
+		}
+		if travelTime := robot.TravelTimeTo(target); best == nil || travelTime < bestTime {
+			best, bestTime = robot, travelTime
 		}
 	}
-	return nil
+	return best
 }
 
-// findProductInWarehouse locates product with sufficient quantity
+// findProductInWarehouse locates product with sufficient quantity in a bin nobody else is fetching
 func (os *OrderService) findProductInWarehouse(productID int, requiredQty int) *models.Position {
 	// Search through warehouse grid for this product
 	for x := 0; x < os.warehouse.Width; x++ {
@@ -116,8 +202,9 @@ func (os *OrderService) findProductInWarehouse(productID int, requiredQty int) *
 				cell := os.warehouse.Grid[x][y][z]
 				os.warehouse.Mutex.RUnlock()
 
-				if cell.CanFulfill(productID, requiredQty) {
-					return &models.Position{X: x, Y: y, Z: z}
+				pos := models.Position{X: x, Y: y, Z: z}
+				if cell.CanFulfill(productID, requiredQty) && !os.slotting.IsReserved(pos) {
+					return &pos
 				}
 			}
 		}
@@ -125,28 +212,48 @@ func (os *OrderService) findProductInWarehouse(productID int, requiredQty int) *
 	return nil // Product not found or insufficient quantity
 }
 
-// assignRobotToOrder sends pick command to robot
-func (os *OrderService) assignRobotToOrder(robot *models.Robot, order *models.Order, productLocation models.Position) {
+// assignRobotToOrder updates the order and returns the pick, delivery and return commands for the robot
+func (os *OrderService) assignRobotToOrder(robot *models.Robot, order *models.Order, productLocation models.Position) ([]models.RobotCommand, error) {
 	// Assign delivery port
-	order.DeliveryPort = os.AssignAvailablePort()
+	port := os.AssignAvailablePort(robot)
+
+	// Reserve the bin and the slot it goes back to after the port
+	pickCommand, storeCommand, err := os.slotting.PlanRetrieval(productLocation, port, robot.RobotType())
+	if err != nil {
+		return nil, err
+	}
+	pickCommand.OrderID = order.ID
+	storeCommand.OrderID = order.ID
+	pickCommand.Priority = order.Priority.Rank()
+	storeCommand.Priority = order.Priority.Rank()
+
+	// The bin goes back lighter by the units picked at the port
+	if product := os.productService.GetProductByID(order.ProductID); product != nil {
+		storeCommand.PayloadKg = math.Max(0, storeCommand.PayloadKg-float64(order.RequestedQty)*product.Weight)
+	}
+
+	order.DeliveryPort = port
 	order.AssignedRobot = robot.ID
 	os.updateOrderStatus(order.ID, models.OrderAssigned)
 
-	// Send pick command to robot
-	pickCommand := models.RobotCommand{
-		Type:    "pick",
-		X:       productLocation.X,
-		Y:       productLocation.Y,
-		Z:       productLocation.Z,
-		OrderID: order.ID,
+	// Carry the bin to the delivery port
+	dropCommand := models.RobotCommand{
+		Type:      "drop",
+		X:         order.DeliveryPort.X,
+		Y:         order.DeliveryPort.Y,
+		Z:         order.DeliveryPort.Z,
+		OrderID:   order.ID,
+		PayloadKg: pickCommand.PayloadKg,
+		Priority:  order.Priority.Rank(),
 	}
 
-	robot.Commands <- pickCommand
-	fmt.Printf("Assigned Order %d to Robot %d - pick from (%d,%d,%d)\n",
-		order.ID, robot.ID, productLocation.X, productLocation.Y, productLocation.Z)
+	fmt.Printf("Assigned Order %d to Robot %d - pick from (%d,%d,%d), digging %d, return to (%d,%d,%d)\n",
+		order.ID, robot.ID, productLocation.X, productLocation.Y, productLocation.Z, pickCommand.Digs,
+		storeCommand.X, storeCommand.Y, storeCommand.Z)
+	return []models.RobotCommand{pickCommand, dropCommand, storeCommand}, nil
 }
 
-// updateOrderStatus updates order status
+// updateOrderStatus updates order status, caller must hold the lock
 func (os *OrderService) updateOrderStatus(orderID int, status models.OrderStatus) {
 	order := os.orderQueue.GetOrderByID(orderID)
 	if order != nil {
@@ -154,9 +261,96 @@ func (os *OrderService) updateOrderStatus(orderID int, status models.OrderStatus
 	}
 }
 
+// HandleRobotUpdate advances the order lifecycle from robot status updates
+func (os *OrderService) HandleRobotUpdate(update models.RobotUpdate) {
+	if update.OrderID == 0 {
+		return
+	}
+
+	os.mu.Lock()
+	order := os.orderQueue.GetOrderByID(update.OrderID)
+	if order == nil || order.Status == models.OrderCompleted {
+		os.mu.Unlock()
+		return
+	}
+
+	var completed *models.Order
+	previous := order.Status
+
+	switch {
+	case update.Command == "pick" && update.Status == "picking":
+		order.Status = models.OrderPicking
+	case update.Command == "pick" && update.Status == "carrying":
+		order.Status = models.OrderDelivering
+	case update.Command == "drop" && update.Status == "idle":
+		// Robot finished dropping the bin at the delivery port, the picker takes the items
+		os.slotting.TakeFromCarried(update.RobotID, order.ProductID, order.RequestedQty)
+
+		completedAt := os.clock.Now()
+		order.Status = models.OrderCompleted
+		order.CompletedAt = &completedAt
+		fmt.Printf("Order %d completed by Robot %d\n", order.ID, update.RobotID)
+
+		orderCopy := *order
+		completed = &orderCopy
+	}
+	changed := *order
+	os.mu.Unlock()
+
+	if changed.Status != previous {
+		os.notifyChanged(changed)
+	}
+
+	if completed != nil && os.OnOrderCompleted != nil {
+		os.OnOrderCompleted(*completed)
+	}
+}
+
+// PendingCount returns how many orders are waiting for a robot
+func (os *OrderService) PendingCount() int {
+	os.mu.Lock()
+	defer os.mu.Unlock()
+	return len(os.orderQueue.GetPendingOrders())
+}
+
+// QueueSummary counts orders by status and the pending ones by priority
+type QueueSummary struct {
+	Total             int                        `json:"total"`
+	Active            int                        `json:"active"` // Neither completed nor failed
+	ByStatus          map[models.OrderStatus]int `json:"by_status"`
+	PendingByPriority map[models.Priority]int    `json:"pending_by_priority"`
+}
+
+// Summary counts the orders in one pass under the lock
+func (os *OrderService) Summary() QueueSummary {
+	os.mu.Lock()
+	defer os.mu.Unlock()
+
+	summary := QueueSummary{
+		Total:    len(os.orderQueue.Orders),
+		ByStatus: make(map[models.OrderStatus]int),
+		PendingByPriority: map[models.Priority]int{
+			models.PriorityNormal: 0, models.PriorityUrgent: 0, models.PriorityExpress: 0,
+		},
+	}
+	for _, order := range os.orderQueue.Orders {
+		summary.ByStatus[order.Status]++
+		if order.Status != models.OrderCompleted && order.Status != models.OrderFailed {
+			summary.Active++
+		}
+		if order.Status == models.OrderPending {
+			summary.PendingByPriority[order.Priority]++
+		}
+	}
+	return summary
+}
+
 // GetActiveOrders returns all non-completed orders
 func (os *OrderService) GetActiveOrders() []models.Order {
-	var active []models.Order
+	os.mu.Lock()
+	defer os.mu.Unlock()
+
+	active := []models.Order{}
 	for _, order := range os.orderQueue.Orders {
 		if order.Status != models.OrderCompleted && order.Status != models.OrderFailed {
 			active = append(active, order)
@@ -165,6 +359,14 @@ func (os *OrderService) GetActiveOrders() []models.Order {
 	return active
 }
 
+// GetAllOrders returns a copy of every order, including completed and failed ones
+func (os *OrderService) GetAllOrders() []models.Order {
+	os.mu.Lock()
+	defer os.mu.Unlock()
+
+	return append([]models.Order{}, os.orderQueue.Orders...)
+}
+
 // CreateOrder creates a new order and adds it to the queue
 func (os *OrderService) CreateOrder(customerName string, productID int, requestedQty int, priority models.Priority) *models.Order {
 	// Validate product exists
@@ -173,6 +375,26 @@ func (os *OrderService) CreateOrder(customerName string, productID int, requeste
 		return nil
 	}
 
-	// Add order through the OrderQueue (which handles ID generation and initialization)
-	return os.orderQueue.AddOrder(customerName, productID, requestedQty, priority)
+	return os.addOrder(customerName, productID, requestedQty, priority)
+}
+
+// addOrder queues an order through the OrderQueue, which handles ID generation and initialization
+func (os *OrderService) addOrder(customerName string, productID int, requestedQty int, priority models.Priority) *models.Order {
+	os.mu.Lock()
+	order := os.orderQueue.AddOrder(customerName, productID, requestedQty, priority)
+	created := *order
+	os.mu.Unlock()
+
+	os.notifyChanged(created)
+	return order
+}
+
+// notifyChanged reports changed orders to the listener, caller must not hold the lock
+func (os *OrderService) notifyChanged(orders ...models.Order) {
+	if os.OnOrderChanged == nil {
+		return
+	}
+	for _, order := range orders {
+		os.OnOrderChanged(order)
+	}
 }
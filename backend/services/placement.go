@@ -0,0 +1,287 @@
+package services
+
+import (
+	"autostore-sim/backend/models"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+)
+
+// Placement strategy names used in config files and flags
+const (
+	PlacementRandom   = "random"   // Shuffle products over all storage positions
+	PlacementVelocity = "velocity" // Fast movers near the top of stacks and close to ports
+	PlacementCategory = "category" // Each category clustered in its own block of stacks
+	PlacementLayout   = "layout"   // Explicit bin positions from a layout file
+)
+
+// Cost model constants for digging estimates
+const (
+	digSecondsPerBin   = 4.0   // Lift a blocking bin off the stack and set it down elsewhere
+	cellTravelSeconds  = 0.19  // Average cell pitch (~0.59m) at 3.1 m/s
+	levelTravelSeconds = 0.206 // One bin height (0.33m) at 1.6 m/s
+)
+
+// PlacementContext is what a placement strategy knows about the warehouse
+type PlacementContext struct {
+	Positions []models.Position         // Storage positions, excluding the port row
+	Ports     []models.Position         // Delivery ports bins travel to
+	Quantity  func(*models.Product) int // Initial stock for a product's bin
+	Rand      *rand.Rand
+}
+
+// PlacementStrategy decides which bin position each product starts in
+type PlacementStrategy interface {
+	Name() string
+	Plan(products []*models.Product, ctx PlacementContext) ([]models.BinPlacement, error)
+}
+
+// LayoutCost estimates how expensive a layout is to pick from, weighted by product velocity
+type LayoutCost struct {
+	Strategy             string  `json:"strategy"`
+	Bins                 int     `json:"bins"`
+	ExpectedDigs         float64 `json:"expected_digs"`          // Bins to move out of the way per pick
+	ExpectedPortDistance float64 `json:"expected_port_distance"` // Grid cells to the nearest port per pick
+	ExpectedLevels       float64 `json:"expected_levels"`        // Levels the gripper travels per pick
+	SecondsPerPick       float64 `json:"seconds_per_pick"`       // Estimated handling time per pick
+}
+
+// ParsePlacementStrategy returns the strategy for a config name
+func ParsePlacementStrategy(name, layoutFile string) (PlacementStrategy, error) {
+	switch name {
+	case "", PlacementRandom:
+		return RandomPlacement{}, nil
+	case PlacementVelocity:
+		return VelocityPlacement{}, nil
+	case PlacementCategory:
+		return CategoryPlacement{}, nil
+	case PlacementLayout:
+		if layoutFile == "" {
+			return nil, fmt.Errorf("placement %q needs a layout file", name)
+		}
+		layout, err := LoadLayoutFile(layoutFile)
+		if err != nil {
+			return nil, err
+		}
+		return LayoutPlacement{Layout: layout}, nil
+	default:
+		return nil, fmt.Errorf("unknown placement strategy %q (supported: random, velocity, category, layout)", name)
+	}
+}
+
+// RandomPlacement shuffles products over the storage positions
+type RandomPlacement struct{}
+
+// Name returns the strategy name
+func (RandomPlacement) Name() string { return PlacementRandom }
+
+// Plan assigns each product a random free position
+func (RandomPlacement) Plan(products []*models.Product, ctx PlacementContext) ([]models.BinPlacement, error) {
+	positions := append([]models.Position(nil), ctx.Positions...)
+	ctx.Rand.Shuffle(len(positions), func(i, j int) {
+		positions[i], positions[j] = positions[j], positions[i]
+	})
+
+	// Catalog order is map order, sort so a seeded run is reproducible
+	products = sortedByID(products)
+	return assignInOrder(products, positions, ctx), nil
+}
+
+// VelocityPlacement puts the fastest movers in the cheapest positions
+type VelocityPlacement struct{}
+
+// Name returns the strategy name
+func (VelocityPlacement) Name() string { return PlacementVelocity }
+
+// Plan sorts products by velocity and positions by access cost, then pairs them up
+func (VelocityPlacement) Plan(products []*models.Product, ctx PlacementContext) ([]models.BinPlacement, error) {
+	products = sortedByVelocity(products)
+
+	positions := append([]models.Position(nil), ctx.Positions...)
+	sort.SliceStable(positions, func(i, j int) bool {
+		return accessSeconds(positions[i], ctx.Ports) < accessSeconds(positions[j], ctx.Ports)
+	})
+
+	return assignInOrder(products, positions, ctx), nil
+}
+
+// CategoryPlacement gives each category a block of neighbouring stacks
+type CategoryPlacement struct{}
+
+// Name returns the strategy name
+func (CategoryPlacement) Name() string { return PlacementCategory }
+
+// Plan splits the grid into one column block per category and fills each block top-down
+func (CategoryPlacement) Plan(products []*models.Product, ctx PlacementContext) ([]models.BinPlacement, error) {
+	byCategory := make(map[models.Category][]*models.Product)
+	var categories []models.Category
+	for _, product := range products {
+		if _, ok := byCategory[product.Category]; !ok {
+			categories = append(categories, product.Category)
+		}
+		byCategory[product.Category] = append(byCategory[product.Category], product)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i] < categories[j] })
+
+	// Order positions by column so consecutive categories get neighbouring blocks
+	maxX := 0
+	for _, pos := range ctx.Positions {
+		if pos.X > maxX {
+			maxX = pos.X
+		}
+	}
+	blockOf := func(pos models.Position) int {
+		return pos.X * len(categories) / (maxX + 1)
+	}
+
+	used := make(map[models.Position]bool)
+	var layout []models.BinPlacement
+	var overflow []*models.Product
+	for block, category := range categories {
+		var blockPositions []models.Position
+		for _, pos := range ctx.Positions {
+			if blockOf(pos) == block {
+				blockPositions = append(blockPositions, pos)
+			}
+		}
+		// Within a block keep the usual rule: fast movers on top, near the ports
+		sort.SliceStable(blockPositions, func(i, j int) bool {
+			return accessSeconds(blockPositions[i], ctx.Ports) < accessSeconds(blockPositions[j], ctx.Ports)
+		})
+
+		members := sortedByVelocity(byCategory[category])
+		for i, product := range members {
+			if i >= len(blockPositions) {
+				overflow = append(overflow, members[i:]...)
+				break
+			}
+			used[blockPositions[i]] = true
+			layout = append(layout, binFor(product, blockPositions[i], ctx))
+		}
+	}
+
+	// Categories larger than their block spill over into whatever is left
+	var remaining []models.Position
+	for _, pos := range ctx.Positions {
+		if !used[pos] {
+			remaining = append(remaining, pos)
+		}
+	}
+	layout = append(layout, assignInOrder(overflow, remaining, ctx)...)
+
+	return layout, nil
+}
+
+// LayoutPlacement uses explicit bin positions, e.g. loaded from a layout file
+type LayoutPlacement struct {
+	Layout []models.BinPlacement
+}
+
+// Name returns the strategy name
+func (LayoutPlacement) Name() string { return PlacementLayout }
+
+// Plan returns the layout as given
+func (lp LayoutPlacement) Plan(products []*models.Product, ctx PlacementContext) ([]models.BinPlacement, error) {
+	return lp.Layout, nil
+}
+
+// LayoutData represents the JSON structure of a layout file
+type LayoutData struct {
+	Layout []models.BinPlacement `json:"layout"`
+}
+
+// LoadLayoutFile reads explicit bin placements from a JSON file
+func LoadLayoutFile(path string) ([]models.BinPlacement, error) {
+	jsonData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layout: %w", err)
+	}
+
+	var data LayoutData
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse layout JSON: %w", err)
+	}
+	return data.Layout, nil
+}
+
+// assignInOrder pairs products with positions one to one, warning when positions run out
+func assignInOrder(products []*models.Product, positions []models.Position, ctx PlacementContext) []models.BinPlacement {
+	if len(products) > len(positions) {
+		fmt.Printf("Warning: More products (%d) than available storage positions (%d)\n",
+			len(products), len(positions))
+		products = products[:len(positions)]
+	}
+
+	layout := make([]models.BinPlacement, 0, len(products))
+	for i, product := range products {
+		layout = append(layout, binFor(product, positions[i], ctx))
+	}
+	return layout
+}
+
+// binFor creates a placement of a product's initial stock at pos
+func binFor(product *models.Product, pos models.Position, ctx PlacementContext) models.BinPlacement {
+	return models.BinPlacement{
+		ProductID: product.ID,
+		X:         pos.X,
+		Y:         pos.Y,
+		Z:         pos.Z,
+		Quantity:  ctx.Quantity(product),
+	}
+}
+
+// sortedByID returns products in ascending ID order
+func sortedByID(products []*models.Product) []*models.Product {
+	sorted := append([]*models.Product(nil), products...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
+
+// sortedByVelocity returns products fastest first, ties by ID
+func sortedByVelocity(products []*models.Product) []*models.Product {
+	sorted := sortedByID(products)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return productVelocity(sorted[i]) > productVelocity(sorted[j])
+	})
+	return sorted
+}
+
+// productVelocity returns a product's relative demand, treating unknown as 1
+func productVelocity(product *models.Product) float64 {
+	if product.Velocity <= 0 {
+		return 1
+	}
+	return product.Velocity
+}
+
+// portDistance returns the grid distance from a stack to the nearest port
+func portDistance(pos models.Position, ports []models.Position) int {
+	best := -1
+	for _, port := range ports {
+		d := abs(pos.X-port.X) + abs(pos.Y-port.Y)
+		if best < 0 || d < best {
+			best = d
+		}
+	}
+	if best < 0 {
+		return 0
+	}
+	return best
+}
+
+// accessSeconds estimates handling time for a bin at pos assuming every level above is full
+func accessSeconds(pos models.Position, ports []models.Position) float64 {
+	return float64(pos.Z)*digSecondsPerBin +
+		float64(pos.Z)*levelTravelSeconds +
+		float64(portDistance(pos, ports))*cellTravelSeconds
+}
+
+// abs returns absolute value of integer
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -7,11 +7,13 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 )
 
 // ProductService handles product-related operations
 type ProductService struct {
 	catalog *models.ProductCatalog
+	rng     *rand.Rand // Seeded source for reproducible placement, global rand when nil
 }
 
 // ProductData represents the JSON structure from the data file
@@ -30,8 +32,11 @@ func NewProductService() *ProductService {
 // In real system, this would be: LoadProductsFromAPI()
 func (ps *ProductService) LoadProductsFromFile(filename string) error {
 	// Get path for the JSON file
-	dataPath := filepath.Join("data", filename)
+	return ps.LoadProductsFromPath(filepath.Join("data", filename))
+}
 
+// LoadProductsFromPath loads the product catalog from a JSON file at any path
+func (ps *ProductService) LoadProductsFromPath(dataPath string) error {
 	// Read the JSON file
 	jsonData, err := os.ReadFile(dataPath)
 	if err != nil {
@@ -55,7 +60,7 @@ func (ps *ProductService) LoadProductsFromFile(filename string) error {
 		}
 	}
 
-	fmt.Printf("Loaded %d products from %s\n", len(data.Products), filename)
+	fmt.Printf("Loaded %d products from %s\n", len(data.Products), dataPath)
 	return nil
 }
 
@@ -78,49 +83,246 @@ func (ps *ProductService) GetProductsByCategory(category models.Category) []*mod
 	return ps.catalog.GetProductsByCategory(category)
 }
 
+// SetSeed makes placement and initial quantities reproducible
+func (ps *ProductService) SetSeed(seed int64) {
+	ps.rng = rand.New(rand.NewSource(seed))
+}
+
 // PlaceProductsInWarehouse randomly assigning products to warehouse positions
 func (ps *ProductService) PlaceProductsInWarehouse(warehouse *models.SafeWarehouse) error {
-	products := ps.GetAllProducts()
+	_, err := ps.PlaceProducts(warehouse, RandomPlacement{})
+	return err
+}
+
+// PlaceProducts fills the warehouse using a placement strategy and reports the layout's cost
+func (ps *ProductService) PlaceProducts(warehouse *models.SafeWarehouse, strategy PlacementStrategy) (LayoutCost, error) {
+	ctx := PlacementContext{
+		// Get all available positions excluding edge positions for ports
+		Positions: ps.getStoragePositions(warehouse),
+		Ports:     ps.getPortPositions(warehouse),
+		Quantity: func(product *models.Product) int {
+			// Generate realistic quantity based on product category
+			return ps.getRealisticQuantity(product.Category)
+		},
+		Rand: ps.random(),
+	}
+
+	layout, err := strategy.Plan(ps.GetAllProducts(), ctx)
+	if err != nil {
+		return LayoutCost{}, fmt.Errorf("%s placement failed: %w", strategy.Name(), err)
+	}
+	if err := ps.PlaceProductsFromLayout(warehouse, layout); err != nil {
+		return LayoutCost{}, err
+	}
+
+	cost := ps.EvaluateLayout(warehouse)
+	cost.Strategy = strategy.Name()
+	fmt.Printf("Layout %s: %.2f expected digs, %.2f cells to port, ~%.1fs handling per pick\n",
+		cost.Strategy, cost.ExpectedDigs, cost.ExpectedPortDistance, cost.SecondsPerPick)
+	return cost, nil
+}
+
+// PlaceProductsFromLayout fills the warehouse from an explicit list of bin placements
+func (ps *ProductService) PlaceProductsFromLayout(warehouse *models.SafeWarehouse, layout []models.BinPlacement) error {
+	warehouse.Mutex.Lock()
+	defer warehouse.Mutex.Unlock()
+
+	for i, placement := range layout {
+		product := ps.GetProductByID(placement.ProductID)
+		if product == nil {
+			return fmt.Errorf("layout entry %d: unknown product %d", i, placement.ProductID)
+		}
+		if !warehouse.IsValidPosition(placement.X, placement.Y, placement.Z) {
+			return fmt.Errorf("layout entry %d: position (%d, %d, %d) is outside the warehouse",
+				i, placement.X, placement.Y, placement.Z)
+		}
+		if placement.Quantity < 0 {
+			return fmt.Errorf("layout entry %d: negative quantity %d", i, placement.Quantity)
+		}
+
+		// Placements sharing a position fill different compartments of one bin
+		cell := &warehouse.Grid[placement.X][placement.Y][placement.Z]
+		if cell.BinID == "" {
+			binID := placement.BinID
+			if binID == "" {
+				binID = fmt.Sprintf("BIN-%04d", i+1)
+			}
+			*cell = models.NewBin(binID, warehouse.Compartments)
+		} else if placement.BinID != "" && placement.BinID != cell.BinID {
+			return fmt.Errorf("layout entry %d: position (%d, %d, %d) already holds %s",
+				i, placement.X, placement.Y, placement.Z, cell.BinID)
+		}
+
+		added, err := cell.AddToCompartment(placement.Compartment, product, placement.Quantity, ps.GetProductByID)
+		if err != nil {
+			return fmt.Errorf("layout entry %d: %s at (%d, %d, %d): %w",
+				i, cell.BinID, placement.X, placement.Y, placement.Z, err)
+		}
+		if added < placement.Quantity {
+			fmt.Printf("Warning: only %d of %d %s fit into %s compartment %d\n",
+				added, placement.Quantity, product.Name, cell.BinID, placement.Compartment)
+		}
+		product.Position = models.Position{X: placement.X, Y: placement.Y, Z: placement.Z}
 
-	// Get all available positions excluding edge positions for ports
-	availablePositions := ps.getStoragePositions(warehouse)
+		fmt.Printf("Placed %dx %s at position (%d, %d, %d) in %s\n",
+			added, product.Name, placement.X, placement.Y, placement.Z, cell.BinID)
+	}
+
+	return nil
+}
 
-	// Shuffle positions for random placement
-	rand.Shuffle(len(availablePositions), func(i, j int) {
-		availablePositions[i], availablePositions[j] = availablePositions[j], availablePositions[i]
+// AddEmptyBins stores empty bins in the least accessible free positions, ready for receiving
+func (ps *ProductService) AddEmptyBins(warehouse *models.SafeWarehouse, count int) int {
+	ports := ps.getPortPositions(warehouse)
+	positions := ps.getStoragePositions(warehouse)
+	sort.SliceStable(positions, func(i, j int) bool {
+		return accessSeconds(positions[i], ports) > accessSeconds(positions[j], ports)
 	})
 
-	// Place products in random positions
-	for i, product := range products {
-		if i >= len(availablePositions) {
-			fmt.Printf("Warning: More products (%d) than available storage positions (%d)\n",
-				len(products), len(availablePositions))
+	warehouse.Mutex.Lock()
+	defer warehouse.Mutex.Unlock()
+
+	added := 0
+	for _, pos := range positions {
+		if added == count {
 			break
 		}
+		cell := &warehouse.Grid[pos.X][pos.Y][pos.Z]
+		if cell.BinID != "" {
+			continue
+		}
+		added++
+		*cell = models.NewBin(fmt.Sprintf("SPARE-%04d", added), warehouse.Compartments)
+	}
+
+	if added < count {
+		fmt.Printf("Warning: only %d of %d empty bins fit into the grid\n", added, count)
+	}
+	return added
+}
+
+// BinInfo describes a bin and its contents for the API
+type BinInfo struct {
+	BinID           string            `json:"bin_id"`
+	Location        models.Position   `json:"location"`
+	Compartments    []CompartmentInfo `json:"compartments"`
+	CapacityLitres  float64           `json:"capacity_l"`
+	UsedLitres      float64           `json:"used_l"`
+	CurrentWeightKg float64           `json:"current_weight_kg"`
+	MaxWeightKg     float64           `json:"max_weight_kg"`
+}
 
-		position := availablePositions[i]
-		product.Position = position
+// CompartmentInfo is one compartment of a bin with its product details
+type CompartmentInfo struct {
+	Index     int    `json:"index"`
+	ProductID int    `json:"product_id,omitempty"`
+	SKU       string `json:"sku,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
 
-		// Generate realistic quantity based on product category
-		quantity := ps.getRealisticQuantity(product.Category)
+// DescribeBins lists every bin stored in the grid with its compartments, volume and weight
+func (ps *ProductService) DescribeBins(warehouse *models.SafeWarehouse) []BinInfo {
+	warehouse.Mutex.RLock()
+	defer warehouse.Mutex.RUnlock()
 
-		// Create StorageCell with product and quantity
-		storageCell := models.StorageCell{
-			ProductID: product.ID,
-			Quantity:  quantity,
-			BinID:     fmt.Sprintf("BIN-%04d", i+1), // BIN-0001, BIN-0002, etc.
+	var bins []BinInfo
+	for x := 0; x < warehouse.Width; x++ {
+		for y := 0; y < warehouse.Height; y++ {
+			for z := 0; z < warehouse.Levels; z++ {
+				cell := warehouse.Grid[x][y][z]
+				if cell.BinID == "" {
+					continue
+				}
+
+				info := BinInfo{
+					BinID:           cell.BinID,
+					Location:        models.Position{X: x, Y: y, Z: z},
+					CapacityLitres:  models.BinVolumeLitres,
+					CurrentWeightKg: cell.Weight(ps.GetProductByID),
+					MaxWeightKg:     models.MaxBinWeightKg,
+				}
+				for i, c := range cell.Compartments {
+					compartment := CompartmentInfo{Index: i, Quantity: c.Quantity}
+					if product := ps.GetProductByID(c.ProductID); product != nil && c.Quantity > 0 {
+						compartment.ProductID = product.ID
+						compartment.SKU = product.SKU
+						compartment.Name = product.Name
+						info.UsedLitres += float64(c.Quantity) * product.Volume
+					}
+					info.Compartments = append(info.Compartments, compartment)
+				}
+				bins = append(bins, info)
+			}
 		}
+	}
+	return bins
+}
 
-		// Place the StorageCell in warehouse grid (thread-safe)
-		warehouse.Mutex.Lock()
-		warehouse.Grid[position.X][position.Y][position.Z] = storageCell
-		warehouse.Mutex.Unlock()
+// EvaluateLayout estimates the digging and travel cost of picking from the current layout.
+// Each product is picked from its cheapest bin and weighted by its velocity.
+func (ps *ProductService) EvaluateLayout(warehouse *models.SafeWarehouse) LayoutCost {
+	ports := ps.getPortPositions(warehouse)
 
-		fmt.Printf("Placed %dx %s at position (%d, %d, %d) in %s\n",
-			quantity, product.Name, position.X, position.Y, position.Z, storageCell.BinID)
+	type binAccess struct {
+		digs, levels, distance int
+		seconds                float64
 	}
+	best := make(map[int]binAccess)
+	bins := 0
 
-	return nil
+	warehouse.Mutex.RLock()
+	for x := 0; x < warehouse.Width; x++ {
+		for y := 0; y < warehouse.Height; y++ {
+			digs := 0 // Bins stacked above the current level (z=0 is the top)
+			for z := 0; z < warehouse.Levels; z++ {
+				cell := warehouse.Grid[x][y][z]
+				if cell.BinID == "" {
+					continue
+				}
+				bins++
+
+				// Every product in the bin is picked from here
+				for _, productID := range cell.ProductIDs() {
+					distance := portDistance(models.Position{X: x, Y: y}, ports)
+					access := binAccess{
+						digs:     digs,
+						levels:   z,
+						distance: distance,
+						seconds: float64(digs)*digSecondsPerBin +
+							float64(z)*levelTravelSeconds +
+							float64(distance)*cellTravelSeconds,
+					}
+					if current, ok := best[productID]; !ok || access.seconds < current.seconds {
+						best[productID] = access
+					}
+				}
+				digs++
+			}
+		}
+	}
+	warehouse.Mutex.RUnlock()
+
+	cost := LayoutCost{Bins: bins}
+	totalWeight := 0.0
+	for productID, access := range best {
+		weight := 1.0
+		if product := ps.GetProductByID(productID); product != nil {
+			weight = productVelocity(product)
+		}
+		totalWeight += weight
+		cost.ExpectedDigs += weight * float64(access.digs)
+		cost.ExpectedLevels += weight * float64(access.levels)
+		cost.ExpectedPortDistance += weight * float64(access.distance)
+		cost.SecondsPerPick += weight * access.seconds
+	}
+	if totalWeight > 0 {
+		cost.ExpectedDigs /= totalWeight
+		cost.ExpectedLevels /= totalWeight
+		cost.ExpectedPortDistance /= totalWeight
+		cost.SecondsPerPick /= totalWeight
+	}
+	return cost
 }
 
 // getStoragePositions returns all positions excluding edge ports
@@ -143,27 +345,52 @@ func (ps *ProductService) getStoragePositions(warehouse *models.SafeWarehouse) [
 	return positions
 }
 
+// getPortPositions returns the delivery ports along the north edge (y=0)
+func (ps *ProductService) getPortPositions(warehouse *models.SafeWarehouse) []models.Position {
+	ports := make([]models.Position, 0, warehouse.Width)
+	for x := 0; x < warehouse.Width; x++ {
+		ports = append(ports, models.Position{X: x, Y: 0, Z: 0})
+	}
+	return ports
+}
+
 // GetProductCount returns total number of products
 func (ps *ProductService) GetProductCount() int {
 	return len(ps.catalog.Products)
 }
 
+// random returns the seeded source, or a fresh one when no seed was set
+func (ps *ProductService) random() *rand.Rand {
+	if ps.rng != nil {
+		return ps.rng
+	}
+	return rand.New(rand.NewSource(rand.Int63()))
+}
+
+// intn draws from the seeded source when set, global rand otherwise
+func (ps *ProductService) intn(n int) int {
+	if ps.rng != nil {
+		return ps.rng.Intn(n)
+	}
+	return rand.Intn(n)
+}
+
 // getRealisticQuantity returns realistic quantities based on product category
 func (ps *ProductService) getRealisticQuantity(category models.Category) int {
 	switch category {
 	case models.CategoryEngine:
-		return rand.Intn(11) + 20 // 20-30 items (spark plugs, filters)
+		return ps.intn(11) + 20 // 20-30 items (spark plugs, filters)
 	case models.CategoryBrakes:
-		return rand.Intn(6) + 10 // 10-15 items (heavier brake parts)
+		return ps.intn(6) + 10 // 10-15 items (heavier brake parts)
 	case models.CategoryElectrical:
-		return rand.Intn(16) + 25 // 25-40 items (light bulbs, fuses)
+		return ps.intn(16) + 25 // 25-40 items (light bulbs, fuses)
 	case models.CategoryFilters:
-		return rand.Intn(11) + 25 // 25-35 items (oil filters, air filters)
+		return ps.intn(11) + 25 // 25-35 items (oil filters, air filters)
 	case models.CategoryLighting:
-		return rand.Intn(11) + 15 // 15-25 items (bulbs, assemblies)
+		return ps.intn(11) + 15 // 15-25 items (bulbs, assemblies)
 	case models.CategoryMaintenance:
-		return rand.Intn(11) + 20 // 20-30 items (wiper blades, fluids)
+		return ps.intn(11) + 20 // 20-30 items (wiper blades, fluids)
 	default:
-		return rand.Intn(11) + 15 // 15-25 items (fallback)
+		return ps.intn(11) + 15 // 15-25 items (fallback)
 	}
 }
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"autostore-sim/backend/models"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// GoodsInHandlingTime is how long the operator at goods-in takes to fill a bin
+const GoodsInHandlingTime = 20 * time.Second
+
+// ReceivingService handles inbound receipts and puts stock away through goods-in workstations
+type ReceivingService struct {
+	mu             sync.Mutex // Guards receipts, shared by API, processor and robots
+	receipts       []models.Receipt
+	nextID         int
+	productService *ProductService
+	slotting       *SlottingService
+	goodsIn        []models.Position // Goods-in workstation ports
+	clock          models.Clock
+}
+
+// NewReceivingService creates a receiving service putting stock away through the goods-in workstations
+func NewReceivingService(productService *ProductService, slotting *SlottingService,
+	workstations []models.Workstation, clock models.Clock) *ReceivingService {
+	var goodsIn []models.Position
+	for _, ws := range workstations {
+		if ws.Type == models.WorkstationGoodsIn {
+			goodsIn = append(goodsIn, models.Position{X: ws.X, Y: ws.Y, Z: 0})
+		}
+	}
+
+	return &ReceivingService{
+		nextID:         1,
+		productService: productService,
+		slotting:       slotting,
+		goodsIn:        goodsIn,
+		clock:          clock,
+	}
+}
+
+// CreateReceipt records inbound stock for a product
+func (rs *ReceivingService) CreateReceipt(productID, quantity int, source models.ReceiptSource) (*models.Receipt, error) {
+	if rs.productService.GetProductByID(productID) == nil {
+		return nil, fmt.Errorf("unknown product %d", productID)
+	}
+	if quantity <= 0 {
+		return nil, fmt.Errorf("quantity %d is not positive", quantity)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.addReceipt(productID, quantity, source), nil
+}
+
+// addReceipt appends a pending receipt, caller must hold the lock
+func (rs *ReceivingService) addReceipt(productID, quantity int, source models.ReceiptSource) *models.Receipt {
+	receipt := models.Receipt{
+		ID:        rs.nextID,
+		ProductID: productID,
+		Quantity:  quantity,
+		Source:    source,
+		Status:    models.ReceiptPending,
+		CreatedAt: rs.clock.Now(),
+		Bin:       models.Position{X: -1, Y: -1, Z: -1}, // Will be assigned later
+	}
+	rs.receipts = append(rs.receipts, receipt)
+	rs.nextID++
+
+	fmt.Printf("Receipt %d created (%s): %d units of product %d\n", receipt.ID, source, quantity, productID)
+	return &receipt
+}
+
+// InboundByProduct totals the units of receipts not yet put away, by product
+func (rs *ReceivingService) InboundByProduct() map[int]int {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	inbound := make(map[int]int)
+	for _, receipt := range rs.receipts {
+		inbound[receipt.ProductID] += receipt.Remaining()
+	}
+	return inbound
+}
+
+// HasInboundStock reports whether a receipt for the product is still being put away
+func (rs *ReceivingService) HasInboundStock(productID int) bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	for _, receipt := range rs.receipts {
+		if receipt.ProductID == productID && receipt.Status != models.ReceiptReceived {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessPendingReceipts sends idle robots to fetch bins for pending receipts
+func (rs *ReceivingService) ProcessPendingReceipts(robots []*models.Robot) {
+	if len(rs.goodsIn) == 0 {
+		return
+	}
+
+	rs.mu.Lock()
+	assigned := make(map[int]bool)
+	var dispatches []robotDispatch
+
+	for i := range rs.receipts {
+		receipt := &rs.receipts[i]
+		if receipt.Status != models.ReceiptPending {
+			continue
+		}
+
+		if findIdleRobot(robots, assigned, models.Position{}, nil) == nil {
+			break // No robots available
+		}
+
+		// Retried on the next pass while every bin is in use
+		bin := rs.slotting.FindRestockBin(receipt.ProductID)
+		if bin == nil {
+			continue
+		}
+
+		// The robot must reach goods-in and still lift the bin once it is filled
+		port := rs.goodsIn[receipt.ID%len(rs.goodsIn)]
+		addedKg := rs.receivedWeight(*bin, receipt)
+		robot := findIdleRobot(robots, assigned, *bin, func(robot *models.Robot) bool {
+			return robot.RobotType().Fits(port.X, rs.slotting.warehouse.Width) && rs.slotting.CanHandle(robot, *bin, addedKg)
+		})
+		if robot == nil {
+			continue
+		}
+
+		pickCommand, storeCommand, err := rs.slotting.PlanRetrieval(*bin, port, robot.RobotType())
+		if err != nil {
+			continue
+		}
+
+		receipt.Status = models.ReceiptAssigned
+		receipt.AssignedRobot = robot.ID
+		receipt.Bin = *bin
+
+		pickCommand.ReceiptID = receipt.ID
+		storeCommand.ReceiptID = receipt.ID
+		storeCommand.PayloadKg += addedKg
+		dropCommand := models.RobotCommand{
+			Type:      "drop",
+			X:         port.X,
+			Y:         port.Y,
+			Z:         port.Z,
+			ReceiptID: receipt.ID,
+			Duration:  GoodsInHandlingTime,
+			PayloadKg: pickCommand.PayloadKg,
+		}
+
+		fmt.Printf("Assigned Receipt %d to Robot %d - bin from (%d,%d,%d) to goods-in (%d,%d)\n",
+			receipt.ID, robot.ID, bin.X, bin.Y, bin.Z, port.X, port.Y)
+		dispatches = append(dispatches, robotDispatch{
+			robot:    robot,
+			commands: []models.RobotCommand{pickCommand, dropCommand, storeCommand},
+		})
+		assigned[robot.ID] = true
+	}
+	rs.mu.Unlock()
+
+	// Send commands without holding the lock, robots report back via HandleRobotUpdate
+	for _, dispatch := range dispatches {
+		for _, cmd := range dispatch.commands {
+			dispatch.robot.Commands <- cmd
+		}
+	}
+}
+
+// HandleRobotUpdate advances receipts and fills the bin once the operator is done
+func (rs *ReceivingService) HandleRobotUpdate(update models.RobotUpdate) {
+	if update.ReceiptID == 0 {
+		return
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	receipt := rs.getReceipt(update.ReceiptID)
+	if receipt == nil || receipt.Status == models.ReceiptReceived || update.RobotID != receipt.AssignedRobot {
+		return
+	}
+
+	switch {
+	case update.Command == "drop" && update.Status == "dropping":
+		receipt.Status = models.ReceiptReceiving
+	case update.Command == "drop" && update.Status == "idle":
+		// Operator finished filling the bin at goods-in
+		added := rs.slotting.AddToCarried(update.RobotID, receipt.ProductID, receipt.Remaining())
+		receipt.ReceivedQty += added
+		if receipt.Remaining() > 0 {
+			// The rest waits at goods-in for another bin
+			receipt.Status = models.ReceiptPending
+			receipt.AssignedRobot = 0
+			fmt.Printf("Receipt %d: %d units of product %d fit into the bin, %d wait for another\n",
+				receipt.ID, added, receipt.ProductID, receipt.Remaining())
+			return
+		}
+		receivedAt := rs.clock.Now()
+		receipt.Status = models.ReceiptReceived
+		receipt.ReceivedAt = &receivedAt
+		fmt.Printf("Receipt %d received: %d units of product %d\n", receipt.ID, receipt.ReceivedQty, receipt.ProductID)
+	}
+}
+
+// receivedWeight estimates the weight the receipt adds to the bin, capped by what fits
+func (rs *ReceivingService) receivedWeight(bin models.Position, receipt *models.Receipt) float64 {
+	product := rs.productService.GetProductByID(receipt.ProductID)
+	if product == nil {
+		return 0
+	}
+
+	warehouse := rs.slotting.warehouse
+	warehouse.Mutex.RLock()
+	room := warehouse.Grid[bin.X][bin.Y][bin.Z].Room(product, rs.productService.GetProductByID)
+	warehouse.Mutex.RUnlock()
+
+	if room > receipt.Remaining() {
+		room = receipt.Remaining()
+	}
+	return float64(room) * product.Weight
+}
+
+// GetAllReceipts returns a copy of every receipt
+func (rs *ReceivingService) GetAllReceipts() []models.Receipt {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return append([]models.Receipt{}, rs.receipts...)
+}
+
+// getReceipt finds a receipt by ID, caller must hold the lock
+func (rs *ReceivingService) getReceipt(id int) *models.Receipt {
+	for i := range rs.receipts {
+		if rs.receipts[i].ID == id {
+			return &rs.receipts[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"autostore-sim/backend/models"
+	"testing"
+)
+
+// testCatalog returns a product service holding the given products
+func testCatalog(products ...models.Product) *ProductService {
+	ps := NewProductService()
+	for i := range products {
+		ps.catalog.Products[products[i].ID] = &products[i]
+	}
+	return ps
+}
+
+func TestReceiptStaysOpenUntilPutAway(t *testing.T) {
+	tests := []struct {
+		name    string
+		product models.Product
+		fits    int // Units the first, empty single-compartment bin takes
+	}{
+		// 65 litres hold six 10 litre units
+		{"bin runs out of volume", models.Product{ID: 1, Volume: 10, Weight: 0.1}, 6},
+		// 30 kg lift seven 4 kg units
+		{"bin runs out of weight", models.Product{ID: 1, Volume: 0.1, Weight: 4}, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products := testCatalog(tt.product)
+			slotting, err := NewSlottingService(products, models.NewSafeWarehouse(2, 2, 1), models.RealClock(), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs := NewReceivingService(products, slotting, nil, models.RealClock())
+			receipt, err := rs.CreateReceipt(1, 10, models.ReceiptSourceAPI)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// putAway has a robot bring an empty bin to goods-in and the operator fill it
+			putAway := func(robotID int) models.Receipt {
+				t.Helper()
+				slotting.carried[robotID] = models.NewBin("BIN", 1)
+				rs.mu.Lock()
+				rs.getReceipt(receipt.ID).Status = models.ReceiptAssigned
+				rs.getReceipt(receipt.ID).AssignedRobot = robotID
+				rs.mu.Unlock()
+				rs.HandleRobotUpdate(models.RobotUpdate{RobotID: robotID, ReceiptID: receipt.ID, Command: "drop", Status: "dropping"})
+				rs.HandleRobotUpdate(models.RobotUpdate{RobotID: robotID, ReceiptID: receipt.ID, Command: "drop", Status: "idle"})
+				return rs.GetAllReceipts()[0]
+			}
+
+			first := putAway(7)
+			if first.ReceivedQty != tt.fits || first.Status != models.ReceiptPending || first.AssignedRobot != 0 || first.ReceivedAt != nil {
+				t.Fatalf("after the first bin: %+v", first)
+			}
+			if got := slotting.carried[7].QuantityOf(1); got != tt.fits {
+				t.Errorf("first bin holds %d units, want %d", got, tt.fits)
+			}
+			if inbound := rs.InboundByProduct()[1]; inbound != 10-tt.fits {
+				t.Errorf("inbound %d after the first bin, want %d", inbound, 10-tt.fits)
+			}
+			if !rs.HasInboundStock(1) {
+				t.Error("the shortfall is not inbound stock")
+			}
+
+			// A late update from the first robot changes nothing
+			rs.HandleRobotUpdate(models.RobotUpdate{RobotID: 7, ReceiptID: receipt.ID, Command: "drop", Status: "idle"})
+			if again := rs.GetAllReceipts()[0]; again.ReceivedQty != tt.fits {
+				t.Errorf("stale update received %d units", again.ReceivedQty-tt.fits)
+			}
+
+			second := putAway(8)
+			if second.ReceivedQty != 10 || second.Status != models.ReceiptReceived || second.ReceivedAt == nil {
+				t.Fatalf("after the second bin: %+v", second)
+			}
+			if got := slotting.carried[8].QuantityOf(1); got != 10-tt.fits {
+				t.Errorf("second bin holds %d units, want %d", got, 10-tt.fits)
+			}
+			if inbound := rs.InboundByProduct()[1]; inbound != 0 {
+				t.Errorf("inbound %d once received", inbound)
+			}
+		})
+	}
+}
@@ -0,0 +1,532 @@
+package services
+
+import (
+	"autostore-sim/backend/models"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Return policies decide where a bin goes back into the grid after visiting a port
+const (
+	ReturnSameStack  = "same_stack"  // Back on top of the stack it came from
+	ReturnNearestTop = "nearest_top" // On top of the stack closest to the delivery port
+	ReturnPopularity = "popularity"  // Hot products get stacks near the ports, slow movers go far
+)
+
+// Housekeeping only relocates bins that save at least this much velocity-weighted handling time
+const minHousekeepingGain = 1.0
+
+// maxLayoutSamples bounds the layout history kept for metrics
+const maxLayoutSamples = 2000
+
+// LayoutSample is the layout cost at a point in simulated time
+type LayoutSample struct {
+	Time time.Time  `json:"time"`
+	Cost LayoutCost `json:"cost"`
+}
+
+// SlottingService moves bins between the grid and robots and decides where they are stored
+type SlottingService struct {
+	mu             sync.Mutex
+	productService *ProductService
+	warehouse      *models.SafeWarehouse
+	clock          models.Clock
+	returnPolicy   string
+
+	// OnBinMoved is called with the grid cell a bin left or arrived in, outside the lock
+	OnBinMoved func(models.BinSlot) `json:"-"`
+
+	reserved map[models.Position]bool   // Cells with a bin allocated for pick or a slot held for return
+	carried  map[int]models.StorageCell // Bins lifted out of the grid, by robot ID
+	history  []LayoutSample
+}
+
+// NewSlottingService creates a slotting service with the given return policy
+func NewSlottingService(productService *ProductService, warehouse *models.SafeWarehouse,
+	clock models.Clock, returnPolicy string) (*SlottingService, error) {
+	switch returnPolicy {
+	case "":
+		returnPolicy = ReturnSameStack
+	case ReturnSameStack, ReturnNearestTop, ReturnPopularity:
+	default:
+		return nil, fmt.Errorf("unknown return policy %q (supported: same_stack, nearest_top, popularity)", returnPolicy)
+	}
+
+	return &SlottingService{
+		productService: productService,
+		warehouse:      warehouse,
+		clock:          clock,
+		returnPolicy:   returnPolicy,
+		reserved:       make(map[models.Position]bool),
+		carried:        make(map[int]models.StorageCell),
+	}, nil
+}
+
+// ReturnPolicy returns the active return policy name
+func (ss *SlottingService) ReturnPolicy() string {
+	return ss.returnPolicy
+}
+
+// IsReserved reports whether a cell's bin or slot is already spoken for
+func (ss *SlottingService) IsReserved(pos models.Position) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.reserved[pos]
+}
+
+// PlanRetrieval reserves the bin at from and a slot to return it to after visiting port.
+// The pick command carries how many bins must be dug out above the target.
+func (ss *SlottingService) PlanRetrieval(from, port models.Position, robotType *models.RobotType) (pick, store models.RobotCommand, err error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.reserved[from] {
+		return pick, store, fmt.Errorf("bin at (%d, %d, %d) is already reserved", from.X, from.Y, from.Z)
+	}
+
+	ss.warehouse.Mutex.RLock()
+	bin := ss.warehouse.Grid[from.X][from.Y][from.Z]
+	digs := ss.binsAbove(from)
+	target, ok := ss.chooseReturnSlot(bin, from, port, ss.returnPolicy, robotType)
+	ss.warehouse.Mutex.RUnlock()
+	weight := bin.Weight(ss.productService.GetProductByID)
+
+	// The stack may have settled since the caller looked at it
+	if bin.BinID == "" {
+		return pick, store, fmt.Errorf("no bin at (%d, %d, %d)", from.X, from.Y, from.Z)
+	}
+	if !ok {
+		return pick, store, fmt.Errorf("no free slot to return %s to", bin.BinID)
+	}
+
+	ss.reserved[from] = true
+	ss.reserved[target] = true
+
+	// The store payload is what leaves the grid, callers adjust it for units taken or added at the port
+	pick = models.RobotCommand{Type: "pick", X: from.X, Y: from.Y, Z: from.Z, Digs: digs, PayloadKg: weight}
+	store = models.RobotCommand{Type: "store", X: target.X, Y: target.Y, Z: target.Z, PayloadKg: weight}
+	return pick, store, nil
+}
+
+// TakeFromCarried removes a product's units from the bin a robot is carrying, e.g. at a picking port
+func (ss *SlottingService) TakeFromCarried(robotID, productID, quantity int) int {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	bin, ok := ss.carried[robotID]
+	if !ok {
+		return 0
+	}
+	taken := bin.Take(productID, quantity)
+	ss.carried[robotID] = bin
+	return taken
+}
+
+// AddToCarried puts received stock into the bin a robot is carrying, e.g. at goods-in.
+// It returns how many units fit into the product's compartment or an empty one.
+func (ss *SlottingService) AddToCarried(robotID, productID, quantity int) int {
+	product := ss.productService.GetProductByID(productID)
+	if product == nil {
+		return 0
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	bin, ok := ss.carried[robotID]
+	if !ok {
+		return 0
+	}
+	added := bin.Add(product, quantity, ss.productService.GetProductByID)
+	ss.carried[robotID] = bin
+	return added
+}
+
+// FindRestockBin returns an unreserved bin with room for a product: the product's own bin
+// with the least stock, otherwise the emptiest bin with a free compartment. Nil when all are full.
+func (ss *SlottingService) FindRestockBin(productID int) *models.Position {
+	product := ss.productService.GetProductByID(productID)
+	if product == nil {
+		return nil
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.warehouse.Mutex.RLock()
+	defer ss.warehouse.Mutex.RUnlock()
+
+	var own, other *models.Position
+	ownQty, otherWeight := 0, 0.0
+	for x := 0; x < ss.warehouse.Width; x++ {
+		for y := 1; y < ss.warehouse.Height; y++ {
+			for z := 0; z < ss.warehouse.Levels; z++ {
+				pos := models.Position{X: x, Y: y, Z: z}
+				cell := ss.warehouse.Grid[x][y][z]
+				if cell.BinID == "" || ss.reserved[pos] || cell.Room(product, ss.productService.GetProductByID) == 0 {
+					continue
+				}
+
+				if qty := cell.QuantityOf(productID); qty > 0 {
+					if own == nil || qty < ownQty {
+						own, ownQty = &pos, qty
+					}
+				} else if weight := cell.Weight(ss.productService.GetProductByID); other == nil || weight < otherWeight {
+					other, otherWeight = &pos, weight
+				}
+			}
+		}
+	}
+
+	if own != nil {
+		return own
+	}
+	return other
+}
+
+// StockByProduct totals the units of each product in the grid and on robots
+func (ss *SlottingService) StockByProduct() map[int]int {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	stock := make(map[int]int)
+	addBin := func(bin models.StorageCell) {
+		for _, c := range bin.Compartments {
+			if c.Quantity > 0 {
+				stock[c.ProductID] += c.Quantity
+			}
+		}
+	}
+
+	ss.warehouse.Mutex.RLock()
+	for x := 0; x < ss.warehouse.Width; x++ {
+		for y := 0; y < ss.warehouse.Height; y++ {
+			for z := 0; z < ss.warehouse.Levels; z++ {
+				addBin(ss.warehouse.Grid[x][y][z])
+			}
+		}
+	}
+	ss.warehouse.Mutex.RUnlock()
+
+	for _, bin := range ss.carried {
+		addBin(bin)
+	}
+	return stock
+}
+
+// HandleRobotUpdate lifts bins out of the grid on pick and puts them back on store.
+// Either way the stack settles, so no bin is left standing over a hole.
+func (ss *SlottingService) HandleRobotUpdate(update models.RobotUpdate) {
+	pos := models.Position{X: update.X, Y: update.Y, Z: update.Z}
+
+	var moved []models.BinSlot
+	switch {
+	case update.Command == "pick" && update.Status == "carrying":
+		ss.mu.Lock()
+		delete(ss.reserved, pos)
+		ss.warehouse.Mutex.Lock()
+		ss.carried[update.RobotID] = ss.warehouse.Grid[pos.X][pos.Y][pos.Z]
+		ss.warehouse.Grid[pos.X][pos.Y][pos.Z] = models.StorageCell{}
+		moved = append(moved, models.BinSlot{X: pos.X, Y: pos.Y, Z: pos.Z})
+		moved = append(moved, ss.compactStack(pos.X, pos.Y)...)
+		ss.warehouse.Mutex.Unlock()
+		ss.mu.Unlock()
+
+	case update.Command == "store" && update.Status == "idle":
+		ss.mu.Lock()
+		delete(ss.reserved, pos)
+		if bin, ok := ss.carried[update.RobotID]; ok {
+			ss.warehouse.Mutex.Lock()
+			ss.warehouse.Grid[pos.X][pos.Y][pos.Z] = bin
+			ss.setProductPositions(bin, pos)
+			moved = append(moved, models.BinSlot{X: pos.X, Y: pos.Y, Z: pos.Z, BinID: bin.BinID})
+			moved = append(moved, ss.compactStack(pos.X, pos.Y)...)
+			ss.warehouse.Mutex.Unlock()
+			delete(ss.carried, update.RobotID)
+		}
+		ss.mu.Unlock()
+	}
+
+	for _, slot := range moved {
+		ss.notifyBinMoved(slot)
+	}
+}
+
+// compactStack lets the bins of stack (x, y) drop into the holes left by bins dug out from
+// under them. Reserved cells stay put, bins above one settle on it, and the pick or store
+// planned there keeps its coordinates. It returns the cells that changed, in order.
+// Caller must hold ss.mu and the warehouse write lock.
+func (ss *SlottingService) compactStack(x, y int) []models.BinSlot {
+	var moved []models.BinSlot
+	floor := ss.warehouse.Levels - 1 // Lowest level a falling bin can reach, z=0 being the top
+	for z := ss.warehouse.Levels - 1; z >= 0; z-- {
+		pos := models.Position{X: x, Y: y, Z: z}
+		bin := ss.warehouse.Grid[x][y][z]
+		switch {
+		case ss.reserved[pos]:
+			floor = z - 1
+		case bin.BinID != "":
+			if z != floor {
+				ss.warehouse.Grid[x][y][floor] = bin
+				ss.warehouse.Grid[x][y][z] = models.StorageCell{}
+				ss.setProductPositions(bin, models.Position{X: x, Y: y, Z: floor})
+				moved = append(moved,
+					models.BinSlot{X: x, Y: y, Z: floor, BinID: bin.BinID},
+					models.BinSlot{X: x, Y: y, Z: z})
+			}
+			floor--
+		}
+	}
+	return moved
+}
+
+// setProductPositions points a bin's products at pos. Product positions are written under
+// the warehouse lock, as when placing the layout.
+func (ss *SlottingService) setProductPositions(bin models.StorageCell, pos models.Position) {
+	for _, productID := range bin.ProductIDs() {
+		if product := ss.productService.GetProductByID(productID); product != nil {
+			product.Position = pos
+		}
+	}
+}
+
+// notifyBinMoved reports the new content of a grid cell to the listener
+func (ss *SlottingService) notifyBinMoved(slot models.BinSlot) {
+	if ss.OnBinMoved != nil {
+		ss.OnBinMoved(slot)
+	}
+}
+
+// Housekeep plans moving one hot bin to the top of a stack near the ports.
+// It returns pick and store commands for the idle robot, or nil when nothing is worth moving.
+func (ss *SlottingService) Housekeep(idleRobot *models.Robot) []models.RobotCommand {
+	if idleRobot == nil {
+		return nil
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	ports := ss.productService.getPortPositions(ss.warehouse)
+	maxVelocity := ss.maxVelocity()
+
+	ss.warehouse.Mutex.RLock()
+	var bestFrom, bestTo models.Position
+	bestGain, bestDigs := 0.0, 0
+	for x := 0; x < ss.warehouse.Width; x++ {
+		for y := 1; y < ss.warehouse.Height; y++ {
+			for z := 0; z < ss.warehouse.Levels; z++ {
+				from := models.Position{X: x, Y: y, Z: z}
+				bin := ss.warehouse.Grid[x][y][z]
+				if bin.IsEmpty() || ss.reserved[from] {
+					continue
+				}
+
+				// Only hot bins are worth a robot trip
+				velocity := ss.binVelocity(bin)
+				if velocity < maxVelocity/2 {
+					continue
+				}
+
+				if !ss.canHandle(idleRobot.RobotType(), from, bin) {
+					continue
+				}
+
+				to, ok := ss.cheapestLandingSlot(from, ports, idleRobot.RobotType())
+				if !ok || to.X == from.X && to.Y == from.Y {
+					continue
+				}
+				gain := (ss.slotSeconds(from, ports) - ss.slotSeconds(to, ports)) * velocity
+				if gain > bestGain {
+					bestGain, bestFrom, bestTo, bestDigs = gain, from, to, ss.binsAbove(from)
+				}
+			}
+		}
+	}
+	ss.warehouse.Mutex.RUnlock()
+
+	if bestGain < minHousekeepingGain {
+		return nil
+	}
+
+	ss.warehouse.Mutex.RLock()
+	weight := ss.warehouse.Grid[bestFrom.X][bestFrom.Y][bestFrom.Z].Weight(ss.productService.GetProductByID)
+	ss.warehouse.Mutex.RUnlock()
+
+	ss.reserved[bestFrom] = true
+	ss.reserved[bestTo] = true
+	fmt.Printf("Housekeeping: Robot %d moves bin (%d, %d, %d) -> (%d, %d, %d)\n", idleRobot.ID,
+		bestFrom.X, bestFrom.Y, bestFrom.Z, bestTo.X, bestTo.Y, bestTo.Z)
+
+	return []models.RobotCommand{
+		{Type: "pick", X: bestFrom.X, Y: bestFrom.Y, Z: bestFrom.Z, Digs: bestDigs, PayloadKg: weight},
+		{Type: "store", X: bestTo.X, Y: bestTo.Y, Z: bestTo.Z, PayloadKg: weight},
+	}
+}
+
+// RecordLayoutSample stores the current layout cost for convergence tracking
+func (ss *SlottingService) RecordLayoutSample() LayoutSample {
+	sample := LayoutSample{
+		Time: ss.clock.Now(),
+		Cost: ss.productService.EvaluateLayout(ss.warehouse),
+	}
+	sample.Cost.Strategy = ss.returnPolicy
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.history = append(ss.history, sample)
+	if len(ss.history) > maxLayoutSamples {
+		ss.history = ss.history[len(ss.history)-maxLayoutSamples:]
+	}
+	return sample
+}
+
+// GetLayoutHistory returns the recorded layout samples, oldest first
+func (ss *SlottingService) GetLayoutHistory() []LayoutSample {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return append([]LayoutSample(nil), ss.history...)
+}
+
+// chooseReturnSlot picks where a bin lifted from `from` goes back according to policy.
+// Bins always land on top of a stack. Caller must hold ss.mu and the warehouse read lock.
+func (ss *SlottingService) chooseReturnSlot(bin models.StorageCell, from, port models.Position, policy string,
+	robotType *models.RobotType) (models.Position, bool) {
+	switch policy {
+	case ReturnSameStack:
+		if pos, ok := ss.landingSlot(from.X, from.Y, from); ok && robotType.Fits(pos.X, ss.warehouse.Width) {
+			return pos, true
+		}
+		// The stack filled up meanwhile, fall back to the nearest free top
+		return ss.cheapestLandingSlot(from, []models.Position{port}, robotType)
+
+	case ReturnNearestTop:
+		return ss.cheapestLandingSlot(from, []models.Position{port}, robotType)
+
+	case ReturnPopularity:
+		// Rank stack tops by access cost and give the bin the one matching its popularity
+		ports := ss.productService.getPortPositions(ss.warehouse)
+		slots := ss.landingSlots(from, robotType)
+		if len(slots) == 0 {
+			return from, false
+		}
+		sort.SliceStable(slots, func(i, j int) bool {
+			return ss.slotSeconds(slots[i], ports) < ss.slotSeconds(slots[j], ports)
+		})
+
+		hotness := 1.0
+		if maxVelocity := ss.maxVelocity(); maxVelocity > 0 {
+			hotness = math.Min(ss.binVelocity(bin)/maxVelocity, 1)
+		}
+		index := int((1 - hotness) * float64(len(slots)-1))
+		return slots[index], true
+	}
+
+	return from, false
+}
+
+// cheapestLandingSlot returns the stack top with the lowest access cost to the given ports
+func (ss *SlottingService) cheapestLandingSlot(from models.Position, ports []models.Position,
+	robotType *models.RobotType) (models.Position, bool) {
+	var best models.Position
+	bestCost := -1.0
+	for _, pos := range ss.landingSlots(from, robotType) {
+		if cost := ss.slotSeconds(pos, ports); bestCost < 0 || cost < bestCost {
+			best, bestCost = pos, cost
+		}
+	}
+	return best, bestCost >= 0
+}
+
+// landingSlots returns the free top slot of every storage stack that has room and the robot can work
+func (ss *SlottingService) landingSlots(from models.Position, robotType *models.RobotType) []models.Position {
+	var slots []models.Position
+	for x := 0; x < ss.warehouse.Width; x++ {
+		if !robotType.Fits(x, ss.warehouse.Width) {
+			continue
+		}
+		// Skip the port row (y=0)
+		for y := 1; y < ss.warehouse.Height; y++ {
+			if pos, ok := ss.landingSlot(x, y, from); ok {
+				slots = append(slots, pos)
+			}
+		}
+	}
+	return slots
+}
+
+// landingSlot returns the cell just above the topmost bin of stack (x, y), z=0 being the top.
+// The cell at from counts as empty since its bin is being moved, reserved cells count as taken.
+func (ss *SlottingService) landingSlot(x, y int, from models.Position) (models.Position, bool) {
+	top := ss.warehouse.Levels // First occupied level, Levels when the stack is empty
+	for z := 0; z < ss.warehouse.Levels; z++ {
+		pos := models.Position{X: x, Y: y, Z: z}
+		if pos == from {
+			continue
+		}
+		if ss.reserved[pos] || ss.warehouse.Grid[x][y][z].BinID != "" {
+			top = z
+			break
+		}
+	}
+	if top == 0 {
+		return models.Position{}, false
+	}
+	return models.Position{X: x, Y: y, Z: top - 1}, true
+}
+
+// canHandle reports whether a robot type can stand over a bin and lift it, caller must hold the warehouse lock
+func (ss *SlottingService) canHandle(robotType *models.RobotType, pos models.Position, bin models.StorageCell) bool {
+	return robotType.Fits(pos.X, ss.warehouse.Width) && robotType.CanLift(bin.Weight(ss.productService.GetProductByID))
+}
+
+// CanHandle reports whether a robot can work the stack at pos and lift its bin with extraKg added at a port
+func (ss *SlottingService) CanHandle(robot *models.Robot, pos models.Position, extraKg float64) bool {
+	robotType := robot.RobotType()
+	ss.warehouse.Mutex.RLock()
+	weight := ss.warehouse.Grid[pos.X][pos.Y][pos.Z].Weight(ss.productService.GetProductByID)
+	ss.warehouse.Mutex.RUnlock()
+	return robotType.Fits(pos.X, ss.warehouse.Width) && robotType.CanLift(weight+extraKg)
+}
+
+// slotSeconds estimates handling time for a bin at pos from the bins actually stacked above it
+func (ss *SlottingService) slotSeconds(pos models.Position, ports []models.Position) float64 {
+	return float64(ss.binsAbove(pos))*digSecondsPerBin +
+		float64(pos.Z)*levelTravelSeconds +
+		float64(portDistance(pos, ports))*cellTravelSeconds
+}
+
+// binsAbove counts bins stacked above pos (z=0 is the top), caller must hold the warehouse lock
+func (ss *SlottingService) binsAbove(pos models.Position) int {
+	count := 0
+	for z := 0; z < pos.Z; z++ {
+		if ss.warehouse.Grid[pos.X][pos.Y][z].BinID != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// binVelocity sums the velocity of the products in a bin, a bin serving several SKUs is visited more
+func (ss *SlottingService) binVelocity(bin models.StorageCell) float64 {
+	velocity := 0.0
+	for _, productID := range bin.ProductIDs() {
+		if product := ss.productService.GetProductByID(productID); product != nil {
+			velocity += productVelocity(product)
+		}
+	}
+	return velocity
+}
+
+// maxVelocity returns the highest velocity in the catalog
+func (ss *SlottingService) maxVelocity() float64 {
+	max := 0.0
+	for _, product := range ss.productService.GetAllProducts() {
+		if v := productVelocity(product); v > max {
+			max = v
+		}
+	}
+	return max
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"autostore-sim/backend/models"
+	"fmt"
+	"testing"
+)
+
+// testSlotting builds a 3x3 grid, 3 levels deep, with the stack heights below over the
+// storage rows y=1 and y=2. Every bin holds five units of its own product at velocity 1, the
+// catalog's fastest mover, product 99 at velocity 10, is not in the grid.
+//
+//	        x=0  x=1  x=2
+//	y=2      3    0    3
+//	y=1      2    2    3
+//	y=0     ports
+func testSlotting(t *testing.T, policy string) (*SlottingService, *models.SafeWarehouse) {
+	t.Helper()
+	heights := map[[2]int]int{{0, 1}: 2, {1, 1}: 2, {2, 1}: 3, {0, 2}: 3, {1, 2}: 0, {2, 2}: 3}
+
+	warehouse := models.NewSafeWarehouse(3, 3, 3)
+	products := []models.Product{{ID: 99, Velocity: 10}}
+	for stack, height := range heights {
+		for z := 3 - height; z < 3; z++ {
+			id := len(products)
+			products = append(products, models.Product{ID: id, Velocity: 1})
+			warehouse.Grid[stack[0]][stack[1]][z] = models.StorageCell{
+				BinID:        binAt(stack[0], stack[1], z),
+				Compartments: []models.Compartment{{ProductID: id, Quantity: 5}},
+			}
+		}
+	}
+
+	ss, err := NewSlottingService(testCatalog(products...), warehouse, models.RealClock(), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ss, warehouse
+}
+
+// binAt names the bin a test grid starts with at (x, y, z)
+func binAt(x, y, z int) string {
+	return fmt.Sprintf("BIN-%d-%d-%d", x, y, z)
+}
+
+// stack lists the bin IDs of stack (x, y) from the top, "" for a free cell
+func stack(warehouse *models.SafeWarehouse, x, y int) []string {
+	ids := make([]string, warehouse.Levels)
+	for z := range ids {
+		ids[z] = warehouse.Grid[x][y][z].BinID
+	}
+	return ids
+}
+
+func TestReturnPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		from   models.Position
+		port   models.Position
+		hot    bool // The bin's product is the catalog's fastest mover
+		digs   int
+		want   models.Position
+	}{
+		{name: "same stack", policy: ReturnSameStack, from: models.Position{X: 0, Y: 2, Z: 0}, port: models.Position{X: 2},
+			want: models.Position{X: 0, Y: 2, Z: 0}},
+		// With the bins above still standing the stack has no top free yet, the nearest one is used
+		{name: "same stack, dug out", policy: ReturnSameStack, from: models.Position{X: 2, Y: 1, Z: 1}, port: models.Position{X: 2},
+			digs: 1, want: models.Position{X: 1, Y: 1, Z: 0}},
+		{name: "nearest top", policy: ReturnNearestTop, from: models.Position{X: 0, Y: 2, Z: 0}, port: models.Position{X: 2},
+			want: models.Position{X: 1, Y: 1, Z: 0}},
+		// Tops by cost to any port: (0,1,0) and (1,1,0), then (0,2,0), then (1,2,2)
+		{name: "popularity, hot", policy: ReturnPopularity, from: models.Position{X: 0, Y: 2, Z: 0}, port: models.Position{X: 2},
+			hot: true, want: models.Position{X: 0, Y: 1, Z: 0}},
+		{name: "popularity, slow", policy: ReturnPopularity, from: models.Position{X: 0, Y: 2, Z: 0}, port: models.Position{X: 2},
+			want: models.Position{X: 0, Y: 2, Z: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss, warehouse := testSlotting(t, tt.policy)
+			if tt.hot {
+				productID := warehouse.Grid[tt.from.X][tt.from.Y][tt.from.Z].Compartments[0].ProductID
+				ss.productService.GetProductByID(productID).Velocity = 10
+			}
+
+			pick, store, err := ss.PlanRetrieval(tt.from, tt.port, &models.StandardRobot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pick.X != tt.from.X || pick.Y != tt.from.Y || pick.Z != tt.from.Z || pick.Digs != tt.digs {
+				t.Errorf("pick %+v, want %v with %d digs", pick, tt.from, tt.digs)
+			}
+			if got := (models.Position{X: store.X, Y: store.Y, Z: store.Z}); got != tt.want {
+				t.Errorf("returned to %v, want %v", got, tt.want)
+			}
+			if !ss.IsReserved(tt.from) || !ss.IsReserved(tt.want) {
+				t.Error("bin or return slot not reserved")
+			}
+		})
+	}
+}
+
+// TestDigOutCompactsStack checks that bins above a dug-out bin drop into its cell, so the
+// free slot is on top where a returning bin can land
+func TestDigOutCompactsStack(t *testing.T) {
+	ss, warehouse := testSlotting(t, ReturnSameStack)
+	var moved []models.BinSlot
+	ss.OnBinMoved = func(slot models.BinSlot) { moved = append(moved, slot) }
+
+	from := models.Position{X: 2, Y: 1, Z: 1}
+	topProduct := warehouse.Grid[2][1][0].Compartments[0].ProductID
+	if _, _, err := ss.PlanRetrieval(from, models.Position{X: 2}, &models.StandardRobot); err != nil {
+		t.Fatal(err)
+	}
+	ss.HandleRobotUpdate(models.RobotUpdate{RobotID: 1, Command: "pick", Status: "carrying", X: 2, Y: 1, Z: 1})
+
+	want := []string{"", binAt(2, 1, 0), binAt(2, 1, 2)}
+	if got := stack(warehouse, 2, 1); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("stack %q, want %q", got, want)
+	}
+	if pos := ss.productService.GetProductByID(topProduct).Position; pos != (models.Position{X: 2, Y: 1, Z: 1}) {
+		t.Errorf("settled bin's product at %v", pos)
+	}
+	wantMoved := []models.BinSlot{{X: 2, Y: 1, Z: 1}, {X: 2, Y: 1, Z: 1, BinID: binAt(2, 1, 0)}, {X: 2, Y: 1, Z: 0}}
+	if fmt.Sprint(moved) != fmt.Sprint(wantMoved) {
+		t.Errorf("moved %+v, want %+v", moved, wantMoved)
+	}
+
+	// The freed top is a landing slot again, the next bin from this stack goes back on it
+	_, store, err := ss.PlanRetrieval(models.Position{X: 2, Y: 1, Z: 2}, models.Position{X: 2}, &models.StandardRobot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.X != 2 || store.Y != 1 || store.Z != 0 {
+		t.Errorf("returned to (%d, %d, %d), want the top of its own stack", store.X, store.Y, store.Z)
+	}
+}
+
+// TestCompactionKeepsReservations checks that reserved bins and slots stay where their robots
+// expect them, and the stack settles once they are gone
+func TestCompactionKeepsReservations(t *testing.T) {
+	ss, warehouse := testSlotting(t, ReturnNearestTop)
+	port := models.Position{X: 2}
+
+	// Robot 1 fetches (0,2,0) and will return it on top of (1,1), robot 2 fetches the top bin of
+	// (2,1) and robot 3 the bin under it
+	_, store1, err := ss.PlanRetrieval(models.Position{X: 0, Y: 2, Z: 0}, port, &models.StandardRobot)
+	if err != nil || store1.X != 1 || store1.Y != 1 || store1.Z != 0 {
+		t.Fatalf("robot 1 returns to %+v, %v", store1, err)
+	}
+	if _, _, err := ss.PlanRetrieval(models.Position{X: 2, Y: 1, Z: 0}, port, &models.StandardRobot); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ss.PlanRetrieval(models.Position{X: 2, Y: 1, Z: 1}, port, &models.StandardRobot); err != nil {
+		t.Fatal(err)
+	}
+
+	// Robot 3 digs its bin out from under robot 2's, which stays where robot 2 will look for it
+	ss.HandleRobotUpdate(models.RobotUpdate{RobotID: 3, Command: "pick", Status: "carrying", X: 2, Y: 1, Z: 1})
+	if got, want := stack(warehouse, 2, 1), []string{binAt(2, 1, 0), "", binAt(2, 1, 2)}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("stack %q, want %q", got, want)
+	}
+	// Robot 2 takes it, nothing is left standing over the hole
+	ss.HandleRobotUpdate(models.RobotUpdate{RobotID: 2, Command: "pick", Status: "carrying", X: 2, Y: 1, Z: 0})
+	if got, want := stack(warehouse, 2, 1), []string{"", "", binAt(2, 1, 2)}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("stack %q, want %q", got, want)
+	}
+
+	// Meanwhile the bin under robot 1's slot is fetched, the slot is left hanging over the hole
+	if _, _, err := ss.PlanRetrieval(models.Position{X: 1, Y: 1, Z: 1}, port, &models.StandardRobot); err != nil {
+		t.Fatal(err)
+	}
+	ss.HandleRobotUpdate(models.RobotUpdate{RobotID: 4, Command: "pick", Status: "carrying", X: 1, Y: 1, Z: 1})
+	if !ss.IsReserved(models.Position{X: 1, Y: 1, Z: 0}) {
+		t.Fatal("robot 1's return slot was released")
+	}
+
+	// Robot 1's bin drops onto the stack when stored
+	ss.HandleRobotUpdate(models.RobotUpdate{RobotID: 1, Command: "pick", Status: "carrying", X: 0, Y: 2, Z: 0})
+	ss.HandleRobotUpdate(models.RobotUpdate{RobotID: 1, Command: "store", Status: "idle", X: 1, Y: 1, Z: 0})
+	if got, want := stack(warehouse, 1, 1), []string{"", binAt(0, 2, 0), binAt(1, 1, 2)}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("stack %q, want %q", got, want)
+	}
+}
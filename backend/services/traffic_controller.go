@@ -0,0 +1,632 @@
+package services
+
+import (
+	"autostore-sim/backend/models"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Traffic tuning
+const (
+	trafficMargin  = 500 * time.Millisecond // Gap kept between two robots using the same cell
+	trafficMaxWait = 60 * time.Second       // Longest wait in place before a route counts as blocked
+	trafficRetry   = 1 * time.Second        // Blocked robots ask for a route again after this
+	sideStepRange  = 3                      // Furthest a yielding robot moves, in cells
+)
+
+// TrafficMetrics counts congestion on the grid
+type TrafficMetrics struct {
+	Routes         int            `json:"routes"`          // Routes handed out
+	Delayed        int            `json:"delayed"`         // Routes starting with a wait for a crossing robot
+	Detours        int            `json:"detours"`         // Routes longer than the direct one to avoid a robot
+	Blocked        int            `json:"blocked"`         // Requests refused because a parked robot is in the way
+	WaitSeconds    float64        `json:"wait_seconds"`    // Planned waits plus blocked retries
+	Deadlocks      int            `json:"deadlocks"`       // Waiting cycles between robots detected
+	Yields         int            `json:"yields"`          // Robots side-stepped to break a cycle
+	CongestedCells map[string]int `json:"congested_cells"` // "x,y" -> delays and blocks caused there
+}
+
+// reservation is one robot's claim on a cell for a time window
+type reservation struct {
+	robotID int
+	start   time.Time
+	end     time.Time // Zero while the robot is parked there with no route planned
+}
+
+// overlaps checks two windows against each other keeping trafficMargin apart, zero ends are open
+func (r reservation) overlaps(start, end time.Time) bool {
+	if !r.end.IsZero() && !r.end.Add(trafficMargin).After(start) {
+		return false
+	}
+	if !end.IsZero() && !end.Add(trafficMargin).After(r.start) {
+		return false
+	}
+	return true
+}
+
+// cellWindow is a cell a planned route occupies and when
+type cellWindow struct {
+	cell  models.Position
+	start time.Time
+	end   time.Time // Zero for the cell the route ends in
+}
+
+// TrafficController reserves space-time windows on the grid top so robots never share a cell.
+// Routes are tried along every row and column detour, waiting in place for crossing robots,
+// and a robot blocked by a parked one waits for it. Waiting cycles are broken by the robot with
+// the lowest priority side-stepping.
+type TrafficController struct {
+	mu        sync.Mutex // Guards all reservation state, robots plan from their own goroutines
+	width     int
+	height    int
+	clock     models.Clock
+	cells     map[models.Position][]reservation // Keyed by top cell, Z is always 0
+	waitsFor  map[int]int                       // Blocked robot -> robot holding the cell it needs
+	waiting   map[int]models.RouteRequest       // Request each blocked robot is waiting on
+	yield     map[int]bool                      // Robots picked to side-step out of a cycle
+	footprint map[int]int                       // Cells each robot covers, east of its gripper
+	metrics   TrafficMetrics
+}
+
+// NewTrafficController creates a controller for a width x height grid
+func NewTrafficController(width, height int, clock models.Clock) *TrafficController {
+	return &TrafficController{
+		width:     width,
+		height:    height,
+		clock:     clock,
+		cells:     make(map[models.Position][]reservation),
+		waitsFor:  make(map[int]int),
+		waiting:   make(map[int]models.RouteRequest),
+		yield:     make(map[int]bool),
+		footprint: make(map[int]int),
+		metrics:   TrafficMetrics{CongestedCells: make(map[string]int)},
+	}
+}
+
+// Register parks a robot at its start cell
+func (tc *TrafficController) Register(robotID int, pos models.Position, footprint int) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if footprint < 1 {
+		footprint = 1
+	}
+	tc.footprint[robotID] = footprint
+	tc.release(robotID)
+	tc.park(robotID, pos, tc.clock.Now())
+}
+
+// Unregister drops every reservation a robot holds
+func (tc *TrafficController) Unregister(robotID int) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.release(robotID)
+	delete(tc.waitsFor, robotID)
+	delete(tc.waiting, robotID)
+	delete(tc.yield, robotID)
+	delete(tc.footprint, robotID)
+}
+
+// RequestRoute reserves the fastest free route for a robot, or tells it to wait and retry
+func (tc *TrafficController) RequestRoute(req models.RouteRequest) models.RoutePlan {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	now := tc.clock.Now()
+	tc.prune(now)
+	// The robot is at req.From, its previous route is over
+	tc.release(req.RobotID)
+
+	if tc.yield[req.RobotID] {
+		delete(tc.yield, req.RobotID)
+		if plan, windows, ok := tc.sideStep(req, now); ok {
+			delete(tc.waitsFor, req.RobotID)
+			tc.reserve(req.RobotID, windows)
+			tc.metrics.Yields++
+			return plan
+		}
+	}
+
+	plan, windows, blocker, ok := tc.bestRoute(req, now)
+	if ok {
+		delete(tc.waitsFor, req.RobotID)
+		delete(tc.waiting, req.RobotID)
+		tc.reserve(req.RobotID, windows)
+		return plan
+	}
+
+	// Hold the cell and wait for the robot in the way
+	tc.park(req.RobotID, req.From, now)
+	tc.metrics.Blocked++
+	tc.metrics.WaitSeconds += trafficRetry.Seconds()
+	tc.metrics.CongestedCells[cellKey(req.To)]++
+	if blocker == 0 {
+		// No route fits the robot's footprint at all, nobody to wait for
+		return models.RoutePlan{RetryAfter: trafficRetry}
+	}
+	tc.waitsFor[req.RobotID] = blocker
+	tc.waiting[req.RobotID] = req
+
+	if cycle := tc.findCycle(req.RobotID); cycle != nil {
+		tc.resolveDeadlock(cycle)
+	}
+	return models.RoutePlan{RetryAfter: trafficRetry}
+}
+
+// Blockers returns robots others are waiting for that are not waiting themselves, e.g. parked idle on a port
+func (tc *TrafficController) Blockers() []int {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	seen := make(map[int]bool)
+	var blockers []int
+	for _, blocker := range tc.waitsFor {
+		if _, waiting := tc.waitsFor[blocker]; waiting || seen[blocker] {
+			continue
+		}
+		seen[blocker] = true
+		blockers = append(blockers, blocker)
+	}
+	sort.Ints(blockers)
+	return blockers
+}
+
+// FreeCellNear returns the nearest cell a robot fits in that nobody has reserved,
+// away from the port row when possible
+func (tc *TrafficController) FreeCellNear(robotID int, pos models.Position) (models.Position, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.prune(tc.clock.Now())
+	return tc.freeCellNear(robotID, pos)
+}
+
+// RegisterNear parks a robot joining the fleet on the free cell nearest pos
+func (tc *TrafficController) RegisterNear(robotID int, pos models.Position, footprint int) (models.Position, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if footprint < 1 {
+		footprint = 1
+	}
+	now := tc.clock.Now()
+	tc.prune(now)
+	tc.footprint[robotID] = footprint
+	cell, ok := tc.freeCellNear(robotID, pos)
+	if !ok {
+		delete(tc.footprint, robotID)
+		return models.Position{}, false
+	}
+	tc.release(robotID)
+	tc.park(robotID, cell, now)
+	return cell, true
+}
+
+// freeCellNear finds the nearest free cell for a robot, caller must hold the lock
+func (tc *TrafficController) freeCellNear(robotID int, pos models.Position) (models.Position, bool) {
+	inTheWay := tc.waitersPath(robotID)
+	best, bestDistance, found := models.Position{}, 0, false
+	for x := 0; x < tc.width; x++ {
+		for y := 0; y < tc.height; y++ {
+			cell := models.Position{X: x, Y: y}
+			if !tc.fits(robotID, cell) || !tc.isFree(robotID, cell) {
+				continue
+			}
+			blocking := false
+			for _, covered := range tc.cellsOf(robotID, cell) {
+				blocking = blocking || inTheWay[covered]
+			}
+			if blocking {
+				continue
+			}
+			distance := abs(x-pos.X) + abs(y-pos.Y)
+			if y == 0 {
+				distance += tc.width + tc.height // Ports are on the y=0 row
+			}
+			if !found || distance < bestDistance {
+				best, bestDistance, found = cell, distance, true
+			}
+		}
+	}
+	return best, found
+}
+
+// GetMetrics returns a copy of the congestion counters
+func (tc *TrafficController) GetMetrics() TrafficMetrics {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	metrics := tc.metrics
+	metrics.CongestedCells = make(map[string]int, len(tc.metrics.CongestedCells))
+	for cell, count := range tc.metrics.CongestedCells {
+		metrics.CongestedCells[cell] = count
+	}
+	return metrics
+}
+
+// bestRoute tries every candidate route and keeps the earliest arrival. When none fits
+// it returns the robot blocking the most direct route. Caller must hold the lock.
+func (tc *TrafficController) bestRoute(req models.RouteRequest, now time.Time) (models.RoutePlan, []cellWindow, int, bool) {
+	candidates := tc.candidateRoutes(req.From, req.To, req.Axis)
+
+	var best models.RoutePlan
+	var bestWindows []cellWindow
+	var bestArrival time.Time
+	bestIndex, blocker, found := 0, 0, false
+
+	for i, legs := range candidates {
+		depart, windows, blockedBy, ok := tc.fit(req, legs, now)
+		if !ok {
+			if blocker == 0 {
+				blocker = blockedBy
+			}
+			continue
+		}
+		arrival := windows[len(windows)-1].start
+		if !found || arrival.Before(bestArrival) {
+			best = models.RoutePlan{OK: true, Wait: depart.Sub(now), Legs: legs}
+			bestWindows, bestArrival, bestIndex, found = windows, arrival, i, true
+		}
+	}
+	if !found {
+		return best, nil, blocker, false
+	}
+
+	tc.metrics.Routes++
+	if best.Wait > 0 {
+		tc.metrics.Delayed++
+		tc.metrics.WaitSeconds += best.Wait.Seconds()
+		tc.metrics.CongestedCells[cellKey(req.From)]++
+	}
+	if bestIndex > 0 && routeDistance(best.Legs) > routeDistance(candidates[0]) {
+		tc.metrics.Detours++
+	}
+	return best, bestWindows, 0, true
+}
+
+// fit finds the earliest departure at which legs are free, waiting in place for crossing robots.
+// It fails with the robot in the way when a parked robot blocks the route or the wait is too long.
+func (tc *TrafficController) fit(req models.RouteRequest, legs []models.Leg, now time.Time) (time.Time, []cellWindow, int, bool) {
+	for _, leg := range legs {
+		for _, cell := range leg.Cells() {
+			if !tc.fits(req.RobotID, cell) {
+				return now, nil, 0, false
+			}
+		}
+	}
+
+	depart := now
+	for {
+		windows := tc.routeWindows(req, legs, now, depart)
+		conflict, index, found := tc.firstConflict(req.RobotID, windows)
+		if !found {
+			return depart, windows, 0, true
+		}
+		// Waiting does not help against a parked robot or one crossing the cells we wait in
+		if conflict.end.IsZero() || index < tc.footprintOf(req.RobotID) {
+			return depart, nil, conflict.robotID, false
+		}
+		shift := conflict.end.Add(trafficMargin).Sub(windows[index].start)
+		if shift < trafficMargin {
+			shift = trafficMargin
+		}
+		depart = depart.Add(shift)
+		if depart.Sub(now) > trafficMaxWait {
+			return depart, nil, conflict.robotID, false
+		}
+	}
+}
+
+// routeWindows lays legs out in time from depart: the start cells are held while waiting,
+// each leg holds its cells until it ends and the robot then parks at the last cell.
+// Caller must hold the lock.
+func (tc *TrafficController) routeWindows(req models.RouteRequest, legs []models.Leg, now, depart time.Time) []cellWindow {
+	var windows []cellWindow
+	add := func(pos models.Position, start, end time.Time) {
+		for _, cell := range tc.cellsOf(req.RobotID, pos) {
+			windows = append(windows, cellWindow{cell: cell, start: start, end: end})
+		}
+	}
+
+	add(req.From, now, depart)
+	t := depart
+	axis := req.Axis
+	for _, leg := range legs {
+		d := req.LegTime(leg, axis)
+		axis = leg.Axis
+		for _, cell := range leg.Cells() {
+			add(cell, t, t.Add(d))
+		}
+		t = t.Add(d)
+	}
+
+	add(req.To, t, time.Time{})
+	return windows
+}
+
+// firstConflict returns the first reservation by another robot overlapping the windows, caller must hold the lock
+func (tc *TrafficController) firstConflict(robotID int, windows []cellWindow) (reservation, int, bool) {
+	for i, window := range windows {
+		for _, r := range tc.cells[window.cell] {
+			if r.robotID != robotID && r.overlaps(window.start, window.end) {
+				return r, i, true
+			}
+		}
+	}
+	return reservation{}, 0, false
+}
+
+// candidateRoutes lists routes from one cell to another: the turn-minimising route first, then
+// every detour along another row or column. Caller must hold the lock.
+func (tc *TrafficController) candidateRoutes(from, to models.Position, axis models.Axis) [][]models.Leg {
+	from, to = topCell(from), topCell(to)
+	direct := models.PlanRoute(from, to, axis)
+	candidates := [][]models.Leg{direct}
+	seen := map[string]bool{legsKey(direct): true}
+
+	add := func(waypoints ...models.Position) {
+		legs, ok := legsThrough(waypoints)
+		if !ok {
+			return
+		}
+		if key := legsKey(legs); !seen[key] {
+			seen[key] = true
+			candidates = append(candidates, legs)
+		}
+	}
+
+	for y := 0; y < tc.height; y++ {
+		add(from, models.Position{X: from.X, Y: y}, models.Position{X: to.X, Y: y}, to)
+	}
+	for x := 0; x < tc.width; x++ {
+		add(from, models.Position{X: x, Y: from.Y}, models.Position{X: x, Y: to.Y}, to)
+	}
+	return candidates
+}
+
+// sideStep plans a short move out of the way of the robots waiting for this one, caller must hold the lock
+func (tc *TrafficController) sideStep(req models.RouteRequest, now time.Time) (models.RoutePlan, []cellWindow, bool) {
+	from := topCell(req.From)
+	inTheWay := tc.waitersPath(req.RobotID)
+
+	// Nearby cells, ones that clear the waiting robots' routes first
+	type option struct {
+		cell  models.Position
+		score int
+	}
+	var options []option
+	for dx := -sideStepRange; dx <= sideStepRange; dx++ {
+		for dy := -sideStepRange; dy <= sideStepRange; dy++ {
+			cell := models.Position{X: from.X + dx, Y: from.Y + dy}
+			distance := abs(dx) + abs(dy)
+			if distance == 0 || distance > sideStepRange || !tc.fits(req.RobotID, cell) || cell == topCell(req.To) {
+				continue
+			}
+			score := distance
+			for _, covered := range tc.cellsOf(req.RobotID, cell) {
+				if inTheWay[covered] {
+					score += 10 * sideStepRange
+				}
+			}
+			options = append(options, option{cell: cell, score: score})
+		}
+	}
+	sort.SliceStable(options, func(i, j int) bool { return options[i].score < options[j].score })
+
+	for _, option := range options {
+		legs := models.PlanRoute(from, option.cell, req.Axis)
+		step := req
+		step.To = option.cell
+		depart, windows, _, ok := tc.fit(step, legs, now)
+		if !ok {
+			continue
+		}
+		fmt.Printf("Traffic: Robot %d side-steps to (%d, %d)\n", req.RobotID, option.cell.X, option.cell.Y)
+		return models.RoutePlan{OK: true, Wait: depart.Sub(now), Legs: legs, Yield: true, RetryAfter: trafficRetry}, windows, true
+	}
+	return models.RoutePlan{}, nil, false
+}
+
+// waitersPath returns the cells on the direct routes of robots waiting for robotID, caller must hold the lock
+func (tc *TrafficController) waitersPath(robotID int) map[models.Position]bool {
+	cells := make(map[models.Position]bool)
+	for waiter, blocker := range tc.waitsFor {
+		if blocker != robotID {
+			continue
+		}
+		req := tc.waiting[waiter]
+		for _, leg := range models.PlanRoute(topCell(req.From), topCell(req.To), req.Axis) {
+			for _, cell := range leg.Cells() {
+				for _, covered := range tc.cellsOf(waiter, cell) {
+					cells[covered] = true
+				}
+			}
+		}
+	}
+	return cells
+}
+
+// findCycle follows who waits for whom from a robot and returns the cycle it closes, if any
+func (tc *TrafficController) findCycle(robotID int) []int {
+	var chain []int
+	seen := make(map[int]bool)
+	current := robotID
+	for {
+		if seen[current] {
+			if current != robotID {
+				return nil // A cycle further down the chain, found when one of its robots asks
+			}
+			return chain
+		}
+		seen[current] = true
+		chain = append(chain, current)
+
+		next, waiting := tc.waitsFor[current]
+		if !waiting {
+			return nil
+		}
+		current = next
+	}
+}
+
+// resolveDeadlock picks the lowest priority robot of a cycle, highest ID on ties, to side-step
+func (tc *TrafficController) resolveDeadlock(cycle []int) {
+	for _, robotID := range cycle {
+		if tc.yield[robotID] {
+			return // Already being resolved
+		}
+	}
+
+	loser := cycle[0]
+	for _, robotID := range cycle[1:] {
+		priority, loserPriority := tc.waiting[robotID].Priority, tc.waiting[loser].Priority
+		if priority < loserPriority || priority == loserPriority && robotID > loser {
+			loser = robotID
+		}
+	}
+	tc.yield[loser] = true
+	tc.metrics.Deadlocks++
+	fmt.Printf("Traffic: deadlock between robots %v, robot %d yields\n", cycle, loser)
+}
+
+// reserve stores a planned route, caller must hold the lock
+func (tc *TrafficController) reserve(robotID int, windows []cellWindow) {
+	for _, window := range windows {
+		if !window.end.IsZero() && !window.end.After(window.start) {
+			continue // Start cell with no wait
+		}
+		tc.cells[window.cell] = append(tc.cells[window.cell], reservation{
+			robotID: robotID,
+			start:   window.start,
+			end:     window.end,
+		})
+	}
+}
+
+// park holds a robot's cells until it plans its next route, caller must hold the lock
+func (tc *TrafficController) park(robotID int, pos models.Position, now time.Time) {
+	for _, cell := range tc.cellsOf(robotID, pos) {
+		tc.cells[cell] = append(tc.cells[cell], reservation{robotID: robotID, start: now})
+	}
+}
+
+// cellsOf returns the cells a robot covers with its gripper over pos, caller must hold the lock
+func (tc *TrafficController) cellsOf(robotID int, pos models.Position) []models.Position {
+	cells := make([]models.Position, 0, tc.footprintOf(robotID))
+	for i := 0; i < tc.footprintOf(robotID); i++ {
+		cells = append(cells, models.Position{X: pos.X + i, Y: pos.Y})
+	}
+	return cells
+}
+
+// footprintOf returns how many cells a robot covers, caller must hold the lock
+func (tc *TrafficController) footprintOf(robotID int) int {
+	if footprint := tc.footprint[robotID]; footprint > 1 {
+		return footprint
+	}
+	return 1
+}
+
+// fits reports whether a robot with its gripper over cell stays on the grid, caller must hold the lock
+func (tc *TrafficController) fits(robotID int, cell models.Position) bool {
+	return cell.X >= 0 && cell.Y >= 0 && cell.Y < tc.height &&
+		cell.X+tc.footprintOf(robotID)-1 < tc.width
+}
+
+// isFree reports whether no other robot has reserved any cell the robot would cover, caller must hold the lock
+func (tc *TrafficController) isFree(robotID int, pos models.Position) bool {
+	for _, cell := range tc.cellsOf(robotID, pos) {
+		for _, r := range tc.cells[cell] {
+			if r.robotID != robotID {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// release drops every reservation of a robot, caller must hold the lock
+func (tc *TrafficController) release(robotID int) {
+	for cell, reservations := range tc.cells {
+		kept := reservations[:0]
+		for _, r := range reservations {
+			if r.robotID != robotID {
+				kept = append(kept, r)
+			}
+		}
+		tc.setCell(cell, kept)
+	}
+}
+
+// prune drops windows that ended before now, caller must hold the lock
+func (tc *TrafficController) prune(now time.Time) {
+	for cell, reservations := range tc.cells {
+		kept := reservations[:0]
+		for _, r := range reservations {
+			if r.end.IsZero() || r.end.Add(trafficMargin).After(now) {
+				kept = append(kept, r)
+			}
+		}
+		tc.setCell(cell, kept)
+	}
+}
+
+// setCell stores a cell's reservations, dropping the key when none are left
+func (tc *TrafficController) setCell(cell models.Position, reservations []reservation) {
+	if len(reservations) == 0 {
+		delete(tc.cells, cell)
+		return
+	}
+	tc.cells[cell] = reservations
+}
+
+// legsThrough joins waypoints into straight legs, rejecting routes that double back on one axis
+func legsThrough(waypoints []models.Position) ([]models.Leg, bool) {
+	var legs []models.Leg
+	for i := 1; i < len(waypoints); i++ {
+		from, to := waypoints[i-1], waypoints[i]
+		if from == to {
+			continue
+		}
+		axis := models.AxisX
+		if from.X == to.X {
+			axis = models.AxisY
+		}
+		if len(legs) > 0 && legs[len(legs)-1].Axis == axis {
+			return nil, false
+		}
+		legs = append(legs, models.Leg{Axis: axis, From: from, To: to})
+	}
+	return legs, true
+}
+
+// legsKey identifies a route by its corners
+func legsKey(legs []models.Leg) string {
+	key := ""
+	for _, leg := range legs {
+		key += fmt.Sprintf("%d,%d>%d,%d;", leg.From.X, leg.From.Y, leg.To.X, leg.To.Y)
+	}
+	return key
+}
+
+// routeDistance returns the length of a route in metres
+func routeDistance(legs []models.Leg) float64 {
+	distance := 0.0
+	for _, leg := range legs {
+		distance += leg.Distance()
+	}
+	return distance
+}
+
+// topCell maps a position to its cell on the grid top, where robots drive
+func topCell(pos models.Position) models.Position {
+	return models.Position{X: pos.X, Y: pos.Y}
+}
+
+// cellKey formats a cell for the congestion map
+func cellKey(pos models.Position) string {
+	return fmt.Sprintf("%d,%d", pos.X, pos.Y)
+}
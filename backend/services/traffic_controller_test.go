@@ -0,0 +1,149 @@
+package services
+
+import (
+	"autostore-sim/backend/models"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fixedClock is a clock that stands still, so planned windows are exact
+type fixedClock struct{ now time.Time }
+
+func (c *fixedClock) Now() time.Time                         { return c.now }
+func (c *fixedClock) Sleep(d time.Duration)                  { c.now = c.now.Add(d) }
+func (c *fixedClock) After(d time.Duration) <-chan time.Time { return time.After(0) }
+func (c *fixedClock) NewTicker(d time.Duration) *time.Ticker { return time.NewTicker(d) }
+
+// secondPerCell times a leg at one second per cell travelled, whatever the axis
+func secondPerCell(leg models.Leg, current models.Axis) time.Duration {
+	return time.Duration(len(leg.Cells())-1) * time.Second
+}
+
+// routeStep is one route request and what the controller must answer
+type routeStep struct {
+	robot    int
+	from, to models.Position
+	priority int
+	ok       bool
+	wait     time.Duration
+	yield    bool
+	end      models.Position // Cell the legs end in when ok
+}
+
+func TestTrafficController(t *testing.T) {
+	cell := func(x, y int) models.Position { return models.Position{X: x, Y: y} }
+
+	tests := []struct {
+		name          string
+		width, height int
+		parked        map[int]models.Position
+		steps         []routeStep
+		blockers      []int
+		metrics       TrafficMetrics
+	}{
+		{
+			// B's column crosses A's row while A drives it, B waits until A is past and clear
+			name: "crossing", width: 5, height: 5,
+			parked: map[int]models.Position{1: cell(0, 2), 2: cell(2, 0)},
+			steps: []routeStep{
+				{robot: 1, from: cell(0, 2), to: cell(4, 2), ok: true, end: cell(4, 2)},
+				{robot: 2, from: cell(2, 0), to: cell(2, 4), ok: true, wait: 4*time.Second + trafficMargin, end: cell(2, 4)},
+			},
+			metrics: TrafficMetrics{Routes: 2, Delayed: 1},
+		},
+		{
+			// A single row leaves no way around the parked robot, A is told to retry
+			name: "parked robot in the way", width: 3, height: 1,
+			parked: map[int]models.Position{1: cell(0, 0), 2: cell(1, 0)},
+			steps: []routeStep{
+				{robot: 1, from: cell(0, 0), to: cell(2, 0)},
+			},
+			blockers: []int{2},
+			metrics:  TrafficMetrics{Blocked: 1},
+		},
+		{
+			// A detour along the free row passes the parked robot
+			name: "detour around a parked robot", width: 3, height: 2,
+			parked: map[int]models.Position{1: cell(0, 0), 2: cell(1, 0)},
+			steps: []routeStep{
+				{robot: 1, from: cell(0, 0), to: cell(2, 0), ok: true, end: cell(2, 0)},
+			},
+			metrics: TrafficMetrics{Routes: 1, Detours: 1},
+		},
+		{
+			// Each wants the other's cell: B has the lower priority and side-steps off the row A
+			// needs, then A drives once B has left
+			name: "two-robot cycle", width: 3, height: 2,
+			parked: map[int]models.Position{1: cell(0, 0), 2: cell(2, 0)},
+			steps: []routeStep{
+				{robot: 1, from: cell(0, 0), to: cell(2, 0), priority: 2},
+				{robot: 2, from: cell(2, 0), to: cell(0, 0), priority: 1},
+				{robot: 2, from: cell(2, 0), to: cell(0, 0), priority: 1, ok: true, yield: true, end: cell(2, 1)},
+				{robot: 1, from: cell(0, 0), to: cell(2, 0), priority: 2, ok: true, wait: time.Second + trafficMargin, end: cell(2, 0)},
+			},
+			metrics: TrafficMetrics{Routes: 1, Blocked: 2, Deadlocks: 1, Yields: 1, Delayed: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := NewTrafficController(tt.width, tt.height, &fixedClock{now: time.Unix(0, 0)})
+			for id := 1; id <= len(tt.parked); id++ {
+				tc.Register(id, tt.parked[id], 1)
+			}
+
+			for i, step := range tt.steps {
+				plan := tc.RequestRoute(models.RouteRequest{
+					RobotID: step.robot, From: step.from, To: step.to, Priority: step.priority, LegTime: secondPerCell,
+				})
+				if plan.OK != step.ok || plan.Yield != step.yield || plan.Wait != step.wait {
+					t.Fatalf("step %d, robot %d: got ok %v yield %v wait %v, want ok %v yield %v wait %v",
+						i, step.robot, plan.OK, plan.Yield, plan.Wait, step.ok, step.yield, step.wait)
+				}
+				if !plan.OK {
+					if plan.RetryAfter != trafficRetry {
+						t.Errorf("step %d: retry after %v", i, plan.RetryAfter)
+					}
+					continue
+				}
+				if end := plan.Legs[len(plan.Legs)-1].To; end != step.end {
+					t.Errorf("step %d: route ends at %v, want %v", i, end, step.end)
+				}
+			}
+
+			if got := tc.Blockers(); fmt.Sprint(got) != fmt.Sprint(tt.blockers) {
+				t.Errorf("blockers %v, want %v", got, tt.blockers)
+			}
+			m := tc.GetMetrics()
+			got := [6]int{m.Routes, m.Delayed, m.Detours, m.Blocked, m.Deadlocks, m.Yields}
+			want := [6]int{tt.metrics.Routes, tt.metrics.Delayed, tt.metrics.Detours, tt.metrics.Blocked, tt.metrics.Deadlocks, tt.metrics.Yields}
+			if got != want {
+				t.Errorf("routes, delayed, detours, blocked, deadlocks, yields = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestTrafficReservationsNeverOverlap checks that two robots never hold one cell at overlapping times
+func TestTrafficReservationsNeverOverlap(t *testing.T) {
+	tc := NewTrafficController(4, 4, &fixedClock{now: time.Unix(0, 0)})
+	starts := []models.Position{{X: 0, Y: 0}, {X: 3, Y: 0}, {X: 0, Y: 3}, {X: 3, Y: 3}}
+	for i, pos := range starts {
+		tc.Register(i+1, pos, 1)
+	}
+	for i, from := range starts {
+		to := starts[(i+2)%len(starts)] // Across the grid, routes meet in the middle
+		tc.RequestRoute(models.RouteRequest{RobotID: i + 1, From: from, To: to, LegTime: secondPerCell})
+	}
+
+	for cell, reservations := range tc.cells {
+		for i, a := range reservations {
+			for _, b := range reservations[i+1:] {
+				if a.robotID != b.robotID && a.overlaps(b.start, b.end) {
+					t.Errorf("cell %v: robots %d and %d overlap: %+v %+v", cell, a.robotID, b.robotID, a, b)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package simulation
+
+import (
+	"autostore-sim/backend/models"
+	"autostore-sim/backend/services"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// RunReport summarises a finished simulation run
+type RunReport struct {
+	Config          Config                   `json:"config"`
+	SimulatedHours  float64                  `json:"simulated_hours"`
+	WallSeconds     float64                  `json:"wall_seconds"`
+	OrdersCreated   int                      `json:"orders_created"`
+	OrdersCompleted int                      `json:"orders_completed"`
+	OrdersFailed    int                      `json:"orders_failed"`
+	OrdersOpen      int                      `json:"orders_open"` // Still pending or in progress at the end
+	Receipts        int                      `json:"receipts"`
+	UnitsReceived   int                      `json:"units_received"`
+	Events          map[models.EventType]int `json:"events"`            // Count per event type, e.g. out_of_stock
+	LayoutCost      services.LayoutCost      `json:"layout_cost"`       // Initial layout
+	FinalLayoutCost services.LayoutCost      `json:"final_layout_cost"` // After bins were returned by the policy
+	LayoutHistory   []services.LayoutSample  `json:"layout_history,omitempty"`
+	KPIs            services.KPIReport       `json:"kpis"`
+	Traffic         services.TrafficMetrics  `json:"traffic"`
+	Fleet           []FleetStats             `json:"fleet"` // Per robot type, to compare mixed fleets
+	Orders          []OrderRecord            `json:"orders,omitempty"`
+}
+
+// FleetStats summarises the robots of one type
+type FleetStats struct {
+	Type            string  `json:"type"`
+	Robots          int     `json:"robots"`
+	OrdersCompleted int     `json:"orders_completed"`
+	EnergyWh        float64 `json:"energy_wh"`
+	BatteryHours    float64 `json:"battery_hours"` // How long a full battery lasts at this run's average draw
+}
+
+// OrderRecord is one order line in a run report
+type OrderRecord struct {
+	ID              int                `json:"id"`
+	CustomerName    string             `json:"customer_name"`
+	ProductID       int                `json:"product_id"`
+	RequestedQty    int                `json:"requested_qty"`
+	Priority        models.Priority    `json:"priority"`
+	Status          models.OrderStatus `json:"status"`
+	AssignedRobot   int                `json:"assigned_robot"`
+	CreatedAt       time.Time          `json:"created_at"`
+	CompletedAt     *time.Time         `json:"completed_at,omitempty"`
+	LeadTimeSeconds float64            `json:"lead_time_s,omitempty"`
+}
+
+// Run executes a headless simulation for the given simulated duration and reports on it.
+// When ctx ends early the report covers the time simulated so far.
+func Run(ctx context.Context, cfg Config, duration time.Duration) (*RunReport, error) {
+	sim, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	wallStart := time.Now()
+	ran := sim.RunFor(ctx, duration)
+	report := sim.Report(ran)
+	report.WallSeconds = time.Since(wallStart).Seconds()
+	return report, nil
+}
+
+// Report builds a run report covering the last d of simulated time
+func (s *Simulation) Report(d time.Duration) *RunReport {
+	report := &RunReport{
+		Config:         s.Config,
+		SimulatedHours: d.Hours(),
+		LayoutCost:     s.LayoutCost,
+		KPIs:           s.Analytics.Report(d),
+	}
+	report.FinalLayoutCost = s.Slotting.RecordLayoutSample().Cost
+	report.LayoutHistory = s.Slotting.GetLayoutHistory()
+
+	for _, order := range s.Orders.GetAllOrders() {
+		record := OrderRecord{
+			ID:            order.ID,
+			CustomerName:  order.CustomerName,
+			ProductID:     order.ProductID,
+			RequestedQty:  order.RequestedQty,
+			Priority:      order.Priority,
+			Status:        order.Status,
+			AssignedRobot: order.AssignedRobot,
+			CreatedAt:     order.CreatedAt,
+			CompletedAt:   order.CompletedAt,
+		}
+
+		switch order.Status {
+		case models.OrderCompleted:
+			report.OrdersCompleted++
+			record.LeadTimeSeconds = order.CompletedAt.Sub(order.CreatedAt).Seconds()
+		case models.OrderFailed:
+			report.OrdersFailed++
+		default:
+			report.OrdersOpen++
+		}
+
+		report.Orders = append(report.Orders, record)
+	}
+	report.OrdersCreated = len(report.Orders)
+
+	for _, receipt := range s.Receiving.GetAllReceipts() {
+		report.Receipts++
+		report.UnitsReceived += receipt.ReceivedQty
+	}
+
+	report.Events = s.Events.Counts()
+	report.Traffic = s.Traffic.GetMetrics()
+	report.Fleet = s.fleetStats(report.Orders, d)
+
+	return report
+}
+
+// fleetStats groups robots by type with their completed orders and energy use
+func (s *Simulation) fleetStats(orders []OrderRecord, d time.Duration) []FleetStats {
+	completedBy := make(map[int]int)
+	for _, order := range orders {
+		if order.Status == models.OrderCompleted {
+			completedBy[order.AssignedRobot]++
+		}
+	}
+
+	var stats []FleetStats
+	index := make(map[string]int)
+	for _, robot := range s.allRobots() {
+		robotType := robot.RobotType()
+		i, ok := index[robotType.Name]
+		if !ok {
+			i = len(stats)
+			index[robotType.Name] = i
+			stats = append(stats, FleetStats{Type: robotType.Name})
+		}
+		stats[i].Robots++
+		stats[i].OrdersCompleted += completedBy[robot.ID]
+		stats[i].EnergyWh += robot.Snapshot().EnergyWh
+	}
+
+	for i := range stats {
+		robotType := s.robotType(stats[i].Type)
+		perRobotWatts := stats[i].EnergyWh / float64(stats[i].Robots) / d.Hours()
+		if perRobotWatts > 0 && robotType != nil {
+			stats[i].BatteryHours = robotType.BatteryKWh * 1000 / perRobotWatts
+		}
+	}
+	return stats
+}
+
+// allRobots returns the active fleet followed by robots removed during the run
+func (s *Simulation) allRobots() []*models.Robot {
+	s.robotsMu.Lock()
+	defer s.robotsMu.Unlock()
+	return append(append([]*models.Robot(nil), s.Robots...), s.retired...)
+}
+
+// robotType returns the type of the first robot with the given type name
+func (s *Simulation) robotType(name string) *models.RobotType {
+	for _, robot := range s.allRobots() {
+		if robot.RobotType().Name == name {
+			return robot.RobotType()
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the full report, including per-order records, as indented JSON
+func (r *RunReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteOrdersCSV writes one CSV row per order
+func (r *RunReport) WriteOrdersCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"id", "customer_name", "product_id", "requested_qty", "priority",
+		"status", "assigned_robot", "created_at", "completed_at", "lead_time_s",
+	})
+
+	for _, order := range r.Orders {
+		completedAt := ""
+		if order.CompletedAt != nil {
+			completedAt = order.CompletedAt.Format(time.RFC3339Nano)
+		}
+		writer.Write([]string{
+			strconv.Itoa(order.ID),
+			order.CustomerName,
+			strconv.Itoa(order.ProductID),
+			strconv.Itoa(order.RequestedQty),
+			string(order.Priority),
+			string(order.Status),
+			strconv.Itoa(order.AssignedRobot),
+			order.CreatedAt.Format(time.RFC3339Nano),
+			completedAt,
+			formatFloat(order.LeadTimeSeconds),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// summaryHeader lists the columns of a KPI summary row
+var summaryHeader = []string{
+	"robots", "orders_per_hour_demand", "placement", "simulated_hours",
+	"orders_created", "orders_completed", "orders_failed", "orders_open",
+	"orders_per_hour", "lines_per_hour", "units_per_hour",
+	"avg_lead_time_s", "p95_lead_time_s", "robot_utilisation",
+	"travelling_share", "lifting_share", "idle_share",
+	"layout_expected_digs", "layout_seconds_per_pick",
+	"return_policy", "final_expected_digs", "final_seconds_per_pick",
+	"receipts", "units_received", "low_stock_events", "out_of_stock_events",
+	"waiting_share", "traffic_delayed", "traffic_detours", "traffic_blocked", "deadlocks",
+}
+
+// summaryRow flattens the KPIs of a report into a CSV row
+func (r *RunReport) summaryRow() []string {
+	return []string{
+		strconv.Itoa(r.Config.Robots),
+		formatFloat(r.Config.OrdersPerHour),
+		r.LayoutCost.Strategy,
+		formatFloat(r.SimulatedHours),
+		strconv.Itoa(r.OrdersCreated),
+		strconv.Itoa(r.OrdersCompleted),
+		strconv.Itoa(r.OrdersFailed),
+		strconv.Itoa(r.OrdersOpen),
+		formatFloat(r.KPIs.OrdersPerHour),
+		formatFloat(r.KPIs.LinesPerHour),
+		formatFloat(r.KPIs.UnitsPerHour),
+		formatFloat(r.KPIs.AvgLeadTimeSeconds),
+		formatFloat(r.KPIs.P95LeadTimeSeconds),
+		formatFloat(r.KPIs.RobotUtilisation),
+		formatFloat(r.KPIs.TimeShare.Travelling),
+		formatFloat(r.KPIs.TimeShare.Lifting),
+		formatFloat(r.KPIs.TimeShare.Idle),
+		formatFloat(r.LayoutCost.ExpectedDigs),
+		formatFloat(r.LayoutCost.SecondsPerPick),
+		r.FinalLayoutCost.Strategy,
+		formatFloat(r.FinalLayoutCost.ExpectedDigs),
+		formatFloat(r.FinalLayoutCost.SecondsPerPick),
+		strconv.Itoa(r.Receipts),
+		strconv.Itoa(r.UnitsReceived),
+		strconv.Itoa(r.Events[models.EventLowStock]),
+		strconv.Itoa(r.Events[models.EventOutOfStock]),
+		formatFloat(r.KPIs.TimeShare.Waiting),
+		strconv.Itoa(r.Traffic.Delayed),
+		strconv.Itoa(r.Traffic.Detours),
+		strconv.Itoa(r.Traffic.Blocked),
+		strconv.Itoa(r.Traffic.Deadlocks),
+	}
+}
+
+// WriteSummaryCSV writes a header and one KPI row per report, e.g. for a parameter sweep
+func WriteSummaryCSV(w io.Writer, reports []*RunReport) error {
+	writer := csv.NewWriter(w)
+	writer.Write(summaryHeader)
+	for _, report := range reports {
+		writer.Write(report.summaryRow())
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatFloat prints floats compactly for CSV output
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,391 @@
+package simulation
+
+import (
+	"autostore-sim/backend/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+)
+
+// Scenario action types
+const (
+	ActionCreateOrder = "create_order" // Add an order directly to the queue
+	ActionRobotFault  = "robot_fault"  // Break a robot down for a while
+	ActionAPI         = "api"          // Call a REST endpoint in-process
+)
+
+// Scenario is a scripted test case: initial state, timed actions and expected outcomes
+type Scenario struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description,omitempty"`
+	Duration    Duration              `json:"duration"` // Simulated run time
+	Config      Config                `json:"config"`
+	Layout      []models.BinPlacement `json:"layout,omitempty"` // Random placement when empty
+	Robots      []models.Position     `json:"robots,omitempty"` // Start positions, overrides config.robots
+	Script      []ScriptAction        `json:"script,omitempty"`
+	Expect      []Expectation         `json:"expect,omitempty"`
+}
+
+// ScriptAction is one timed step of a scenario script
+type ScriptAction struct {
+	At     Duration `json:"at"`     // Offset from scenario start in simulated time
+	Action string   `json:"action"` // create_order, robot_fault or api
+	Ref    string   `json:"ref,omitempty"`
+
+	// create_order
+	CustomerName string          `json:"customer_name,omitempty"`
+	ProductID    int             `json:"product_id,omitempty"`
+	Quantity     int             `json:"quantity,omitempty"`
+	Priority     models.Priority `json:"priority,omitempty"`
+
+	// robot_fault, takes effect once the robot has finished its queued commands
+	RobotID  int      `json:"robot_id,omitempty"`
+	Duration Duration `json:"duration,omitempty"`
+
+	// api
+	Method       string          `json:"method,omitempty"`
+	Path         string          `json:"path,omitempty"`
+	Body         json.RawMessage `json:"body,omitempty"`
+	ExpectStatus int             `json:"expect_status,omitempty"`
+}
+
+// Expectation is an outcome checked when the scenario ends
+type Expectation struct {
+	// Order checks refer to orders by the ref given in the script
+	Order           string             `json:"order,omitempty"`
+	Status          models.OrderStatus `json:"status,omitempty"`
+	MaxLeadTime     Duration           `json:"max_lead_time,omitempty"`
+	CompletedBefore string             `json:"completed_before,omitempty"`
+
+	// Metric checks compare a report value against bounds
+	Metric string   `json:"metric,omitempty"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+}
+
+// ScenarioResult is the outcome of running a scenario
+type ScenarioResult struct {
+	Name         string        `json:"name"`
+	Passed       bool          `json:"passed"`
+	ActionErrors []string      `json:"action_errors,omitempty"`
+	Checks       []CheckResult `json:"checks"`
+	Report       *RunReport    `json:"report"`
+}
+
+// CheckResult is the outcome of one expectation
+type CheckResult struct {
+	Description string `json:"description"`
+	Passed      bool   `json:"passed"`
+	Detail      string `json:"detail"`
+}
+
+// scenarioMetrics maps metric names usable in expectations to report values
+var scenarioMetrics = map[string]func(*RunReport) float64{
+	"orders_created":    func(r *RunReport) float64 { return float64(r.OrdersCreated) },
+	"orders_completed":  func(r *RunReport) float64 { return float64(r.OrdersCompleted) },
+	"orders_failed":     func(r *RunReport) float64 { return float64(r.OrdersFailed) },
+	"orders_open":       func(r *RunReport) float64 { return float64(r.OrdersOpen) },
+	"orders_per_hour":   func(r *RunReport) float64 { return r.KPIs.OrdersPerHour },
+	"avg_lead_time_s":   func(r *RunReport) float64 { return r.KPIs.AvgLeadTimeSeconds },
+	"p95_lead_time_s":   func(r *RunReport) float64 { return r.KPIs.P95LeadTimeSeconds },
+	"robot_utilisation": func(r *RunReport) float64 { return r.KPIs.RobotUtilisation },
+}
+
+// LoadScenario reads a scenario JSON file, filling unspecified config from the defaults
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	scenario := &Scenario{Config: DefaultConfig()}
+	if err := json.Unmarshal(data, scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+	if scenario.Name == "" {
+		scenario.Name = path
+	}
+
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return scenario, nil
+}
+
+// Validate checks the script and expectations refer to known actions, orders and metrics
+func (sc *Scenario) Validate() error {
+	if sc.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+
+	refs := make(map[string]bool)
+	for i, action := range sc.Script {
+		switch action.Action {
+		case ActionCreateOrder:
+			if action.ProductID == 0 || action.Quantity < 1 {
+				return fmt.Errorf("script step %d: create_order needs product_id and quantity", i)
+			}
+		case ActionRobotFault:
+			if action.RobotID < 1 || action.Duration <= 0 {
+				return fmt.Errorf("script step %d: robot_fault needs robot_id and duration", i)
+			}
+		case ActionAPI:
+			if action.Method == "" || action.Path == "" {
+				return fmt.Errorf("script step %d: api needs method and path", i)
+			}
+		default:
+			return fmt.Errorf("script step %d: unknown action %q", i, action.Action)
+		}
+		if action.Ref != "" {
+			refs[action.Ref] = true
+		}
+	}
+
+	for i, expect := range sc.Expect {
+		switch {
+		case expect.Order != "":
+			if !refs[expect.Order] {
+				return fmt.Errorf("expectation %d: unknown order ref %q", i, expect.Order)
+			}
+			if expect.CompletedBefore != "" && !refs[expect.CompletedBefore] {
+				return fmt.Errorf("expectation %d: unknown order ref %q", i, expect.CompletedBefore)
+			}
+		case expect.Metric != "":
+			if _, ok := scenarioMetrics[expect.Metric]; !ok {
+				return fmt.Errorf("expectation %d: unknown metric %q", i, expect.Metric)
+			}
+		default:
+			return fmt.Errorf("expectation %d: needs an order or a metric", i)
+		}
+	}
+	return nil
+}
+
+// RunScenario builds the scenario's initial state, plays its script and checks the expectations.
+// API actions run in-process against the handler newAPI builds for the simulation, e.g. the
+// REST router; they fail when newAPI is nil.
+func RunScenario(sc *Scenario, newAPI func(*Simulation) http.Handler) (*ScenarioResult, error) {
+	cfg := sc.Config
+	if len(sc.Robots) > 0 {
+		cfg.Robots = len(sc.Robots)
+	}
+
+	var layout []models.BinPlacement
+	if len(sc.Layout) > 0 {
+		layout = sc.Layout
+	}
+
+	sim, err := build(cfg, layout, sc.Robots)
+	if err != nil {
+		return nil, err
+	}
+
+	var router http.Handler
+	if newAPI != nil {
+		router = newAPI(sim)
+	}
+
+	script := append([]ScriptAction(nil), sc.Script...)
+	sort.SliceStable(script, func(i, j int) bool { return script[i].At < script[j].At })
+
+	result := &ScenarioResult{Name: sc.Name}
+	refs := make(map[string]int)
+
+	sim.Start(context.Background())
+	start := sim.Clock.Now()
+	for _, action := range script {
+		if wait := start.Add(time.Duration(action.At)).Sub(sim.Clock.Now()); wait > 0 {
+			sim.Clock.Sleep(wait)
+		}
+		if err := sim.runAction(action, router, refs); err != nil {
+			result.ActionErrors = append(result.ActionErrors,
+				fmt.Sprintf("at %v %s: %v", time.Duration(action.At), action.Action, err))
+		}
+	}
+	if wait := start.Add(time.Duration(sc.Duration)).Sub(sim.Clock.Now()); wait > 0 {
+		sim.Clock.Sleep(wait)
+	}
+	sim.Shutdown(context.Background())
+
+	result.Report = sim.Report(time.Duration(sc.Duration))
+	result.Passed = len(result.ActionErrors) == 0
+	for _, expect := range sc.Expect {
+		check := evaluate(expect, result.Report, refs)
+		result.Checks = append(result.Checks, check)
+		result.Passed = result.Passed && check.Passed
+	}
+
+	return result, nil
+}
+
+// runAction executes one script step, recording created orders under the step's ref
+func (s *Simulation) runAction(action ScriptAction, router http.Handler, refs map[string]int) error {
+	switch action.Action {
+	case ActionCreateOrder:
+		priority := action.Priority
+		if priority == "" {
+			priority = models.PriorityNormal
+		}
+		customer := action.CustomerName
+		if customer == "" {
+			customer = "Scenario Customer"
+		}
+
+		order := s.Orders.CreateOrder(customer, action.ProductID, action.Quantity, priority)
+		if order == nil {
+			return fmt.Errorf("unknown product %d", action.ProductID)
+		}
+		if action.Ref != "" {
+			refs[action.Ref] = order.ID
+		}
+
+	case ActionRobotFault:
+		for _, robot := range s.ActiveRobots() {
+			if robot.ID == action.RobotID {
+				robot.Commands <- models.RobotCommand{Type: "fault", Duration: time.Duration(action.Duration)}
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown robot %d", action.RobotID)
+
+	case ActionAPI:
+		if router == nil {
+			return fmt.Errorf("no API to call %s %s on", action.Method, action.Path)
+		}
+		req, err := http.NewRequest(action.Method, action.Path, bytes.NewReader(action.Body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		recorder := &responseRecorder{header: make(http.Header), code: http.StatusOK}
+		router.ServeHTTP(recorder, req)
+
+		if action.ExpectStatus != 0 && recorder.code != action.ExpectStatus {
+			return fmt.Errorf("%s %s returned %d, expected %d: %s",
+				action.Method, action.Path, recorder.code, action.ExpectStatus, recorder.body.String())
+		}
+
+		// Endpoints that create orders answer with {"order": {...}}
+		if action.Ref != "" {
+			var response struct {
+				Order *models.Order `json:"order"`
+			}
+			if err := json.Unmarshal(recorder.body.Bytes(), &response); err != nil || response.Order == nil {
+				return fmt.Errorf("response has no order to record as %q", action.Ref)
+			}
+			refs[action.Ref] = response.Order.ID
+		}
+	}
+	return nil
+}
+
+// responseRecorder keeps the response of an in-process API call
+type responseRecorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header         { return r.header }
+func (r *responseRecorder) Write(b []byte) (int, error) { return r.body.Write(b) }
+func (r *responseRecorder) WriteHeader(code int)        { r.code = code }
+
+// evaluate checks one expectation against the final report
+func evaluate(expect Expectation, report *RunReport, refs map[string]int) CheckResult {
+	if expect.Metric != "" {
+		value := scenarioMetrics[expect.Metric](report)
+		check := CheckResult{
+			Description: fmt.Sprintf("metric %s%s", expect.Metric, boundsText(expect.Min, expect.Max)),
+			Passed:      true,
+			Detail:      fmt.Sprintf("got %g", value),
+		}
+		if expect.Min != nil && value < *expect.Min {
+			check.Passed = false
+		}
+		if expect.Max != nil && value > *expect.Max {
+			check.Passed = false
+		}
+		return check
+	}
+
+	check := CheckResult{Description: "order " + expect.Order, Passed: true}
+	id, ok := refs[expect.Order]
+	if !ok {
+		// The step with this ref failed and is listed in the action errors
+		check.Passed = false
+		check.Detail = "no order recorded, its script step failed"
+		return check
+	}
+	order := findOrderRecord(report, id)
+	if order == nil {
+		check.Passed = false
+		check.Detail = "order was never created"
+		return check
+	}
+
+	if expect.Status != "" {
+		check.Description += fmt.Sprintf(" status %s", expect.Status)
+		check.Detail = fmt.Sprintf("status %s", order.Status)
+		check.Passed = order.Status == expect.Status
+	}
+
+	if expect.MaxLeadTime > 0 {
+		check.Description += fmt.Sprintf(" lead time <= %v", time.Duration(expect.MaxLeadTime))
+		if order.CompletedAt == nil {
+			check.Passed = false
+			check.Detail = fmt.Sprintf("not completed (status %s)", order.Status)
+		} else {
+			leadTime := order.CompletedAt.Sub(order.CreatedAt)
+			check.Detail = fmt.Sprintf("lead time %v", leadTime.Round(time.Millisecond))
+			check.Passed = check.Passed && leadTime <= time.Duration(expect.MaxLeadTime)
+		}
+	}
+
+	if expect.CompletedBefore != "" {
+		check.Description += " completed before " + expect.CompletedBefore
+		otherID, recorded := refs[expect.CompletedBefore]
+		other := findOrderRecord(report, otherID)
+		check.Detail = "completed first"
+		switch {
+		case !recorded:
+			check.Passed = false
+			check.Detail = fmt.Sprintf("no order recorded as %s, its script step failed", expect.CompletedBefore)
+		case order.CompletedAt == nil:
+			check.Passed = false
+			check.Detail = fmt.Sprintf("not completed (status %s)", order.Status)
+		case other != nil && other.CompletedAt != nil && other.CompletedAt.Before(*order.CompletedAt):
+			check.Passed = false
+			check.Detail = fmt.Sprintf("%s completed %v earlier", expect.CompletedBefore,
+				order.CompletedAt.Sub(*other.CompletedAt).Round(time.Millisecond))
+		}
+	}
+
+	return check
+}
+
+// findOrderRecord returns the report record for an order ID, or nil
+func findOrderRecord(report *RunReport, id int) *OrderRecord {
+	for i := range report.Orders {
+		if report.Orders[i].ID == id {
+			return &report.Orders[i]
+		}
+	}
+	return nil
+}
+
+// boundsText describes metric bounds like " >= 3 <= 10"
+func boundsText(min, max *float64) string {
+	text := ""
+	if min != nil {
+		text += fmt.Sprintf(" >= %g", *min)
+	}
+	if max != nil {
+		text += fmt.Sprintf(" <= %g", *max)
+	}
+	return text
+}
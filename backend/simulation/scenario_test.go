@@ -0,0 +1,89 @@
+package simulation
+
+import (
+	"autostore-sim/backend/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScenarioValidate(t *testing.T) {
+	order := ScriptAction{Action: ActionCreateOrder, Ref: "a", ProductID: 1, Quantity: 1}
+	api := ScriptAction{Action: ActionAPI, Ref: "b", Method: "POST", Path: "/api/orders"}
+	fault := ScriptAction{Action: ActionRobotFault, RobotID: 1, Duration: Duration(time.Minute)}
+	one := 1.0
+
+	tests := []struct {
+		name   string
+		script []ScriptAction
+		expect []Expectation
+		err    string // Empty when valid
+	}{
+		{name: "valid", script: []ScriptAction{order, api, fault}, expect: []Expectation{
+			{Order: "a", Status: models.OrderCompleted},
+			{Order: "b", CompletedBefore: "a"},
+			{Metric: "orders_completed", Min: &one},
+		}},
+		{name: "order without quantity", script: []ScriptAction{{Action: ActionCreateOrder, ProductID: 1}},
+			err: "script step 0: create_order needs product_id and quantity"},
+		{name: "order without product", script: []ScriptAction{{Action: ActionCreateOrder, Quantity: 1}},
+			err: "script step 0: create_order needs product_id and quantity"},
+		{name: "fault without duration", script: []ScriptAction{order, {Action: ActionRobotFault, RobotID: 1}},
+			err: "script step 1: robot_fault needs robot_id and duration"},
+		{name: "api without path", script: []ScriptAction{{Action: ActionAPI, Method: "GET"}},
+			err: "script step 0: api needs method and path"},
+		{name: "unknown action", script: []ScriptAction{{Action: "teleport"}},
+			err: `script step 0: unknown action "teleport"`},
+		{name: "unknown order ref", script: []ScriptAction{order}, expect: []Expectation{{Order: "z"}},
+			err: `expectation 0: unknown order ref "z"`},
+		{name: "unknown completed before ref", script: []ScriptAction{order}, expect: []Expectation{{Order: "a", CompletedBefore: "z"}},
+			err: `expectation 0: unknown order ref "z"`},
+		{name: "unknown metric", expect: []Expectation{{Metric: "happiness"}},
+			err: `expectation 0: unknown metric "happiness"`},
+		{name: "empty expectation", expect: []Expectation{{}},
+			err: "expectation 0: needs an order or a metric"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := Scenario{Duration: Duration(time.Minute), Script: tt.script, Expect: tt.expect}
+			err := sc.Validate()
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	if err := (&Scenario{}).Validate(); err == nil || !strings.Contains(err.Error(), "duration") {
+		t.Errorf("scenario without duration: %v", err)
+	}
+}
+
+// TestEvaluateUnrecordedRef checks that an order whose script step failed is reported as such,
+// not as never created, and that it fails checks comparing against it
+func TestEvaluateUnrecordedRef(t *testing.T) {
+	done := time.Unix(60, 0)
+	report := &RunReport{Orders: []OrderRecord{
+		{ID: 1, Status: models.OrderCompleted, CreatedAt: time.Unix(0, 0), CompletedAt: &done},
+	}}
+	refs := map[string]int{"normal": 1, "lost": 2} // "express" failed, "lost" was created but is not in the report
+
+	tests := []struct {
+		name   string
+		expect Expectation
+		passed bool
+		detail string
+	}{
+		{"recorded", Expectation{Order: "normal", Status: models.OrderCompleted}, true, "status completed"},
+		{"step failed", Expectation{Order: "express", Status: models.OrderCompleted}, false, "no order recorded, its script step failed"},
+		{"not in the report", Expectation{Order: "lost"}, false, "order was never created"},
+		{"before a failed step", Expectation{Order: "normal", CompletedBefore: "express"}, false, "no order recorded as express, its script step failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := evaluate(tt.expect, report, refs)
+			if check.Passed != tt.passed || check.Detail != tt.detail {
+				t.Errorf("passed %v with %q, want %v with %q", check.Passed, check.Detail, tt.passed, tt.detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package simulation
+
+import (
+	ws "autostore-sim/backend/websocket"
+)
+
+// BroadcastTo forwards robot updates, events, order and bin changes and removed robots to the
+// hub's clients. Call it before Start.
+func (s *Simulation) BroadcastTo(hub *ws.Hub) {
+	s.OnRobotUpdate = hub.BroadcastRobotUpdate
+	s.OnEvent = hub.BroadcastEvent
+	s.OnOrderChanged = hub.BroadcastOrderUpdate
+	s.OnBinMoved = hub.BroadcastBinMoved
+	s.OnRobotRemoved = hub.BroadcastRobotRemoved
+}
@@ -0,0 +1,658 @@
+package simulation
+
+import (
+	"autostore-sim/backend/models"
+	"autostore-sim/backend/services"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config describes a simulation run: warehouse size, fleet, demand and clock
+type Config struct {
+	Width           int      `json:"width"`
+	Height          int      `json:"height"`
+	Levels          int      `json:"levels"`
+	Robots          int      `json:"robots"`
+	ProductsFile    string   `json:"products_file"`
+	ClockSpeed      float64  `json:"clock_speed"`      // Simulated seconds per wall-clock second
+	OrdersPerHour   float64  `json:"orders_per_hour"`  // Random demand, 0 disables the generator
+	ProcessInterval Duration `json:"process_interval"` // How often pending orders are assigned
+	Placement       string   `json:"placement"`        // random, velocity, category or layout
+	LayoutFile      string   `json:"layout_file,omitempty"`
+	Seed            int64    `json:"seed,omitempty"` // Reproducible placement when non-zero
+
+	ReturnPolicy          string   `json:"return_policy"`           // same_stack, nearest_top or popularity
+	Housekeeping          bool     `json:"housekeeping"`            // Idle robots move hot bins up
+	HousekeepingInterval  Duration `json:"housekeeping_interval"`   // How often idle robots are put to work
+	LayoutMetricsInterval Duration `json:"layout_metrics_interval"` // How often layout cost is sampled
+
+	Compartments int `json:"compartments"` // Compartments per bin: 1, 2, 4 or 8
+	EmptyBins    int `json:"empty_bins"`   // Spare bins for receiving, stored in the least accessible positions
+
+	AutoReorder        bool     `json:"auto_reorder"`         // Raise receipts when products reach their reorder point
+	StockCheckInterval Duration `json:"stock_check_interval"` // How often stock is compared with reorder points
+
+	RobotTypes []models.RobotType `json:"robot_types,omitempty"` // Robot models on top of the built-in r5 and cantilever
+	Fleet      []FleetEntry       `json:"fleet,omitempty"`       // Robots per type, replaces Robots when set
+}
+
+// FleetEntry is a number of robots of one type
+type FleetEntry struct {
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// ParseFleet parses "type=count,type=count", e.g. r5=4,cantilever=2
+func ParseFleet(spec string) ([]FleetEntry, error) {
+	var fleet []FleetEntry
+	for _, part := range strings.Split(spec, ",") {
+		name, countSpec, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid fleet entry %q: expected type=count", part)
+		}
+		count, err := strconv.Atoi(countSpec)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("invalid robot count %q for type %q", countSpec, name)
+		}
+		fleet = append(fleet, FleetEntry{Type: name, Count: count})
+	}
+	return fleet, nil
+}
+
+// robotTypes returns the built-in robot types and the configured ones, by name
+func (c Config) robotTypes() map[string]*models.RobotType {
+	types := make(map[string]*models.RobotType)
+	for _, list := range [][]models.RobotType{models.BuiltinRobotTypes, c.RobotTypes} {
+		for i := range list {
+			robotType := list[i]
+			types[robotType.Name] = &robotType
+		}
+	}
+	return types
+}
+
+// FleetTypes returns the type of every robot in fleet order, all standard robots when no fleet is set
+func (c Config) FleetTypes() ([]*models.RobotType, error) {
+	types := c.robotTypes()
+	if len(c.Fleet) == 0 {
+		fleet := make([]*models.RobotType, c.Robots)
+		for i := range fleet {
+			fleet[i] = types[models.StandardRobot.Name]
+		}
+		return fleet, nil
+	}
+
+	var fleet []*models.RobotType
+	for _, entry := range c.Fleet {
+		robotType, ok := types[entry.Type]
+		if !ok {
+			return nil, fmt.Errorf("fleet uses unknown robot type %q", entry.Type)
+		}
+		if entry.Count < 0 {
+			return nil, fmt.Errorf("fleet count for %q must not be negative, got %d", entry.Type, entry.Count)
+		}
+		for i := 0; i < entry.Count; i++ {
+			fleet = append(fleet, robotType)
+		}
+	}
+	return fleet, nil
+}
+
+// DefaultConfig returns the standard 8x8x5 warehouse with three robots in real time
+func DefaultConfig() Config {
+	return Config{
+		Width:           8,
+		Height:          8,
+		Levels:          5,
+		Robots:          3,
+		ProductsFile:    "data/products.json",
+		ClockSpeed:      1,
+		OrdersPerHour:   0,
+		ProcessInterval: Duration(3 * time.Second),
+		Placement:       services.PlacementRandom,
+
+		ReturnPolicy:          services.ReturnSameStack,
+		HousekeepingInterval:  Duration(30 * time.Second),
+		LayoutMetricsInterval: Duration(time.Minute),
+
+		Compartments: 1,
+		EmptyBins:    16,
+
+		StockCheckInterval: Duration(time.Minute),
+	}
+}
+
+// LoadConfig reads a JSON config file on top of the defaults
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config JSON: %w", err)
+	}
+	return cfg, nil
+}
+
+// Validate checks that the config describes a runnable simulation
+func (c Config) Validate() error {
+	for _, robotType := range c.RobotTypes {
+		if err := robotType.Validate(); err != nil {
+			return err
+		}
+	}
+	fleet, err := c.FleetTypes()
+	if err != nil {
+		return err
+	}
+	if len(c.Fleet) > 0 {
+		c.Robots = len(fleet)
+	}
+	cells := 0
+	for _, robotType := range fleet {
+		cells += robotType.Footprint
+	}
+
+	switch {
+	case c.Width < 1 || c.Height < 2 || c.Levels < 1:
+		return fmt.Errorf("warehouse must be at least 1x2x1, got %dx%dx%d", c.Width, c.Height, c.Levels)
+	case c.Robots < 1:
+		return fmt.Errorf("at least one robot is required, got %d", c.Robots)
+	case cells > c.Width*c.Height:
+		return fmt.Errorf("%d robots do not fit on a %dx%d grid", c.Robots, c.Width, c.Height)
+	case c.ClockSpeed <= 0:
+		return fmt.Errorf("clock speed must be positive, got %v", c.ClockSpeed)
+	case c.OrdersPerHour < 0:
+		return fmt.Errorf("orders per hour must not be negative, got %v", c.OrdersPerHour)
+	case c.ProcessInterval <= 0:
+		return fmt.Errorf("process interval must be positive, got %v", c.ProcessInterval)
+	case c.Housekeeping && c.HousekeepingInterval <= 0:
+		return fmt.Errorf("housekeeping interval must be positive, got %v", c.HousekeepingInterval)
+	case !models.IsValidCompartmentCount(c.Compartments):
+		return fmt.Errorf("bins must have 1, 2, 4 or 8 compartments, got %d", c.Compartments)
+	case c.EmptyBins < 0:
+		return fmt.Errorf("empty bins must not be negative, got %d", c.EmptyBins)
+	case c.StockCheckInterval <= 0:
+		return fmt.Errorf("stock check interval must be positive, got %v", c.StockCheckInterval)
+	case c.LayoutMetricsInterval <= 0:
+		return fmt.Errorf("layout metrics interval must be positive, got %v", c.LayoutMetricsInterval)
+	}
+	return nil
+}
+
+// Simulation wires the warehouse, services and robots for one run
+type Simulation struct {
+	Config       Config
+	Clock        *models.ScaledClock
+	Warehouse    *models.SafeWarehouse
+	Products     *services.ProductService
+	Orders       *services.OrderService
+	Slotting     *services.SlottingService
+	Receiving    *services.ReceivingService
+	Inventory    *services.InventoryMonitor
+	Events       *services.EventLog
+	Analytics    *services.AnalyticsService
+	Traffic      *services.TrafficController
+	Robots       []*models.Robot // Active fleet, guarded by robotsMu once started
+	Workstations []models.Workstation
+	LayoutCost   services.LayoutCost // Estimated picking cost of the initial layout
+
+	// OnRobotUpdate receives every robot update after the services, e.g. for WebSocket broadcast
+	OnRobotUpdate func(models.RobotUpdate)
+	// OnEvent receives every event after it was logged, e.g. for WebSocket broadcast
+	OnEvent func(models.Event)
+	// OnOrderChanged receives orders as they are created or change status
+	OnOrderChanged func(models.Order)
+	// OnBinMoved receives grid cells a bin was lifted out of or stored in
+	OnBinMoved func(models.BinSlot)
+	// OnRobotRemoved receives the ID of a robot once it has left the fleet
+	OnRobotRemoved func(robotID int)
+
+	robotsMu    sync.Mutex      // Guards Robots, retired, nextRobotID and the run context against fleet changes
+	retired     []*models.Robot // Robots removed at runtime, kept for the report
+	nextRobotID int
+	ctx         context.Context // Set by Start, done once the simulation stops
+	cancel      context.CancelFunc
+}
+
+// New builds a simulation from config: loads products, places them and creates the fleet
+func New(cfg Config) (*Simulation, error) {
+	return build(cfg, nil, nil)
+}
+
+// build creates a simulation, using an explicit layout and robot start positions when given
+func build(cfg Config, layout []models.BinPlacement, robotStarts []models.Position) (*Simulation, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	clock := models.NewScaledClock(cfg.ClockSpeed)
+
+	// Create thread-safe warehouse
+	warehouse := models.NewSafeWarehouse(cfg.Width, cfg.Height, cfg.Levels)
+	warehouse.Compartments = cfg.Compartments
+
+	// Create and load products
+	productService := services.NewProductService()
+	err := productService.LoadProductsFromPath(cfg.ProductsFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading products: %w", err)
+	}
+
+	// Place products using an explicit layout or the configured strategy
+	var strategy services.PlacementStrategy = services.LayoutPlacement{Layout: layout}
+	if layout == nil {
+		strategy, err = services.ParsePlacementStrategy(cfg.Placement, cfg.LayoutFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Seed != 0 {
+		productService.SetSeed(cfg.Seed)
+	}
+
+	layoutCost, err := productService.PlaceProducts(warehouse, strategy)
+	if err != nil {
+		return nil, fmt.Errorf("error placing products: %w", err)
+	}
+	productService.AddEmptyBins(warehouse, cfg.EmptyBins)
+
+	slotting, err := services.NewSlottingService(productService, warehouse, clock, cfg.ReturnPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	sim := &Simulation{
+		Config:    cfg,
+		Clock:     clock,
+		Warehouse: warehouse,
+		Products:  productService,
+		Orders:    services.NewOrderService(productService, warehouse, slotting, clock),
+		Slotting:  slotting,
+		Analytics: services.NewAnalyticsService(clock),
+		Traffic:   services.NewTrafficController(cfg.Width, cfg.Height, clock),
+		// Example positions at delivery ports on the north edge
+		Workstations: []models.Workstation{
+			{ID: 1, X: 0, Y: 0, Type: models.WorkstationPicking, Status: "idle"},
+			{ID: 2, X: cfg.Width - 1, Y: 0, Type: models.WorkstationPicking, Status: "idle"},
+			{ID: 3, X: cfg.Width / 2, Y: 0, Type: models.WorkstationGoodsIn, Status: "idle"},
+		},
+		LayoutCost: layoutCost,
+	}
+	sim.Receiving = services.NewReceivingService(productService, slotting, sim.Workstations, clock)
+	sim.Events = services.NewEventLog(clock)
+	sim.Events.OnEvent = sim.handleEvent
+	sim.Inventory = services.NewInventoryMonitor(productService, slotting, sim.Receiving, sim.Events)
+	sim.Inventory.AutoReorder = cfg.AutoReorder
+	sim.Orders.OnOrderCompleted = sim.Analytics.RecordOrderCompleted
+	sim.Orders.HasInboundStock = sim.Receiving.HasInboundStock
+	sim.Orders.OnOrderChanged = sim.handleOrderChanged
+	sim.Slotting.OnBinMoved = sim.handleBinMoved
+
+	// Spread robots over the top of the grid, row by row, unless positions are given
+	fleet, err := cfg.FleetTypes()
+	if err != nil {
+		return nil, err
+	}
+	sim.Config.Robots = len(fleet)
+	starts := spreadRobots(fleet, cfg.Width, cfg.Height)
+	for i, robotType := range fleet {
+		start := starts[i]
+		if i < len(robotStarts) {
+			start = robotStarts[i]
+		}
+
+		sim.Robots = append(sim.Robots, sim.newRobot(i+1, start, robotType))
+	}
+	sim.nextRobotID = len(fleet) + 1
+
+	return sim, nil
+}
+
+// newRobot creates an idle robot wired to the simulation clock, traffic control and services
+func (s *Simulation) newRobot(id int, pos models.Position, robotType *models.RobotType) *models.Robot {
+	robot := &models.Robot{
+		RobotState: models.RobotState{
+			ID:     id,
+			X:      pos.X,
+			Y:      pos.Y,
+			Z:      pos.Z,
+			Status: "idle",
+			Type:   robotType,
+		},
+		Clock:   s.Clock,
+		Traffic: s.Traffic,
+	}
+	robot.BroadcastUpdate = s.handleRobotUpdate
+	return robot
+}
+
+// spreadRobots places robots row by row on cells their bodies fit without overlapping
+func spreadRobots(fleet []*models.RobotType, width, height int) []models.Position {
+	taken := make(map[models.Position]bool)
+	starts := make([]models.Position, len(fleet))
+	next := 0
+	for i, robotType := range fleet {
+		for ; next < width*height; next++ {
+			pos := models.Position{X: next % width, Y: next / width}
+			if !robotType.Fits(pos.X, width) {
+				continue
+			}
+			free := true
+			for _, cell := range robotType.Cells(pos) {
+				free = free && !taken[cell]
+			}
+			if !free {
+				continue
+			}
+			for _, cell := range robotType.Cells(pos) {
+				taken[cell] = true
+			}
+			starts[i] = pos
+			break
+		}
+	}
+	return starts
+}
+
+// handleRobotUpdate fans a robot update out to the services and listeners
+func (s *Simulation) handleRobotUpdate(update models.RobotUpdate) {
+	// Slotting first so a lifted bin is tracked before the order takes stock from it
+	s.Slotting.HandleRobotUpdate(update)
+	s.Orders.HandleRobotUpdate(update)
+	s.Receiving.HandleRobotUpdate(update)
+	s.Analytics.RecordRobotUpdate(update)
+	if s.OnRobotUpdate != nil {
+		s.OnRobotUpdate(update)
+	}
+}
+
+// handleEvent forwards a logged event to the listener
+func (s *Simulation) handleEvent(event models.Event) {
+	if s.OnEvent != nil {
+		s.OnEvent(event)
+	}
+}
+
+// handleOrderChanged forwards a created or updated order to the listener
+func (s *Simulation) handleOrderChanged(order models.Order) {
+	if s.OnOrderChanged != nil {
+		s.OnOrderChanged(order)
+	}
+}
+
+// handleBinMoved forwards a changed grid cell to the listener
+func (s *Simulation) handleBinMoved(slot models.BinSlot) {
+	if s.OnBinMoved != nil {
+		s.OnBinMoved(slot)
+	}
+}
+
+// Start launches robot goroutines, the order processor and the demand generator.
+// They all stop when ctx ends or Stop is called.
+func (s *Simulation) Start(ctx context.Context) {
+	s.robotsMu.Lock()
+	defer s.robotsMu.Unlock()
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	for _, robot := range s.Robots {
+		s.Analytics.RegisterRobot(robot.ID)
+		s.Traffic.Register(robot.ID, models.Position{X: robot.X, Y: robot.Y}, robot.RobotType().Footprint)
+		robot.StartRobot(s.ctx, s.Warehouse)
+	}
+
+	go s.runOrderProcessor(s.ctx)
+	if s.Config.OrdersPerHour > 0 {
+		go s.runOrderGenerator(s.ctx)
+	}
+	if s.Config.Housekeeping {
+		go s.runHousekeeping(s.ctx)
+	}
+	go s.runLayoutMetrics(s.ctx)
+	go s.runInventoryMonitor(s.ctx)
+}
+
+// Stop ends order intake and dispatch, robots stop after their current command
+func (s *Simulation) Stop() {
+	s.robotsMu.Lock()
+	defer s.robotsMu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// Shutdown stops the simulation and waits for every robot to finish or abandon its
+// current command. It gives up when ctx ends first.
+func (s *Simulation) Shutdown(ctx context.Context) error {
+	s.Stop()
+	for _, robot := range s.allRobots() {
+		select {
+		case <-robot.Stopped():
+		case <-ctx.Done():
+			return fmt.Errorf("robot %d still busy: %w", robot.ID, ctx.Err())
+		}
+	}
+	return nil
+}
+
+// running reports whether the simulation has started and not stopped, caller must hold robotsMu
+func (s *Simulation) running() bool {
+	return s.ctx != nil && s.ctx.Err() == nil
+}
+
+// ActiveRobots returns the robots currently in the fleet
+func (s *Simulation) ActiveRobots() []*models.Robot {
+	s.robotsMu.Lock()
+	defer s.robotsMu.Unlock()
+	return append([]*models.Robot(nil), s.Robots...)
+}
+
+// AddRobot starts a robot of the named type, the standard robot when empty, on the free cell nearest pos
+func (s *Simulation) AddRobot(typeName string, pos models.Position) (*models.Robot, error) {
+	if typeName == "" {
+		typeName = models.StandardRobot.Name
+	}
+	robotType, ok := s.Config.robotTypes()[typeName]
+	if !ok {
+		return nil, fmt.Errorf("unknown robot type %q", typeName)
+	}
+
+	s.robotsMu.Lock()
+	defer s.robotsMu.Unlock()
+	if !s.running() {
+		return nil, fmt.Errorf("simulation is not running")
+	}
+
+	cell, ok := s.Traffic.RegisterNear(s.nextRobotID, pos, robotType.Footprint)
+	if !ok {
+		return nil, models.ErrNoFreeCell
+	}
+	robot := s.newRobot(s.nextRobotID, cell, robotType)
+	s.nextRobotID++
+
+	s.Robots = append(s.Robots, robot)
+	s.Analytics.RegisterRobot(robot.ID)
+	robot.StartRobot(s.ctx, s.Warehouse)
+	// Listeners learn about the robot before it reports its first command
+	if s.OnRobotUpdate != nil {
+		s.OnRobotUpdate(models.RobotUpdate{RobotID: robot.ID, X: cell.X, Y: cell.Y, Z: cell.Z, Status: "idle"})
+	}
+	fmt.Printf("Robot %d (%s) joined the fleet at (%d, %d)\n", robot.ID, robotType.Name, cell.X, cell.Y)
+	return robot, nil
+}
+
+// RemoveRobot takes a robot out of dispatch. It finishes the order it is working on, returning
+// the bin to the grid, then stops and releases its cells.
+func (s *Simulation) RemoveRobot(id int) (*models.Robot, error) {
+	s.robotsMu.Lock()
+	defer s.robotsMu.Unlock()
+	if !s.running() {
+		return nil, fmt.Errorf("simulation is not running")
+	}
+
+	for i, robot := range s.Robots {
+		if robot.ID != id {
+			continue
+		}
+		// Dispatch holds robotsMu, so no new work reaches the robot after this
+		s.Robots = append(s.Robots[:i:i], s.Robots[i+1:]...)
+		s.retired = append(s.retired, robot)
+		stopped := robot.Retire()
+		go func() {
+			<-stopped
+			s.Traffic.Unregister(robot.ID)
+			s.Analytics.UnregisterRobot(robot.ID)
+			if s.OnRobotRemoved != nil {
+				s.OnRobotRemoved(robot.ID)
+			}
+		}()
+		fmt.Printf("Robot %d draining before leaving the fleet\n", robot.ID)
+		return robot, nil
+	}
+	return nil, models.ErrUnknownRobot
+}
+
+// RunFor starts the simulation, lets it run for d of simulated time and shuts it down.
+// It stops early when ctx ends and returns the simulated time actually run.
+func (s *Simulation) RunFor(ctx context.Context, d time.Duration) time.Duration {
+	start := s.Clock.Now()
+	s.Start(ctx)
+	select {
+	case <-s.Clock.After(d):
+	case <-ctx.Done():
+	}
+	ran := s.Clock.Now().Sub(start)
+	// Robots give up on travel once stopped, so they all come to rest
+	s.Shutdown(context.Background())
+	return ran
+}
+
+// runOrderProcessor processes pending orders and receipts periodically
+func (s *Simulation) runOrderProcessor(ctx context.Context) {
+	ticker := s.Clock.NewTicker(time.Duration(s.Config.ProcessInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Customer orders get robots first, restocking uses what is left
+			s.robotsMu.Lock()
+			s.Orders.ProcessPendingOrders(s.Robots)
+			s.Receiving.ProcessPendingReceipts(s.Robots)
+			s.clearBlockingRobots()
+			s.robotsMu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runOrderGenerator creates random orders as a Poisson process at OrdersPerHour
+func (s *Simulation) runOrderGenerator(ctx context.Context) {
+	meanGap := time.Duration(float64(time.Hour) / s.Config.OrdersPerHour)
+
+	for {
+		gap := time.Duration(rand.ExpFloat64() * float64(meanGap))
+		select {
+		case <-s.Clock.After(gap):
+			s.Orders.GenerateRandomOrder()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runHousekeeping gives one idle robot a bin to move up whenever no orders are waiting
+func (s *Simulation) runHousekeeping(ctx context.Context) {
+	ticker := s.Clock.NewTicker(time.Duration(s.Config.HousekeepingInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Orders always take priority over tidying up
+			if s.Orders.PendingCount() > 0 {
+				continue
+			}
+			s.robotsMu.Lock()
+			robot := s.idleRobot()
+			for _, cmd := range s.Slotting.Housekeep(robot) {
+				robot.Commands <- cmd
+			}
+			s.robotsMu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runLayoutMetrics samples the layout cost so convergence can be followed over time
+func (s *Simulation) runLayoutMetrics(ctx context.Context) {
+	s.Slotting.RecordLayoutSample()
+
+	ticker := s.Clock.NewTicker(time.Duration(s.Config.LayoutMetricsInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Slotting.RecordLayoutSample()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runInventoryMonitor checks stock against reorder points periodically
+func (s *Simulation) runInventoryMonitor(ctx context.Context) {
+	s.Inventory.Check()
+
+	ticker := s.Clock.NewTicker(time.Duration(s.Config.StockCheckInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Inventory.Check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// clearBlockingRobots moves idle robots out of the way of robots waiting for their cell, caller must hold robotsMu
+func (s *Simulation) clearBlockingRobots() {
+	for _, id := range s.Traffic.Blockers() {
+		for _, robot := range s.Robots {
+			if robot.ID != id || !robot.IsIdle() {
+				continue
+			}
+			state := robot.Snapshot()
+			cell, ok := s.Traffic.FreeCellNear(robot.ID, models.Position{X: state.X, Y: state.Y})
+			if !ok {
+				continue
+			}
+			fmt.Printf("Traffic: idle Robot %d clears the way to (%d, %d)\n", robot.ID, cell.X, cell.Y)
+			robot.Commands <- models.RobotCommand{Type: "move", X: cell.X, Y: cell.Y, Priority: -1}
+		}
+	}
+}
+
+// idleRobot returns the first robot with nothing to do, or nil, caller must hold robotsMu
+func (s *Simulation) idleRobot() *models.Robot {
+	for _, robot := range s.Robots {
+		if robot.IsIdle() {
+			return robot
+		}
+	}
+	return nil
+}
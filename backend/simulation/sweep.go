@@ -0,0 +1,71 @@
+package simulation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Sweep varies one config parameter over an inclusive range, e.g. robots=3..20
+type Sweep struct {
+	Param string
+	From  float64
+	To    float64
+	Step  float64
+}
+
+// sweepParams maps sweepable parameter names to config setters
+var sweepParams = map[string]func(*Config, float64){
+	"robots":          func(c *Config, v float64) { c.Robots = int(v) },
+	"orders_per_hour": func(c *Config, v float64) { c.OrdersPerHour = v },
+}
+
+// ParseSweep parses "param=from..to" with an optional ":step" suffix
+func ParseSweep(spec string) (Sweep, error) {
+	param, rangeSpec, ok := strings.Cut(spec, "=")
+	if !ok {
+		return Sweep{}, fmt.Errorf("invalid sweep %q: expected param=from..to[:step]", spec)
+	}
+	if _, ok := sweepParams[param]; !ok {
+		return Sweep{}, fmt.Errorf("unknown sweep parameter %q (supported: robots, orders_per_hour)", param)
+	}
+
+	sweep := Sweep{Param: param, Step: 1}
+	if rangeSpec, stepSpec, hasStep := strings.Cut(rangeSpec, ":"); hasStep {
+		step, err := strconv.ParseFloat(stepSpec, 64)
+		if err != nil || step <= 0 {
+			return Sweep{}, fmt.Errorf("invalid sweep step %q", stepSpec)
+		}
+		sweep.Step = step
+		spec = rangeSpec
+	} else {
+		spec = rangeSpec
+	}
+
+	fromSpec, toSpec, ok := strings.Cut(spec, "..")
+	if !ok {
+		return Sweep{}, fmt.Errorf("invalid sweep range %q: expected from..to", spec)
+	}
+	from, err := strconv.ParseFloat(fromSpec, 64)
+	if err != nil {
+		return Sweep{}, fmt.Errorf("invalid sweep start %q", fromSpec)
+	}
+	to, err := strconv.ParseFloat(toSpec, 64)
+	if err != nil || to < from {
+		return Sweep{}, fmt.Errorf("invalid sweep end %q", toSpec)
+	}
+	sweep.From, sweep.To = from, to
+
+	return sweep, nil
+}
+
+// Configs returns one config per sweep value, based on cfg
+func (s Sweep) Configs(cfg Config) []Config {
+	var configs []Config
+	for v := s.From; v <= s.To+1e-9; v += s.Step {
+		next := cfg
+		sweepParams[s.Param](&next, v)
+		configs = append(configs, next)
+	}
+	return configs
+}
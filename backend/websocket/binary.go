@@ -0,0 +1,126 @@
+package websocket
+
+import (
+	"autostore-sim/backend/models"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Subprotocols a client asks for in Sec-WebSocket-Protocol, JSON when it asks for neither
+const (
+	SubprotocolJSON     = "autostore.json.v1"
+	SubprotocolProtobuf = "autostore.protobuf.v1"
+)
+
+// binaryFrameInterval is the tick binary clients get their messages at, one frame per tick
+const binaryFrameInterval = 100 * time.Millisecond
+
+// Field numbers of autostore.proto
+const (
+	frameMessages protowire.Number = 1
+
+	messageSeq          protowire.Number = 1
+	messageRobotUpdate  protowire.Number = 2
+	messageRobotRemoved protowire.Number = 3
+	messageCellUpdate   protowire.Number = 4
+	messageJSON         protowire.Number = 15
+
+	robotID        protowire.Number = 1
+	robotX         protowire.Number = 2
+	robotY         protowire.Number = 3
+	robotZ         protowire.Number = 4
+	robotStatus    protowire.Number = 5
+	robotOrderID   protowire.Number = 6
+	robotReceiptID protowire.Number = 7
+	robotCommand   protowire.Number = 8
+
+	cellX     protowire.Number = 1
+	cellY     protowire.Number = 2
+	cellZ     protowire.Number = 3
+	cellBinID protowire.Number = 4
+)
+
+// Bodies are a Message's oneof field, encoded once per diff. The write pump puts the
+// client's seq in front, field order does not matter in protobuf.
+
+// binaryRobotUpdate encodes the body of a robot_update message
+func binaryRobotUpdate(update models.RobotUpdate) []byte {
+	var robot []byte
+	robot = appendVarint(robot, robotID, uint64(update.RobotID))
+	robot = appendSint(robot, robotX, update.X)
+	robot = appendSint(robot, robotY, update.Y)
+	robot = appendSint(robot, robotZ, update.Z)
+	robot = appendString(robot, robotStatus, update.Status)
+	robot = appendVarint(robot, robotOrderID, uint64(update.OrderID))
+	robot = appendVarint(robot, robotReceiptID, uint64(update.ReceiptID))
+	robot = appendString(robot, robotCommand, update.Command)
+	return appendBytes(nil, messageRobotUpdate, robot)
+}
+
+// binaryRobotRemoved encodes the body of a robot_removed message
+func binaryRobotRemoved(id int) []byte {
+	return appendVarint(nil, messageRobotRemoved, uint64(id))
+}
+
+// binaryCellUpdate encodes the body of a cell_update message
+func binaryCellUpdate(slot models.BinSlot) []byte {
+	var cell []byte
+	cell = appendSint(cell, cellX, slot.X)
+	cell = appendSint(cell, cellY, slot.Y)
+	cell = appendSint(cell, cellZ, slot.Z)
+	cell = appendString(cell, cellBinID, slot.BinID)
+	return appendBytes(nil, messageCellUpdate, cell)
+}
+
+// binaryJSON wraps a JSON message, e.g. a snapshot or response, as a Message body
+func binaryJSON(data []byte) []byte {
+	return appendBytes(nil, messageJSON, data)
+}
+
+// appendFrameMessage adds a Message with the given seq and body to a Frame, seq 0 is left out
+func appendFrameMessage(frame []byte, seq uint64, body []byte) []byte {
+	size := len(body)
+	if seq != 0 {
+		size += protowire.SizeTag(messageSeq) + protowire.SizeVarint(seq)
+	}
+	frame = protowire.AppendTag(frame, frameMessages, protowire.BytesType)
+	frame = protowire.AppendVarint(frame, uint64(size))
+	if seq != 0 {
+		frame = appendVarint(frame, messageSeq, seq)
+	}
+	return append(frame, body...)
+}
+
+// appendVarint adds an unsigned field, zero is the default and left out
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// appendSint adds a zigzag-encoded signed field, zero is left out
+func appendSint(b []byte, num protowire.Number, v int) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, protowire.EncodeZigZag(int64(v)))
+}
+
+// appendString adds a string field, empty is left out
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendBytes adds an embedded message or bytes field, always present so an empty message still selects the oneof
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
@@ -0,0 +1,147 @@
+package websocket
+
+import (
+	"autostore-sim/backend/models"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestBinaryFrame(t *testing.T) {
+	items := []queued{
+		{kind: queuedSnapshot, data: []byte(`{"type":"snapshot"}`)},
+		{kind: queuedDiff, key: "robot:7", data: []byte(`{}`), binary: binaryRobotUpdate(models.RobotUpdate{
+			RobotID: 7, X: -2, Y: 3, Status: "moving", OrderID: 12, Command: "pick",
+		})},
+		{kind: queuedDiff, key: "robot:8", data: []byte(`{}`), binary: binaryRobotRemoved(8)},
+		{kind: queuedDiff, key: "cell:1:2:0", data: []byte(`{}`), binary: binaryCellUpdate(models.BinSlot{X: 1, Y: 2, BinID: "BIN-4"})},
+		{kind: queuedDiff, data: []byte(`{"type":"order_update"}`)},
+		{kind: queuedResponse, data: []byte(`{"type":"response"}`)},
+	}
+	seq := uint64(41)
+	frame := binaryFrame(items, &seq)
+	if seq != 45 {
+		t.Errorf("seq %d after four diffs from 41, want 45", seq)
+	}
+
+	var messages [][]field
+	for _, f := range decode(t, frame) {
+		if f.num != frameMessages || f.typ != protowire.BytesType {
+			t.Fatalf("frame field %d of wire type %d, want messages (1) of bytes", f.num, f.typ)
+		}
+		messages = append(messages, decode(t, f.bytes))
+	}
+	if len(messages) != len(items) {
+		t.Fatalf("%d messages, want %d", len(messages), len(items))
+	}
+
+	// The snapshot carries the last diff before it, diffs count on, responses have no seq
+	wantSeq := []uint64{41, 42, 43, 44, 45, 0}
+	wantBody := []protowire.Number{messageJSON, messageRobotUpdate, messageRobotRemoved, messageCellUpdate, messageJSON, messageJSON}
+	for i, message := range messages {
+		var gotSeq uint64
+		body := message
+		if message[0].num == messageSeq {
+			if message[0].typ != protowire.VarintType {
+				t.Errorf("message %d: seq of wire type %d", i, message[0].typ)
+			}
+			gotSeq, body = message[0].varint, message[1:]
+		}
+		if gotSeq != wantSeq[i] {
+			t.Errorf("message %d: seq %d, want %d", i, gotSeq, wantSeq[i])
+		}
+		if len(body) != 1 || body[0].num != wantBody[i] {
+			t.Errorf("message %d: body %+v, want field %d only", i, body, wantBody[i])
+		}
+	}
+
+	if got := string(messages[0][1].bytes); got != `{"type":"snapshot"}` {
+		t.Errorf("snapshot JSON %s", got)
+	}
+	if got := string(messages[4][1].bytes); got != `{"type":"order_update"}` {
+		t.Errorf("fallback JSON %s", got)
+	}
+
+	robot := byNumber(decode(t, messages[1][1].bytes))
+	if robot[robotID].varint != 7 || protowire.DecodeZigZag(robot[robotX].varint) != -2 || protowire.DecodeZigZag(robot[robotY].varint) != 3 {
+		t.Errorf("robot position %+v", robot)
+	}
+	if string(robot[robotStatus].bytes) != "moving" || robot[robotOrderID].varint != 12 || string(robot[robotCommand].bytes) != "pick" {
+		t.Errorf("robot fields %+v", robot)
+	}
+	for _, unset := range []protowire.Number{robotZ, robotReceiptID} {
+		if _, ok := robot[unset]; ok {
+			t.Errorf("zero robot field %d was encoded", unset)
+		}
+	}
+	for num, typ := range map[protowire.Number]protowire.Type{robotID: protowire.VarintType, robotX: protowire.VarintType, robotStatus: protowire.BytesType} {
+		if robot[num].typ != typ {
+			t.Errorf("robot field %d of wire type %d, want %d", num, robot[num].typ, typ)
+		}
+	}
+
+	if removed := messages[2][1]; removed.typ != protowire.VarintType || removed.varint != 8 {
+		t.Errorf("robot_removed %+v", removed)
+	}
+
+	cell := byNumber(decode(t, messages[3][1].bytes))
+	if protowire.DecodeZigZag(cell[cellX].varint) != 1 || protowire.DecodeZigZag(cell[cellY].varint) != 2 || string(cell[cellBinID].bytes) != "BIN-4" {
+		t.Errorf("cell fields %+v", cell)
+	}
+	if _, ok := cell[cellZ]; ok {
+		t.Error("zero cell z was encoded")
+	}
+}
+
+// An emptied cell still selects cell_update, the message is present though all its fields are zero
+func TestBinaryEmptyCell(t *testing.T) {
+	body := decode(t, binaryCellUpdate(models.BinSlot{}))
+	if len(body) != 1 || body[0].num != messageCellUpdate || len(body[0].bytes) != 0 {
+		t.Errorf("empty cell body %+v", body)
+	}
+}
+
+// field is a decoded protobuf field, varint or bytes by its wire type
+type field struct {
+	num    protowire.Number
+	typ    protowire.Type
+	varint uint64
+	bytes  []byte
+}
+
+// decode reads the fields of an encoded message, failing on anything but varint and bytes
+func decode(t *testing.T, b []byte) []field {
+	t.Helper()
+	var fields []field
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("bad tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("field %d has unexpected wire type %d", num, typ)
+		}
+		if n < 0 {
+			t.Fatalf("field %d: %v", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// byNumber indexes fields by number, the encoder writes each at most once
+func byNumber(fields []field) map[protowire.Number]field {
+	m := make(map[protowire.Number]field, len(fields))
+	for _, f := range fields {
+		m[f.num] = f
+	}
+	return m
+}
@@ -1,6 +1,8 @@
 package websocket
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
@@ -18,33 +20,48 @@ const (
 	// Send pings to peer with this period (must be less than pongWait)
 	pingPeriod = (pongWait * 9) / 10
 
-	// Maximum message size allowed from peer
-	maxMessageSize = 512
+	// Maximum message size allowed from peer, enough for a command with its parameters
+	maxMessageSize = 4096
 )
 
+// upgrader is copied per connection with the hub's origin check
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	// Allow all origins for development (restrict in production!)
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+	Subprotocols:    []string{SubprotocolProtobuf, SubprotocolJSON},
 }
 
 // Client represents a single websocket connection
 type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan []byte
+	hub   *Hub
+	conn  *websocket.Conn
+	id    uint64
+	queue *clientQueue
+
+	// Negotiated the protobuf subprotocol, messages go out as one binary Frame per tick
+	binary bool
+
+	// Runs the client's commands with the rights of whoever connected, they are refused when nil
+	commands CommandFunc
+
+	// Owned by the hub's Run loop: topics the client receives, whether it waits for a snapshot,
+	// and which sync the awaited snapshot belongs to, older ones are discarded
+	subs    subscriptions
+	syncing bool
+	syncGen uint64
 }
 
 // readPump pumps messages from the websocket connection to the hub
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		c.conn.Close()
 	}()
 
+	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -60,43 +77,88 @@ func (c *Client) readPump() {
 			break
 		}
 
-		// For now, we just log received messages (clients mostly just receive updates)
-		log.Printf("Received from client: %s", message)
+		c.handleMessage(message)
 	}
 }
 
-// writePump pumps messages from the hub to the websocket connection
+// handleMessage acts on a client message: subscriptions and resyncs go to the hub,
+// commands run here and their response is queued through the hub
+func (c *Client) handleMessage(data []byte) {
+	var message ClientMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		c.hub.reply(c, Response{Error: "invalid message: " + err.Error()})
+		return
+	}
+
+	switch message.Type {
+	case "resync":
+		// The client wants to start over, e.g. it could not apply a diff, it gets a fresh snapshot
+		select {
+		case c.hub.resync <- c:
+		case <-c.hub.done:
+		}
+
+	case "subscribe", "unsubscribe":
+		topics := make(map[string]topic, len(message.Topics))
+		for _, name := range message.Topics {
+			t, err := parseTopic(name)
+			if err != nil {
+				c.hub.reply(c, Response{ID: message.ID, Error: err.Error()})
+				return
+			}
+			topics[name] = t
+		}
+		select {
+		case c.hub.subscribe <- subscriptionChange{client: c, id: message.ID, add: message.Type == "subscribe", topics: topics}:
+		case <-c.hub.done:
+		}
+
+	case "command":
+		if c.commands == nil {
+			c.hub.reply(c, Response{ID: message.ID, Error: "commands are not supported"})
+			return
+		}
+		result, err := c.commands(message.Command, message.Params)
+		if err != nil {
+			c.hub.reply(c, Response{ID: message.ID, Error: err.Error()})
+			return
+		}
+		c.hub.reply(c, Response{ID: message.ID, OK: true, Result: result})
+
+	default:
+		c.hub.reply(c, Response{ID: message.ID, Error: fmt.Sprintf("unknown message type %q", message.Type)})
+	}
+}
+
+// writePump pumps messages from the hub to the websocket connection. It numbers diffs as they
+// go out, after coalescing and dropping, so seq orders what the client got and never skips; it
+// is no sign of lost diffs. JSON clients are written to as soon as there is something queued,
+// binary clients once per frame tick.
 func (c *Client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
+	notify := c.queue.notify
+	var frames <-chan time.Time
+	if c.binary {
+		frameTicker := time.NewTicker(binaryFrameInterval)
+		defer frameTicker.Stop()
+		frames, notify = frameTicker.C, nil // A closed queue is noticed on the next tick
+	}
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		c.hub.pumps.Done()
 	}()
 
+	var seq uint64 // Last diff written
 	for {
 		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				// The hub closed the channel
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
+		case <-notify:
+			if !c.flush(&seq) {
 				return
 			}
-			w.Write(message)
-
-			// Add queued messages to the current websocket message
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.send)
-			}
 
-			if err := w.Close(); err != nil {
+		case <-frames:
+			if !c.flush(&seq) {
 				return
 			}
 
@@ -109,23 +171,107 @@ func (c *Client) writePump() {
 	}
 }
 
-// ServeWs handles websocket requests from clients
-func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+// flush writes everything queued in one websocket message, or the close frame once the queue
+// has closed. It returns false when the pump should stop.
+func (c *Client) flush(seq *uint64) bool {
+	items, closing, open := c.queue.take()
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if !open {
+		if closing == nil {
+			closing = websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		}
+		c.conn.WriteMessage(websocket.CloseMessage, closing)
+		return false
+	}
+	if len(items) == 0 {
+		return true
+	}
+
+	if c.binary {
+		return c.conn.WriteMessage(websocket.BinaryMessage, binaryFrame(items, seq)) == nil
+	}
+
+	// Queued messages go out in one websocket message, one per line
+	w, err := c.conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return false
+	}
+	for i, item := range items {
+		if i > 0 {
+			w.Write([]byte{'\n'})
+		}
+		switch item.kind {
+		case queuedDiff:
+			*seq++
+			w.Write(withSeq(item.data, *seq))
+		case queuedSnapshot:
+			w.Write(withSeq(item.data, *seq))
+		default:
+			w.Write(item.data)
+		}
+	}
+	return w.Close() == nil
+}
+
+// binaryFrame encodes queued messages as a protobuf Frame, numbering diffs like the JSON protocol.
+// Messages without a compact form go in as JSON.
+func binaryFrame(items []queued, seq *uint64) []byte {
+	var frame []byte
+	for _, item := range items {
+		body := item.binary
+		if body == nil {
+			body = binaryJSON(item.data)
+		}
+		switch item.kind {
+		case queuedDiff:
+			*seq++
+			frame = appendFrameMessage(frame, *seq, body)
+		case queuedSnapshot:
+			frame = appendFrameMessage(frame, *seq, body)
+		default:
+			frame = appendFrameMessage(frame, 0, body)
+		}
+	}
+	return frame
+}
+
+// encoding names the client's message encoding for stats
+func (c *Client) encoding() string {
+	if c.binary {
+		return "protobuf"
+	}
+	return "json"
+}
+
+// ServeWs handles websocket requests from clients, commands runs the commands the client sends
+func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request, commands CommandFunc) {
+	u := upgrader
+	u.CheckOrigin = hub.CheckOrigin
+	conn, err := u.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
 		return
 	}
 
 	client := &Client{
-		hub:  hub,
-		conn: conn,
-		send: make(chan []byte, 256),
+		hub:      hub,
+		conn:     conn,
+		id:       hub.nextClientID.Add(1),
+		queue:    newClientQueue(),
+		subs:     newSubscriptions(),
+		binary:   conn.Subprotocol() == SubprotocolProtobuf,
+		commands: commands,
 	}
 
-	client.hub.register <- client
+	// The hub starts the write pump once it has the client
+	select {
+	case client.hub.register <- client:
+	case <-client.hub.done:
+		conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+		conn.Close()
+		return
+	}
 
-	// Start read and write pumps in separate goroutines
-	go client.writePump()
 	go client.readPump()
 }
@@ -2,18 +2,39 @@ package websocket
 
 import (
 	"autostore-sim/backend/models"
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"net/http"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
 )
 
-// Hub maintains the set of active clients and broadcasts messages to them
+// WarehouseState is the full state a client starts from, sent on connect and on resync. It goes
+// out with the seq of the last diff it includes, the client's diffs continue at seq+1.
+type WarehouseState struct {
+	Type         string               `json:"type"` // Always "snapshot"
+	Robots       []*models.Robot      `json:"robots"`
+	Orders       []models.Order       `json:"orders"` // Active orders, completed and failed ones are left out
+	Workstations []models.Workstation `json:"workstations"`
+	Grid         models.GridOccupancy `json:"grid"`
+}
+
+// Hub maintains the set of active clients and broadcasts messages to them.
+// Producers never block: diffs collect in an inbox, and each client has its own bounded queue.
 type Hub struct {
 	// Registered clients
 	clients map[*Client]bool
 
-	// Inbound messages from clients
-	broadcast chan []byte
+	// Diffs waiting for fan-out, appended by producers under inboxMu
+	inboxMu    sync.Mutex
+	inbox      []outbound
+	inboxReady chan struct{}
 
 	// Register requests from clients
 	register chan *Client
@@ -21,83 +42,415 @@ type Hub struct {
 	// Unregister requests from clients
 	unregister chan *Client
 
-	// Mutex for thread-safe access
+	// Clients that missed a diff and asked for a fresh snapshot
+	resync chan *Client
+
+	// Snapshots built for syncing clients
+	synced chan clientSnapshot
+
+	// Subscribe and unsubscribe requests from clients
+	subscribe chan subscriptionChange
+
+	// Command responses for clients
+	replies chan reply
+
+	// Guards clients and departed for Stats
 	mu sync.RWMutex
+
+	// Counters of clients that have left, so Stats covers every client
+	departed HubStats
+
+	// Closed once the hub has shut down, sends to the hub are dropped after that
+	done chan struct{}
+
+	// Write pumps still running, waited for on shutdown so close frames go out. Only Run adds
+	// to it, the same goroutine that waits.
+	pumps sync.WaitGroup
+
+	// Last client ID handed out
+	nextClientID atomic.Uint64
+
+	// Connected binary clients, diffs get a protobuf body only while there are any
+	binaryClients atomic.Int64
+
+	// Server-Sent Event streams and the replay buffer they resume from
+	streams streamState
+
+	// Snapshot returns the current warehouse state, e.g. handlers.Server.WarehouseSnapshot.
+	// Clients get no snapshot when nil.
+	Snapshot func() WarehouseState `json:"-"`
+
+	// CheckOrigin decides which browser origins may connect, e.g. handlers' origin allowlist.
+	// When nil only pages served by this host may.
+	CheckOrigin func(r *http.Request) bool `json:"-"`
+}
+
+// outbound is an encoded diff with what clients filter and coalesce it on
+type outbound struct {
+	topic   string           // TopicRobots etc.
+	key     string           // Entity the diff is about, a newer diff replaces a queued one with the same key
+	robotID int              // Robot the diff is about, 0 when none
+	at      *models.Position // Grid cell the diff is about, nil when none
+	data    []byte           // Encoded message without seq
+	binary  []byte           // Protobuf Message body, nil when there is no compact form or no binary client
+}
+
+// clientSnapshot is an encoded snapshot ready to go out to a syncing client
+type clientSnapshot struct {
+	client *Client
+	gen    uint64
+	data   []byte
+}
+
+// ClientStats counts what happened to one client's messages
+type ClientStats struct {
+	ID        uint64 `json:"id"`
+	Addr      string `json:"addr"`
+	Encoding  string `json:"encoding"` // json or protobuf
+	Sent      uint64 `json:"sent"`
+	Coalesced uint64 `json:"coalesced"` // Diffs replaced by a newer one about the same entity before going out
+	Dropped   uint64 `json:"dropped"`   // Diffs discarded because the client fell behind, a snapshot replaced them
+	Snapshots uint64 `json:"snapshots"` // On connect, on request and after falling behind
+	Queued    int    `json:"queued"`
 }
 
+// HubStats sums the client counters, including clients that have left
+type HubStats struct {
+	Clients      int           `json:"clients"`
+	Sent         uint64        `json:"sent"`
+	Coalesced    uint64        `json:"coalesced"`
+	Dropped      uint64        `json:"dropped"`
+	Snapshots    uint64        `json:"snapshots"`
+	Disconnected uint64        `json:"disconnected"` // Clients closed for falling behind while syncing
+	Streams      int           `json:"streams"`      // Open Server-Sent Event streams
+	StreamsShed  uint64        `json:"streams_shed"` // Streams closed for falling behind, their clients resume
+	PerClient    []ClientStats `json:"per_client"`
+}
+
+// closeTooSlow is the close frame for a client that cannot keep up even with coalescing
+var closeTooSlow = websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow")
+
 // NewHub creates a new Hub
 func NewHub() *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte, 256),
+		inboxReady: make(chan struct{}, 1),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		resync:     make(chan *Client),
+		synced:     make(chan clientSnapshot),
+		subscribe:  make(chan subscriptionChange),
+		replies:    make(chan reply),
+		done:       make(chan struct{}),
+		streams:    newStreamState(),
 	}
 }
 
-// Run starts the hub's main loop
-func (h *Hub) Run() {
+// Run starts the hub's main loop, which never blocks on a client. When ctx ends every client
+// gets a going-away close frame and Run returns once they have been written.
+func (h *Hub) Run(ctx context.Context) {
 	for {
 		select {
+		case <-ctx.Done():
+			h.shutdown()
+			return
+
 		case client := <-h.register:
 			h.mu.Lock()
 			h.clients[client] = true
+			count := len(h.clients)
 			h.mu.Unlock()
-			log.Printf("Client registered, total clients: %d", len(h.clients))
+			if client.binary {
+				h.binaryClients.Add(1)
+			}
+			// Counted here, so no pump starts while shutdown waits for them
+			h.pumps.Add(1)
+			go client.writePump()
+			log.Printf("Client registered, total clients: %d", count)
+			h.startSync(client)
 
 		case client := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
+			if h.clients[client] {
+				count := h.remove(client, nil)
+				log.Printf("Client unregistered, total clients: %d", count)
 			}
-			h.mu.Unlock()
-			log.Printf("Client unregistered, total clients: %d", len(h.clients))
-
-		case message := <-h.broadcast:
-			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
+
+		case client := <-h.resync:
+			if h.clients[client] && !client.syncing {
+				h.startSync(client)
+			}
+
+		case snapshot := <-h.synced:
+			client := snapshot.client
+			if !h.clients[client] || !client.syncing || snapshot.gen != client.syncGen {
+				continue // Left, or a newer sync started while the snapshot was built
+			}
+			client.syncing = false
+			client.queue.release(snapshot.data)
+
+		case change := <-h.subscribe:
+			if !h.clients[change.client] {
+				continue
+			}
+			for name, t := range change.topics {
+				if change.add {
+					change.client.subs[name] = t
+				} else {
+					delete(change.client.subs, name)
+				}
+			}
+			h.enqueue(change.client, queued{kind: queuedResponse, data: encodeResponse(Response{
+				ID: change.id, OK: true, Result: map[string]interface{}{"topics": change.client.subs.names()},
+			})})
+
+		case request := <-h.streams.open:
+			h.registerStream(request)
+
+		case stream := <-h.streams.close:
+			h.removeStream(stream, false)
+
+		case r := <-h.replies:
+			if h.clients[r.client] {
+				h.enqueue(r.client, queued{kind: queuedResponse, data: r.data})
+			}
+
+		case <-h.inboxReady:
+			h.inboxMu.Lock()
+			inbox := h.inbox
+			h.inbox = nil
+			h.inboxMu.Unlock()
+
+			for _, out := range inbox {
+				h.fanOutStreams(out)
+				for client := range h.clients {
+					if client.subs.matches(out) {
+						h.enqueue(client, queued{key: out.key, data: out.data, binary: out.binary, kind: queuedDiff})
+					}
 				}
 			}
-			h.mu.RUnlock()
 		}
 	}
 }
 
-// BroadcastWarehouseState sends current warehouse state to all connected clients
-func (h *Hub) BroadcastWarehouseState(robots []*models.Robot, orders []models.Order) {
-	state := map[string]interface{}{
-		"type":   "warehouse_update",
-		"robots": robots,
-		"orders": orders,
+// enqueue queues a message for a client. A client whose queue is full gets its stale diffs
+// discarded and a fresh snapshot, one that falls behind again before the snapshot is closed.
+func (h *Hub) enqueue(client *Client, item queued) {
+	if client.queue.push(item) {
+		return
 	}
 
-	data, err := json.Marshal(state)
-	if err != nil {
-		log.Printf("Error marshaling warehouse state: %v", err)
+	if client.syncing || h.Snapshot == nil {
+		h.mu.Lock()
+		h.departed.Disconnected++
+		h.mu.Unlock()
+		count := h.remove(client, closeTooSlow)
+		log.Printf("Client %d too slow, disconnected, total clients: %d", client.id, count)
 		return
 	}
+	h.startSync(client)
+}
 
-	h.broadcast <- data
+// remove unregisters a client, closing its queue with the given frame, and returns the client count
+func (h *Hub) remove(client *Client, closing []byte) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.clients, client)
+	if client.binary {
+		h.binaryClients.Add(-1)
+	}
+	stats := client.queue.stats()
+	h.departed.Sent += stats.Sent
+	h.departed.Coalesced += stats.Coalesced
+	h.departed.Dropped += stats.Dropped
+	h.departed.Snapshots += stats.Snapshots
+	client.queue.close(closing)
+	return len(h.clients)
 }
 
-// BroadcastRobotUpdate sends a single robot update to all connected clients
-func (h *Hub) BroadcastRobotUpdate(update models.RobotUpdate) {
-	message := map[string]interface{}{
-		"type":   "robot_update",
-		"update": update,
+// startSync holds back diffs for client while a snapshot is built outside the loop, so
+// building it never waits on a producer. Queued diffs are discarded, the snapshot covers them.
+func (h *Hub) startSync(client *Client) {
+	if h.Snapshot == nil {
+		return
+	}
+
+	client.syncing = true
+	client.syncGen++
+	gen := client.syncGen
+	client.queue.hold()
+	go func() {
+		state := h.Snapshot()
+		state.Type = "snapshot"
+		data, err := json.Marshal(state)
+		if err != nil {
+			log.Printf("Error marshaling warehouse snapshot: %v", err)
+			return
+		}
+
+		select {
+		case h.synced <- clientSnapshot{client: client, gen: gen, data: data}:
+		case <-h.done:
+		}
+	}()
+}
+
+// shutdown closes every client with a close frame, ends the event streams and waits for the write pumps to send it
+func (h *Hub) shutdown() {
+	close(h.done)
+
+	h.mu.Lock()
+	closing := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for client := range h.clients {
+		client.queue.close(closing)
+		delete(h.clients, client)
+	}
+	for stream := range h.streams.streams {
+		delete(h.streams.streams, stream)
+		close(stream.Events)
+	}
+	h.mu.Unlock()
+
+	h.pumps.Wait()
+	log.Printf("WebSocket hub stopped")
+}
+
+// Stats returns the message counters of every client, connected or not
+func (h *Hub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stats := h.departed
+	stats.Clients = len(h.clients)
+	stats.Streams = len(h.streams.streams)
+	stats.StreamsShed = h.streams.shed
+	stats.PerClient = []ClientStats{}
+	for client := range h.clients {
+		cs := client.queue.stats()
+		cs.ID = client.id
+		cs.Addr = client.conn.RemoteAddr().String()
+		cs.Encoding = client.encoding()
+		stats.Sent += cs.Sent
+		stats.Coalesced += cs.Coalesced
+		stats.Dropped += cs.Dropped
+		stats.Snapshots += cs.Snapshots
+		stats.PerClient = append(stats.PerClient, cs)
+	}
+	sort.Slice(stats.PerClient, func(i, j int) bool { return stats.PerClient[i].ID < stats.PerClient[j].ID })
+	return stats
+}
+
+// ClientCount returns how many clients are connected
+func (h *Hub) ClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// withSeq returns the encoded message object with a seq field added in front
+func withSeq(data []byte, seq uint64) []byte {
+	stamped := make([]byte, 0, len(data)+24)
+	stamped = append(stamped, `{"seq":`...)
+	stamped = strconv.AppendUint(stamped, seq, 10)
+	stamped = append(stamped, ',')
+	return append(stamped, data[1:]...)
+}
+
+// publish encodes a diff and hands it to the hub without blocking, it is dropped once the hub has shut down
+func (h *Hub) publish(out outbound, message interface{}) {
+	select {
+	case <-h.done:
+		return
+	default:
 	}
 
 	data, err := json.Marshal(message)
 	if err != nil {
-		log.Printf("Error marshaling robot update: %v", err)
+		log.Printf("Error marshaling %s message: %v", out.topic, err)
 		return
 	}
+	out.data = data
+
+	h.inboxMu.Lock()
+	h.inbox = append(h.inbox, out)
+	h.inboxMu.Unlock()
+	select {
+	case h.inboxReady <- struct{}{}:
+	default: // The hub has not picked up the last signal yet
+	}
+}
+
+// reply queues a response for one client, dropping it once the hub has shut down
+func (h *Hub) reply(client *Client, response Response) {
+	select {
+	case h.replies <- reply{client: client, data: encodeResponse(response)}:
+	case <-h.done:
+	}
+}
+
+// wantsBinary reports whether a diff should also get a protobuf body
+func (h *Hub) wantsBinary() bool {
+	return h.binaryClients.Load() > 0
+}
+
+// robotUpdateMessage is a robot_update, the most frequent message by far, so it skips the map encoding
+type robotUpdateMessage struct {
+	Type   string             `json:"type"`
+	Update models.RobotUpdate `json:"update"`
+}
+
+// robotKey is the coalescing key of diffs about a robot
+func robotKey(robotID int) string {
+	return "robot:" + strconv.Itoa(robotID)
+}
+
+// BroadcastRobotUpdate sends a single robot update to the clients subscribed to the robot or its cell
+func (h *Hub) BroadcastRobotUpdate(update models.RobotUpdate) {
+	at := models.Position{X: update.X, Y: update.Y}
+	out := outbound{topic: TopicRobots, key: robotKey(update.RobotID), robotID: update.RobotID, at: &at}
+	if h.wantsBinary() {
+		out.binary = binaryRobotUpdate(update)
+	}
+	h.publish(out, robotUpdateMessage{Type: "robot_update", Update: update})
+}
+
+// BroadcastRobotRemoved tells clients a robot has left the fleet
+func (h *Hub) BroadcastRobotRemoved(robotID int) {
+	out := outbound{topic: TopicRobots, key: robotKey(robotID), robotID: robotID}
+	if h.wantsBinary() {
+		out.binary = binaryRobotRemoved(robotID)
+	}
+	h.publish(out, map[string]interface{}{
+		"type":     "robot_removed",
+		"robot_id": robotID,
+	})
+}
+
+// BroadcastOrderUpdate sends a created or changed order to the clients subscribed to orders
+func (h *Hub) BroadcastOrderUpdate(order models.Order) {
+	h.publish(outbound{topic: TopicOrders, key: "order:" + strconv.Itoa(order.ID)}, map[string]interface{}{
+		"type":  "order_update",
+		"order": order,
+	})
+}
+
+// BroadcastBinMoved sends the new content of a grid cell to the clients subscribed to inventory or the cell
+func (h *Hub) BroadcastBinMoved(slot models.BinSlot) {
+	at := models.Position{X: slot.X, Y: slot.Y, Z: slot.Z}
+	out := outbound{topic: TopicInventory, key: fmt.Sprintf("cell:%d,%d,%d", slot.X, slot.Y, slot.Z), at: &at}
+	if h.wantsBinary() {
+		out.binary = binaryCellUpdate(slot)
+	}
+	h.publish(out, map[string]interface{}{
+		"type": "cell_update",
+		"cell": slot,
+	})
+}
 
-	h.broadcast <- data
+// BroadcastEvent sends a warehouse event, e.g. a low-stock alert, to the clients subscribed to events
+func (h *Hub) BroadcastEvent(event models.Event) {
+	h.publish(outbound{topic: TopicEvents}, map[string]interface{}{
+		"type":  "event",
+		"event": event,
+	})
 }
@@ -0,0 +1,237 @@
+package websocket
+
+import (
+	"autostore-sim/backend/models"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestHubConcurrentClients connects and disconnects clients while robot updates are published,
+// then shuts the hub down with clients still connected. Run it with -race.
+func TestHubConcurrentClients(t *testing.T) {
+	hub := NewHub()
+	hub.Snapshot = func() WarehouseState { return WarehouseState{} }
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(done)
+	}()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, w, r, nil)
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	publishing := make(chan struct{})
+	var publishers sync.WaitGroup
+	for p := 0; p < 4; p++ {
+		publishers.Add(1)
+		go func() {
+			defer publishers.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-publishing:
+					return
+				default:
+				}
+				hub.BroadcastRobotUpdate(models.RobotUpdate{RobotID: p*100 + i%10, X: i % 8, Status: "moving"})
+				time.Sleep(100 * time.Microsecond) // Robots publish often, not in a tight loop
+			}
+		}()
+	}
+
+	var clients sync.WaitGroup
+	var kept []*websocket.Conn
+	var keptMu sync.Mutex
+	for c := 0; c < 16; c++ {
+		clients.Add(1)
+		go func() {
+			defer clients.Done()
+			for i := 0; i < 5; i++ {
+				conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+				if err != nil {
+					t.Errorf("dial: %v", err)
+					return
+				}
+				if _, _, err := conn.ReadMessage(); err != nil {
+					t.Errorf("first message: %v", err)
+				}
+				if c%4 == 0 && i == 4 {
+					keptMu.Lock()
+					kept = append(kept, conn) // Still connected when the hub shuts down
+					keptMu.Unlock()
+					continue
+				}
+				conn.Close()
+			}
+		}()
+	}
+	clients.Wait()
+	close(publishing)
+	publishers.Wait()
+
+	stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("hub did not shut down")
+	}
+
+	// Connected clients get a going-away close frame
+	for _, conn := range kept {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for {
+			_, _, err := conn.ReadMessage()
+			if err == nil {
+				continue
+			}
+			if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+				t.Errorf("expected a going-away close, got %v", err)
+			}
+			break
+		}
+		conn.Close()
+	}
+	if stats := hub.Stats(); stats.Clients != 0 {
+		t.Errorf("%d clients left after shutdown", stats.Clients)
+	}
+}
+
+func TestClientQueueCoalesces(t *testing.T) {
+	q := newClientQueue()
+	q.push(queued{key: "robot:1", data: []byte(`{"x":1}`)})
+	q.push(queued{key: "robot:2", data: []byte(`{"x":2}`)})
+	q.push(queued{key: "robot:1", data: []byte(`{"x":3}`)})
+	q.push(queued{data: []byte(`{"event":1}`)})
+	q.push(queued{data: []byte(`{"event":2}`)})
+
+	items, _, open := q.take()
+	if !open {
+		t.Fatal("queue closed")
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, string(item.data))
+	}
+	// The newer robot:1 diff takes the place of the queued one, diffs without a key are all kept
+	want := []string{`{"x":3}`, `{"x":2}`, `{"event":1}`, `{"event":2}`}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if stats := q.stats(); stats.Coalesced != 1 || stats.Sent != 4 || stats.Queued != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// Taking empties the index too, a diff about robot:1 is new again
+	q.push(queued{key: "robot:1", data: []byte(`{"x":4}`)})
+	if stats := q.stats(); stats.Queued != 1 || stats.Coalesced != 1 {
+		t.Errorf("unexpected stats after take %+v", stats)
+	}
+}
+
+func TestClientQueueCap(t *testing.T) {
+	q := newClientQueue()
+	fill(t, q, "robot")
+	if q.push(queued{key: "robot:new", data: []byte(`{}`)}) {
+		t.Error("push beyond the cap succeeded")
+	}
+	// A diff about an entity already queued still fits
+	if !q.push(queued{key: "robot:0", data: []byte(`{}`)}) {
+		t.Error("coalescing push failed on a full queue")
+	}
+	if stats := q.stats(); stats.Dropped != 1 || stats.Queued != maxQueuedMessages {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestHubResyncsOverflowingClient(t *testing.T) {
+	hub, client := testClient()
+	fill(t, client.queue, "robot")
+
+	hub.enqueue(client, queued{key: "robot:new", data: []byte(`{}`), kind: queuedDiff})
+	if !client.syncing {
+		t.Fatal("overflowing client is not resyncing")
+	}
+	// Queued diffs are dropped, the snapshot covers them; diffs are held until it is ready
+	hub.enqueue(client, queued{key: "robot:after", data: []byte(`{"after":1}`), kind: queuedDiff})
+	if items, _, _ := client.queue.take(); len(items) != 0 {
+		t.Errorf("%d diffs went out before the snapshot", len(items))
+	}
+
+	snapshot := <-hub.synced
+	client.syncing = false
+	client.queue.release(snapshot.data)
+	items, _, _ := client.queue.take()
+	if len(items) != 2 || items[0].kind != queuedSnapshot || !bytes.Equal(items[1].data, []byte(`{"after":1}`)) {
+		t.Fatalf("expected the snapshot then the held diff, got %d items", len(items))
+	}
+	if stats := client.queue.stats(); stats.Dropped != maxQueuedMessages+1 || stats.Snapshots != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if !hub.clients[client] {
+		t.Error("resynced client was removed")
+	}
+}
+
+func TestHubDisconnectsClientOverflowingWhileSyncing(t *testing.T) {
+	hub, client := testClient()
+	client.syncing = true
+	fill(t, client.queue, "robot")
+
+	hub.enqueue(client, queued{key: "robot:new", data: []byte(`{}`), kind: queuedDiff})
+	if hub.clients[client] {
+		t.Fatal("client overflowing while syncing is still registered")
+	}
+	_, closing, open := client.queue.take()
+	if open {
+		t.Fatal("queue of the disconnected client is still open")
+	}
+	if !bytes.Equal(closing, closeTooSlow) {
+		t.Errorf("close frame %q, want %q", closing, closeTooSlow)
+	}
+	code, _ := closeCode(closing)
+	if code != websocket.CloseTryAgainLater {
+		t.Errorf("close code %d, want %d", code, websocket.CloseTryAgainLater)
+	}
+	if stats := hub.Stats(); stats.Disconnected != 1 {
+		t.Errorf("disconnected %d, want 1", stats.Disconnected)
+	}
+}
+
+// testClient registers a client without a connection on a hub that is not running, so the
+// test can drive enqueue itself
+func testClient() (*Hub, *Client) {
+	hub := NewHub()
+	hub.Snapshot = func() WarehouseState { return WarehouseState{} }
+	client := &Client{hub: hub, id: 1, queue: newClientQueue(), subs: newSubscriptions()}
+	hub.clients[client] = true
+	return hub, client
+}
+
+// fill queues distinct diffs up to the cap
+func fill(t *testing.T, q *clientQueue, prefix string) {
+	t.Helper()
+	for i := 0; i < maxQueuedMessages; i++ {
+		if !q.push(queued{key: fmt.Sprintf("%s:%d", prefix, i), data: []byte(`{}`), kind: queuedDiff}) {
+			t.Fatalf("push %d failed below the cap", i)
+		}
+	}
+}
+
+// closeCode reads the status code of a close frame payload
+func closeCode(payload []byte) (int, string) {
+	if len(payload) < 2 {
+		return websocket.CloseNoStatusReceived, ""
+	}
+	return int(payload[0])<<8 | int(payload[1]), string(payload[2:])
+}
@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"autostore-sim/backend/models"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Topics clients subscribe to, every client starts subscribed to all four
+const (
+	TopicRobots    = "robots"    // robot_update and robot_removed
+	TopicOrders    = "orders"    // order_update
+	TopicInventory = "inventory" // cell_update
+	TopicEvents    = "events"    // event
+)
+
+// defaultTopics are the subscriptions of a newly connected client
+var defaultTopics = []string{TopicRobots, TopicOrders, TopicInventory, TopicEvents}
+
+// ClientMessage is a message from a client. Type is subscribe, unsubscribe, resync or command.
+type ClientMessage struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`      // Correlation ID echoed in the response
+	Topics  []string        `json:"topics,omitempty"`  // For subscribe and unsubscribe
+	Command string          `json:"command,omitempty"` // For command, e.g. create_order
+	Params  json.RawMessage `json:"params,omitempty"`  // Command parameters
+}
+
+// Response answers a subscribe, unsubscribe or command message
+type Response struct {
+	Type   string      `json:"type"` // Always "response"
+	ID     string      `json:"id,omitempty"`
+	OK     bool        `json:"ok"`
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// CommandFunc runs a client command and returns its result, e.g. handlers.Server.RunCommand
+type CommandFunc func(command string, params json.RawMessage) (interface{}, error)
+
+// topic is a parsed subscription: a whole topic, one robot or a rectangle of the grid
+type topic struct {
+	name    string // TopicRobots etc. for whole topics
+	robotID int    // For robot:<id>
+	region  *region
+}
+
+// region is an inclusive rectangle of grid cells
+type region struct {
+	x1, y1, x2, y2 int
+}
+
+// contains reports whether the cell lies inside the region
+func (r region) contains(pos models.Position) bool {
+	return pos.X >= r.x1 && pos.X <= r.x2 && pos.Y >= r.y1 && pos.Y <= r.y2
+}
+
+// parseTopic reads robots, orders, inventory, events, robot:<id> or region:<x1>,<y1>,<x2>,<y2>
+func parseTopic(raw string) (topic, error) {
+	switch raw {
+	case TopicRobots, TopicOrders, TopicInventory, TopicEvents:
+		return topic{name: raw}, nil
+	}
+
+	kind, arg, _ := strings.Cut(raw, ":")
+	switch kind {
+	case "robot":
+		id, err := strconv.Atoi(arg)
+		if err != nil || id <= 0 {
+			return topic{}, fmt.Errorf("invalid topic %q: expected robot:<id>", raw)
+		}
+		return topic{robotID: id}, nil
+
+	case "region":
+		parts := strings.Split(arg, ",")
+		if len(parts) != 4 {
+			return topic{}, fmt.Errorf("invalid topic %q: expected region:<x1>,<y1>,<x2>,<y2>", raw)
+		}
+		var coords [4]int
+		for i, part := range parts {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return topic{}, fmt.Errorf("invalid topic %q: expected region:<x1>,<y1>,<x2>,<y2>", raw)
+			}
+			coords[i] = n
+		}
+		r := region{x1: min(coords[0], coords[2]), y1: min(coords[1], coords[3]),
+			x2: max(coords[0], coords[2]), y2: max(coords[1], coords[3])}
+		return topic{region: &r}, nil
+	}
+
+	return topic{}, fmt.Errorf("unknown topic %q", raw)
+}
+
+// matches reports whether a diff belongs to the topic
+func (t topic) matches(out outbound) bool {
+	switch {
+	case t.robotID != 0:
+		return out.topic == TopicRobots && out.robotID == t.robotID
+	case t.region != nil:
+		return out.at != nil && t.region.contains(*out.at)
+	default:
+		return out.topic == t.name
+	}
+}
+
+// subscriptions are the topics a client receives diffs for, keyed by the topic as sent
+type subscriptions map[string]topic
+
+// newSubscriptions returns the default subscriptions
+func newSubscriptions() subscriptions {
+	subs := make(subscriptions)
+	for _, name := range defaultTopics {
+		subs[name] = topic{name: name}
+	}
+	return subs
+}
+
+// matches reports whether any subscription covers the diff
+func (s subscriptions) matches(out outbound) bool {
+	for _, t := range s {
+		if t.matches(out) {
+			return true
+		}
+	}
+	return false
+}
+
+// names lists the subscribed topics
+func (s subscriptions) names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	return names
+}
+
+// subscriptionChange adds or removes a client's topics, applied by the hub's Run loop
+type subscriptionChange struct {
+	client *Client
+	id     string
+	add    bool
+	topics map[string]topic
+}
+
+// reply is a response waiting to be queued for a client by the hub's Run loop
+type reply struct {
+	client *Client
+	data   []byte
+}
+
+// encodeResponse encodes a response, falling back to an error response if the result cannot be encoded
+func encodeResponse(response Response) []byte {
+	response.Type = "response"
+	data, err := json.Marshal(response)
+	if err != nil {
+		data, _ = json.Marshal(Response{Type: "response", ID: response.ID, Error: err.Error()})
+	}
+	return data
+}
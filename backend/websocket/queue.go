@@ -0,0 +1,183 @@
+package websocket
+
+import "sync"
+
+// maxQueuedMessages bounds a client's queue, a client that falls this far behind is resynced
+const maxQueuedMessages = 256
+
+// queued is a message waiting for a client's write pump
+type queued struct {
+	key  string // Entity the diff is about, e.g. robot:3, empty when it must not be coalesced
+	data []byte // Encoded message, diffs and snapshots without seq
+	kind queuedKind
+
+	binary []byte // Protobuf Message body for binary clients, nil when the write pump wraps data instead
+}
+
+// queuedKind tells the write pump how to number a message
+type queuedKind int
+
+const (
+	queuedDiff     queuedKind = iota // Numbered one after the previous diff
+	queuedSnapshot                   // Numbered with the last diff before it
+	queuedResponse                   // Not numbered
+)
+
+// clientQueue holds a client's outgoing messages. A newer diff about the same entity replaces
+// the one still queued, so a slow client skips intermediate states instead of stalling the hub.
+type clientQueue struct {
+	mu      sync.Mutex
+	items   []queued
+	index   map[string]int // Position of the queued diff per key
+	holding bool           // Waiting for a snapshot, only responses go out
+	closed  bool
+	closing []byte        // Close frame for the write pump, a normal closure when nil
+	notify  chan struct{} // Signalled when there is something to write or the queue closed
+
+	sent      uint64
+	coalesced uint64
+	dropped   uint64
+	snapshots uint64
+}
+
+// newClientQueue creates an empty queue
+func newClientQueue() *clientQueue {
+	return &clientQueue{
+		index:  make(map[string]int),
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// push queues a message, replacing a queued diff with the same key. It returns false when the
+// queue is full, the message is then dropped.
+func (q *clientQueue) push(item queued) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return true
+	}
+
+	if i, ok := q.index[item.key]; ok && item.key != "" {
+		q.items[i].data = item.data
+		q.items[i].binary = item.binary
+		q.coalesced++
+		return true
+	}
+	if len(q.items) >= maxQueuedMessages {
+		q.dropped++
+		return false
+	}
+
+	if item.key != "" {
+		q.index[item.key] = len(q.items)
+	}
+	q.items = append(q.items, item)
+	q.signal()
+	return true
+}
+
+// hold discards the queued diffs, which a coming snapshot supersedes, and keeps diffs back until
+// release. Responses still go out.
+func (q *clientQueue) hold() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	kept := q.items[:0]
+	for _, item := range q.items {
+		if item.kind == queuedResponse {
+			kept = append(kept, item)
+		} else {
+			q.dropped++
+		}
+	}
+	q.items = kept
+	q.reindex()
+	q.holding = true
+	q.snapshots++
+}
+
+// release puts the snapshot in front of the diffs queued since hold and lets them go out
+func (q *clientQueue) release(snapshot []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.items = append([]queued{{data: snapshot, kind: queuedSnapshot}}, q.items...)
+	q.reindex()
+	q.holding = false
+	q.signal()
+}
+
+// take removes and returns everything that may go out now. Once the queue is closed it
+// returns the close frame to send instead.
+func (q *clientQueue) take() (items []queued, closing []byte, open bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, q.closing, false
+	}
+
+	if !q.holding {
+		ready := q.items
+		q.items = nil
+		q.index = make(map[string]int)
+		q.sent += uint64(len(ready))
+		return ready, nil, true
+	}
+
+	var responses []queued
+	kept := q.items[:0]
+	for _, item := range q.items {
+		if item.kind == queuedResponse {
+			responses = append(responses, item)
+		} else {
+			kept = append(kept, item)
+		}
+	}
+	q.items = kept
+	q.reindex()
+	q.sent += uint64(len(responses))
+	return responses, nil, true
+}
+
+// close stops the queue, the write pump sends the close frame and exits. Closing twice keeps the first frame.
+func (q *clientQueue) close(closing []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.closing = closing
+	q.signal()
+}
+
+// stats returns the queue's counters and how many messages are waiting
+func (q *clientQueue) stats() ClientStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return ClientStats{
+		Sent:      q.sent,
+		Coalesced: q.coalesced,
+		Dropped:   q.dropped,
+		Snapshots: q.snapshots,
+		Queued:    len(q.items),
+	}
+}
+
+// reindex rebuilds the key index after items moved, caller must hold mu
+func (q *clientQueue) reindex() {
+	q.index = make(map[string]int, len(q.items))
+	for i, item := range q.items {
+		if item.key != "" && item.kind == queuedDiff {
+			q.index[item.key] = i
+		}
+	}
+}
+
+// signal wakes the write pump, caller must hold mu
+func (q *clientQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default: // Already signalled
+	}
+}
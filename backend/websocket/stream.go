@@ -0,0 +1,245 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// replayBufferSize is how many diffs the hub keeps for streams resuming with Last-Event-ID
+	replayBufferSize = 1024
+
+	// streamBufferSize bounds a stream's pending diffs, a stream this far behind is closed and
+	// resumes from the replay buffer when the client reconnects
+	streamBufferSize = 256
+)
+
+// ErrStreamsEnded is returned by OpenStream once the server has started shutting down
+var ErrStreamsEnded = errors.New("event streams have ended")
+
+// StreamEvent is a diff for an event stream. Its ID is "<epoch>-<seq>", where the epoch tells
+// IDs of an earlier server run apart and seq counts the hub's diffs.
+type StreamEvent struct {
+	ID   string
+	Data []byte
+}
+
+// Stream is a one-way subscription to the hub's diffs, served as Server-Sent Events
+type Stream struct {
+	hub  *Hub
+	subs subscriptions
+
+	// Initial holds what goes out before Events: the missed diffs when resuming, otherwise a
+	// snapshot carrying the ID of the last diff it includes
+	Initial []StreamEvent
+
+	// Events delivers the diffs after Initial. It is closed when the stream falls behind or the hub stops.
+	Events chan StreamEvent
+}
+
+// replayed is a diff kept in the replay buffer with its stream seq
+type replayed struct {
+	seq uint64
+	out outbound
+}
+
+// streamRequest asks the Run loop to register a stream
+type streamRequest struct {
+	stream *Stream
+	lastID string
+	result chan streamStart
+}
+
+// streamStart is where a newly registered stream begins
+type streamStart struct {
+	seq     uint64     // Seq of the last diff before the stream
+	resumed bool       // The missed diffs were still in the replay buffer
+	replay  []replayed // Missed diffs when resumed
+}
+
+// streamState is the hub's side of event streams, owned by the Run loop except where noted
+type streamState struct {
+	streams map[*Stream]bool // Also guarded by Hub.mu for Stats
+	open    chan streamRequest
+	close   chan *Stream
+	seq     uint64
+	epoch   string
+	replay  []replayed // Ring of the last replayBufferSize diffs
+	next    int        // Position in replay of the next diff
+
+	shed      uint64        // Streams closed for falling behind, guarded by Hub.mu
+	ended     chan struct{} // Closed by EndStreams
+	endedOnce sync.Once
+}
+
+// newStreamState creates the hub's stream state, with an epoch taken from the clock
+func newStreamState() streamState {
+	return streamState{
+		streams: make(map[*Stream]bool),
+		open:    make(chan streamRequest),
+		close:   make(chan *Stream),
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		replay:  make([]replayed, 0, replayBufferSize),
+		ended:   make(chan struct{}),
+	}
+}
+
+// eventID formats a stream seq as an event ID
+func (s *streamState) eventID(seq uint64) string {
+	return s.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// record numbers a diff and keeps it for replay
+func (s *streamState) record(out outbound) uint64 {
+	s.seq++
+	entry := replayed{seq: s.seq, out: out}
+	if len(s.replay) < replayBufferSize {
+		s.replay = append(s.replay, entry)
+	} else {
+		s.replay[s.next] = entry
+	}
+	s.next = (s.next + 1) % replayBufferSize
+	return s.seq
+}
+
+// since returns the diffs after lastID, and false when lastID is from another run or no longer buffered
+func (s *streamState) since(lastID string) ([]replayed, bool) {
+	epoch, rawSeq, ok := strings.Cut(lastID, "-")
+	if !ok || epoch != s.epoch {
+		return nil, false
+	}
+	last, err := strconv.ParseUint(rawSeq, 10, 64)
+	if err != nil || last > s.seq {
+		return nil, false
+	}
+	missed := s.seq - last
+	if missed > uint64(len(s.replay)) {
+		return nil, false
+	}
+
+	oldest := 0
+	if len(s.replay) == replayBufferSize {
+		oldest = s.next
+	}
+	diffs := make([]replayed, 0, missed)
+	for i := len(s.replay) - int(missed); i < len(s.replay); i++ {
+		diffs = append(diffs, s.replay[(oldest+i)%len(s.replay)])
+	}
+	return diffs, true
+}
+
+// OpenStream subscribes to the diffs of the given topics, all topics when none are given.
+// lastID is the Last-Event-ID of a reconnecting client: the diffs it missed are replayed when
+// still buffered, otherwise the stream starts with a snapshot.
+func (h *Hub) OpenStream(lastID string, topics []string) (*Stream, error) {
+	subs := newSubscriptions()
+	if len(topics) > 0 {
+		subs = make(subscriptions, len(topics))
+		for _, raw := range topics {
+			t, err := parseTopic(raw)
+			if err != nil {
+				return nil, err
+			}
+			subs[raw] = t
+		}
+	}
+
+	stream := &Stream{hub: h, subs: subs, Events: make(chan StreamEvent, streamBufferSize)}
+	request := streamRequest{stream: stream, lastID: lastID, result: make(chan streamStart, 1)}
+	select {
+	case h.streams.open <- request:
+	case <-h.streams.ended:
+		return nil, ErrStreamsEnded
+	case <-h.done:
+		return nil, ErrStreamsEnded
+	}
+	start := <-request.result
+
+	if start.resumed {
+		for _, diff := range start.replay {
+			if subs.matches(diff.out) {
+				stream.Initial = append(stream.Initial, StreamEvent{ID: h.streams.eventID(diff.seq), Data: diff.out.data})
+			}
+		}
+		return stream, nil
+	}
+
+	// Diffs from after registration queue up in Events meanwhile, the snapshot may already include some
+	if h.Snapshot != nil {
+		state := h.Snapshot()
+		state.Type = "snapshot"
+		data, err := json.Marshal(state)
+		if err != nil {
+			stream.Close()
+			return nil, err
+		}
+		stream.Initial = []StreamEvent{{ID: h.streams.eventID(start.seq), Data: data}}
+	}
+	return stream, nil
+}
+
+// Close unsubscribes the stream, e.g. when its client has gone
+func (s *Stream) Close() {
+	select {
+	case s.hub.streams.close <- s:
+	case <-s.hub.done:
+	}
+}
+
+// Ended is closed once EndStreams was called, stream handlers return then
+func (h *Hub) Ended() <-chan struct{} {
+	return h.streams.ended
+}
+
+// EndStreams ends every event stream and refuses new ones, so http.Server.Shutdown need not
+// wait for them. Register it with http.Server.RegisterOnShutdown.
+func (h *Hub) EndStreams() {
+	h.streams.endedOnce.Do(func() { close(h.streams.ended) })
+}
+
+// registerStream adds a stream in the Run loop and tells it where it starts
+func (h *Hub) registerStream(request streamRequest) {
+	start := streamStart{seq: h.streams.seq}
+	if request.lastID != "" {
+		start.replay, start.resumed = h.streams.since(request.lastID)
+	}
+
+	h.mu.Lock()
+	h.streams.streams[request.stream] = true
+	h.mu.Unlock()
+	request.result <- start
+}
+
+// removeStream drops a stream in the Run loop and closes its channel
+func (h *Hub) removeStream(stream *Stream, tooSlow bool) {
+	if !h.streams.streams[stream] {
+		return
+	}
+	h.mu.Lock()
+	delete(h.streams.streams, stream)
+	if tooSlow {
+		h.streams.shed++
+	}
+	h.mu.Unlock()
+	close(stream.Events)
+}
+
+// fanOutStreams numbers a diff, keeps it for replay and hands it to the matching streams.
+// A stream with no room left is closed, its client resumes from the replay buffer.
+func (h *Hub) fanOutStreams(out outbound) {
+	seq := h.streams.record(out)
+	for stream := range h.streams.streams {
+		if !stream.subs.matches(out) {
+			continue
+		}
+		select {
+		case stream.Events <- StreamEvent{ID: h.streams.eventID(seq), Data: out.data}:
+		default:
+			h.removeStream(stream, true)
+		}
+	}
+}
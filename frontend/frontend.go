@@ -0,0 +1,81 @@
+// Package frontend serves the React app from the Go binary: the production build embedded from
+// dist, or in development the Vite dev server behind a proxy. Run pnpm build in this directory
+// before go build to embed the app.
+package frontend
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strings"
+)
+
+//go:embed all:dist
+var dist embed.FS
+
+// notBuilt answers every request when the binary was built without the app
+const notBuilt = "The frontend is not built into this binary: run pnpm install && pnpm build in frontend/ and build again\n"
+
+// Handler serves the embedded build. Paths that are not a file get index.html, so the client
+// side router can take over on a reload or a deep link, unless they look like a missing asset.
+func Handler() http.Handler {
+	files, _ := fs.Sub(dist, "dist")
+	index, err := fs.ReadFile(files, "index.html")
+	if err != nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, notBuilt, http.StatusNotFound)
+		})
+	}
+
+	fileServer := http.FileServer(http.FS(files))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if info, err := fs.Stat(files, name); err == nil && !info.IsDir() && name != "index.html" {
+			if strings.HasPrefix(name, "assets/") {
+				// Vite puts a content hash in every asset name
+				w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			}
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		if path.Ext(name) != "" && name != "index.html" {
+			http.NotFound(w, r)
+			return
+		}
+
+		// index.html names the current assets, it must not be cached
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK) // Routers may have set a 404 for a path they do not know
+		w.Write(index)
+	})
+}
+
+// DevProxy forwards requests to the Vite dev server, e.g. http://localhost:5173, hot reload
+// WebSocket included, so the app and the API share one origin during development too
+func DevProxy(target string) (http.Handler, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Vite dev server URL %q: expected e.g. http://localhost:5173", target)
+	}
+
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(u)
+			r.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, fmt.Sprintf("Vite dev server at %s: %v (is pnpm dev running?)", target, err), http.StatusBadGateway)
+		},
+	}, nil
+}
@@ -0,0 +1,97 @@
+// Client for the simulator's API. The app is served by the Go backend (embedded or through its
+// Vite proxy in development), so every URL is relative to the page's own origin.
+
+const TOKEN_KEY = 'autostore.token'
+
+// Problem is the application/problem+json body of every API error
+export interface Problem {
+  type: string
+  title: string
+  status: number
+  detail?: string
+  instance?: string
+  errors?: { field: string; message: string }[]
+}
+
+export class ApiError extends Error {
+  readonly problem: Problem
+
+  constructor(problem: Problem) {
+    super(problem.detail ?? problem.title)
+    this.name = 'ApiError'
+    this.problem = problem
+  }
+
+  get status(): number {
+    return this.problem.status
+  }
+}
+
+// The API key or token sent with every request, kept across reloads
+export function getToken(): string | null {
+  return localStorage.getItem(TOKEN_KEY)
+}
+
+export function setToken(token: string | null): void {
+  if (token) {
+    localStorage.setItem(TOKEN_KEY, token)
+  } else {
+    localStorage.removeItem(TOKEN_KEY)
+  }
+}
+
+// request calls the API and decodes the JSON response, errors are thrown as ApiError
+export async function request<T>(path: string, init: RequestInit = {}): Promise<T> {
+  const headers = new Headers(init.headers)
+  const token = getToken()
+  if (token) {
+    headers.set('Authorization', `Bearer ${token}`)
+  }
+  if (init.body !== undefined && !headers.has('Content-Type')) {
+    headers.set('Content-Type', 'application/json')
+  }
+
+  const response = await fetch(`/api${path}`, { ...init, headers })
+  if (!response.ok) {
+    let problem: Problem
+    try {
+      problem = await response.json()
+    } catch {
+      problem = { type: 'about:blank', title: response.statusText, status: response.status }
+    }
+    throw new ApiError(problem)
+  }
+  return response.json() as Promise<T>
+}
+
+export const get = <T>(path: string) => request<T>(path)
+
+export const post = <T>(path: string, body?: unknown) =>
+  request<T>(path, { method: 'POST', body: body === undefined ? undefined : JSON.stringify(body) })
+
+export const del = <T>(path: string) => request<T>(path, { method: 'DELETE' })
+
+// withToken adds the token as ?access_token=, WebSocket and EventSource cannot send headers
+function withToken(url: URL): string {
+  const token = getToken()
+  if (token) {
+    url.searchParams.set('access_token', token)
+  }
+  return url.toString()
+}
+
+// webSocketURL is the address of the live updates WebSocket
+export function webSocketURL(): string {
+  const url = new URL('/ws', window.location.href)
+  url.protocol = url.protocol === 'https:' ? 'wss:' : 'ws:'
+  return withToken(url)
+}
+
+// eventStreamURL is the address of the Server-Sent Events stream, optionally limited to topics
+export function eventStreamURL(topics: string[] = []): string {
+  const url = new URL('/api/events', window.location.href)
+  for (const topic of topics) {
+    url.searchParams.append('topic', topic)
+  }
+  return withToken(url)
+}